@@ -0,0 +1,280 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/subliker/backendproj/auth"
+	"github.com/subliker/backendproj/config"
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/health"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/metrics"
+	"github.com/subliker/backendproj/outbox"
+	"github.com/subliker/backendproj/passhash"
+	"github.com/subliker/backendproj/ratelimit"
+	"github.com/subliker/backendproj/route"
+	"github.com/subliker/backendproj/stream"
+	"github.com/subliker/backendproj/tracing"
+	"github.com/subliker/backendproj/webhook"
+)
+
+// App is the whole service: configuration, database, HTTP server and background workers.
+// Nothing is served until Start is called.
+type App struct {
+	cfg    *config.Config
+	db     *db.DataBase
+	server *http.Server
+	// flushes spans on shutdown
+	stopTracing func(context.Context) error
+	// publishes the outbox to the sinks
+	relay *outbox.Relay
+
+	// workers run until Shutdown cancels workersCtx
+	workersCtx   context.Context
+	stopWorkers  context.CancelFunc
+	workers      sync.WaitGroup
+	mu           sync.Mutex
+	running      map[string]bool
+	shuttingDown atomic.Bool
+}
+
+// New applies cfg, connects to the database and builds the router.
+// Connecting is retried until ctx is done or cfg.DB.ConnectTimeout passes.
+func New(ctx context.Context, cfg *config.Config) (*App, error) {
+	if err := configure(cfg); err != nil {
+		return nil, err
+	}
+
+	stopTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+
+	var base db.DataBase
+	if err := base.Init(ctx, cfg.DB); err != nil {
+		return nil, errors.Join(err, stopTracing(ctx))
+	}
+	metrics.RegisterDB(base.SQL(), cfg.DB.Name)
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	a := &App{
+		cfg:         cfg,
+		db:          &base,
+		stopTracing: stopTracing,
+		workersCtx:  workersCtx,
+		stopWorkers: stopWorkers,
+		running:     map[string]bool{},
+	}
+
+	// validated by config.Load
+	sunset, _ := cfg.API.Sunset()
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore())
+	limiter.Disabled = !cfg.RateLimit.Enabled
+	dispatcher := webhook.NewDispatcher(&base, cfg.Webhooks)
+	sinks, err := outbox.NewSinks(cfg.Outbox, dispatcher, os.Stdout)
+	if err != nil {
+		stopWorkers()
+		return nil, errors.Join(err, base.Close(), stopTracing(ctx))
+	}
+	// with postgres every instance streams the events of all of them,
+	// memory is enough for a single instance
+	broker := stream.NewBroker(cfg.Stream.Buffer)
+	if cfg.Stream.Backend == "memory" {
+		sinks = append(sinks, broker)
+	}
+	a.relay = outbox.NewRelay(&base, sinks, cfg.Outbox)
+	handler := route.NewHandler(&base)
+	handler.LegacyNotFound = cfg.API.LegacyNotFound
+	handler.Stream, handler.StreamHeartbeat = broker, cfg.Stream.Heartbeat
+	handler.ImportMaxBookings, handler.ImportHorizon = cfg.Booking.ImportMaxBookings, cfg.Booking.ImportHorizon
+	handler.BatchMaxOperations = cfg.Booking.BatchMaxOperations
+	router := SetupRouter(Services{
+		Handler: handler,
+		Probes:  health.NewHandler(&base, a),
+		Logger:  slog.Default(),
+		Auth:    auth.NewAuthenticator(&base, cfg.Auth.AdminToken.Value(), cfg.Auth.Required),
+		Limiter: limiter,
+		Limits:  cfg.RateLimit,
+		API:     API{LegacyRoutes: cfg.API.LegacyRoutes, LegacySunset: sunset},
+	})
+	if err := router.SetTrustedProxies(trustedProxies(cfg.HTTP.TrustedProxies)); err != nil {
+		stopWorkers()
+		return nil, errors.Join(err, a.relay.Close(), base.Close(), stopTracing(ctx))
+	}
+	a.server = &http.Server{
+		Addr:              cfg.HTTP.Addr,
+		Handler:           router,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+	// open streams would keep Shutdown waiting
+	a.server.RegisterOnShutdown(broker.Close)
+
+	a.Go("booking-metrics", a.refreshBookingMetrics)
+	if cfg.Purge.Retention > 0 {
+		a.Go("purge", a.purgeDeleted)
+	}
+	a.Go("outbox", a.relay.Run)
+	a.Go("webhooks", dispatcher.Run)
+	if cfg.Stream.Backend == "postgres" {
+		a.Go("stream", stream.NewListener(db.DSN(cfg.DB), &base, broker).Run)
+	}
+	return a, nil
+}
+
+// bookingMetricsInterval is how often the bookings_active gauge is refreshed.
+const bookingMetricsInterval = 30 * time.Second
+
+// refreshBookingMetrics keeps metrics.BookingsActive up to date until ctx is done.
+func (a *App) refreshBookingMetrics(ctx context.Context) {
+	ticker := time.NewTicker(bookingMetricsInterval)
+	defer ticker.Stop()
+	for {
+		if count, err := a.db.CountActiveBookings(ctx); err == nil {
+			metrics.BookingsActive.Set(float64(count))
+		} else if ctx.Err() == nil {
+			slog.WarnContext(ctx, "count active bookings", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeDeleted removes users and bookings deleted longer than purge.retention ago
+// every purge.interval until ctx is done.
+func (a *App) purgeDeleted(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.Purge.Interval)
+	defer ticker.Stop()
+	for {
+		bookings, users, err := a.db.PurgeDeleted(ctx, time.Now().Add(-a.cfg.Purge.Retention))
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "purge deleted users and bookings", "err", err)
+		} else if bookings > 0 || users > 0 {
+			slog.InfoContext(ctx, "deleted users and bookings purged", "users", users, "bookings", bookings)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Go runs a background worker. The worker must return soon after ctx is done,
+// a worker that returns earlier makes the service not ready.
+func (a *App) Go(name string, worker func(ctx context.Context)) {
+	a.mu.Lock()
+	a.running[name] = true
+	a.mu.Unlock()
+
+	a.workers.Add(1)
+	go func() {
+		defer a.workers.Done()
+		worker(a.workersCtx)
+
+		a.mu.Lock()
+		a.running[name] = false
+		a.mu.Unlock()
+	}()
+}
+
+// Workers returns the background workers and whether each one is running.
+func (a *App) Workers() map[string]bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	workers := make(map[string]bool, len(a.running))
+	for name, running := range a.running {
+		workers[name] = running
+	}
+	return workers
+}
+
+func (a *App) ShuttingDown() bool {
+	return a.shuttingDown.Load()
+}
+
+// Start serves HTTP requests until Shutdown is called, then it returns http.ErrServerClosed.
+func (a *App) Start() error {
+	return a.server.ListenAndServe()
+}
+
+// Shutdown stops accepting requests and waits for the active ones,
+// then stops the workers and waits for them, and closes the outbox sinks and the database.
+// If ctx is done first, the database is closed anyway and ctx's error is returned.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.shuttingDown.Store(true)
+	err := a.server.Shutdown(ctx)
+
+	a.stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		a.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = errors.Join(err, ctx.Err())
+	}
+
+	return errors.Join(err, a.relay.Close(), a.db.Close(), a.stopTracing(ctx))
+}
+
+// trustedProxies splits the comma separated list, an empty list trusts no proxy.
+func trustedProxies(list string) []string {
+	var proxies []string
+	for _, proxy := range strings.Split(list, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// configure applies the configuration to the validation, hashing and i18n packages.
+func configure(cfg *config.Config) error {
+	if cfg.I18n.LocalesDir != "" {
+		if err := i18n.LoadDir(cfg.I18n.LocalesDir); err != nil {
+			return err
+		}
+	}
+
+	p, h := cfg.Auth.Password, cfg.Auth.Hash
+	dv.Policy = &dv.PasswordPolicy{MinLength: p.MinLength, MaxLength: p.MaxLength, MinEntropy: p.MinEntropy}
+	if h.Algorithm == "bcrypt" {
+		dv.Policy.MaxBytes = passhash.BcryptMaxBytes
+	}
+	if p.BreachedList != "" {
+		if err := dv.Policy.LoadBreachedList(p.BreachedList); err != nil {
+			return err
+		}
+	}
+
+	bc := passhash.Bcrypt{Cost: h.BcryptCost}
+	ar := passhash.DefaultArgon2id()
+	ar.Memory, ar.Time, ar.Threads = h.Argon2Memory, h.Argon2Time, h.Argon2Threads
+	if h.Algorithm == "argon2id" {
+		passhash.Default = passhash.NewManager(ar, bc)
+	} else {
+		passhash.Default = passhash.NewManager(bc, ar)
+	}
+
+	dv.CommentMinLength = cfg.Booking.CommentMinLength
+	dv.CommentMaxLength = cfg.Booking.CommentMaxLength
+	dv.MaxBookingDuration = cfg.Booking.MaxDuration
+	return nil
+}
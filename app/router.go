@@ -0,0 +1,207 @@
+package app
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/subliker/backendproj/auth"
+	"github.com/subliker/backendproj/config"
+	dv "github.com/subliker/backendproj/datavalidator"
+	_ "github.com/subliker/backendproj/docs/v1"
+	"github.com/subliker/backendproj/health"
+	"github.com/subliker/backendproj/logging"
+	"github.com/subliker/backendproj/metrics"
+	"github.com/subliker/backendproj/ratelimit"
+	"github.com/subliker/backendproj/route"
+	"github.com/subliker/backendproj/tracing"
+
+	"github.com/gin-gonic/gin"
+
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// Services are what the router serves and the middlewares it uses.
+type Services struct {
+	Handler *route.Handler
+	Probes  *health.Handler
+	Logger  *slog.Logger
+	Auth    *auth.Authenticator
+	Limiter *ratelimit.Limiter
+	Limits  config.RateLimit
+	API     API
+}
+
+// API configures the unversioned /api paths.
+type API struct {
+	// LegacyRoutes serves /api/... as a deprecated alias of /api/v1/...
+	LegacyRoutes bool
+	// LegacySunset is announced in the Sunset header when it's set
+	LegacySunset time.Time
+}
+
+// apiVersion is a handler set served under /api/<name>.
+type apiVersion struct {
+	name     string
+	register func(api *gin.RouterGroup, s Services)
+	// feeds registers the routes calendar apps fetch with a token parameter instead of an API key
+	feeds func(api *gin.RouterGroup, s Services)
+}
+
+// apiVersions are served side by side. A new version gets its own handler set,
+// register func and docs instance, the older ones keep working unchanged.
+var apiVersions = []apiVersion{
+	{"v1", registerV1, registerV1Feeds},
+}
+
+// legacyVersion is the version the unversioned /api paths are an alias of.
+const legacyVersion = "v1"
+
+func SetupRouter(s Services) *gin.Engine {
+	probes := s.Probes
+	router := gin.New()
+	router.Use(tracing.Middleware(), logging.Middleware(s.Logger), gin.CustomRecoveryWithWriter(nil, recovered), metrics.Middleware())
+
+	// probes and metrics are registered before any auth or rate limiting
+	router.GET("/healthz", probes.Healthz)
+	router.GET("/readyz", probes.Readyz)
+	router.GET("/status", probes.Status)
+	router.GET("/metrics", metrics.Handler())
+
+	// an IP sending invalid keys is stopped before they're looked up, then the client is
+	// authenticated, so it's rate limited by its key rather than by IP
+	failures := s.Limiter.LimitFailures(policy("auth_failures", s.Limits.AuthFailures), http.StatusUnauthorized)
+	common := []gin.HandlerFunc{failures, s.Auth.Middleware(), s.Limiter.Limit(policy("default", s.Limits.Default))}
+	for _, v := range apiVersions {
+		v.register(router.Group("/api/"+v.name, common...), s)
+		v.feeds(router.Group("/api/"+v.name, failures), s)
+		router.GET("/docs/"+v.name+"/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.InstanceName(v.name)))
+	}
+	if s.API.LegacyRoutes {
+		for _, v := range apiVersions {
+			if v.name == legacyVersion {
+				legacy := append([]gin.HandlerFunc{deprecated(v.name, s.API.LegacySunset)}, common...)
+				v.register(router.Group("/api", legacy...), s)
+				v.feeds(router.Group("/api", deprecated(v.name, s.API.LegacySunset), failures), s)
+			}
+		}
+	}
+
+	router.NoRoute(func(c *gin.Context) { dv.ResMessageCode(c, http.StatusNotFound, "route_not_found") })
+
+	docsIndex := "/docs/" + legacyVersion + "/index.html"
+	router.GET("/docs", func(c *gin.Context) { c.Redirect(http.StatusMovedPermanently, docsIndex) })
+	router.GET("/docs/index.html", func(c *gin.Context) { c.Redirect(http.StatusMovedPermanently, docsIndex) })
+
+	return router
+}
+
+// registerV1 registers the /api/v1 handlers on api.
+func registerV1(api *gin.RouterGroup, s Services) {
+	h, limiter := s.Handler, s.Limiter
+	authLimit := limiter.Limit(policy("auth", s.Limits.Auth))
+	signupLimit := limiter.Limit(policy("signup", s.Limits.Signup))
+	usersRead, usersWrite := auth.Require(auth.ScopeUsersRead), auth.Require(auth.ScopeUsersWrite)
+	bookingsRead, bookingsWrite := auth.Require(auth.ScopeBookingsRead), auth.Require(auth.ScopeBookingsWrite)
+
+	api.GET("/user/export", usersRead, h.ExportUsers)
+	api.GET("/user/:id", usersRead, h.GetUserDataById)
+	api.POST("/user", signupLimit, usersWrite, h.AddNewUser)
+	api.POST("/user/login", authLimit, usersRead, h.LoginUser)
+	api.DELETE("/user/:id", usersWrite, h.DeleteUserDataByID)
+	api.PUT("/user/:id", authLimit, usersWrite, h.UpdateUserDataById)
+	api.POST("/user/:id/restore", usersWrite, h.RestoreUser)
+	api.POST("/user/:id/calendar/token", authLimit, usersWrite, h.CreateCalendarToken)
+	api.DELETE("/user/:id/calendar/token", usersWrite, h.DeleteCalendarToken)
+
+	api.GET("/booking/stream", bookingsRead, h.StreamBookings)
+	api.POST("/booking/import", bookingsWrite, h.ImportBookings)
+	api.GET("/booking/export", bookingsRead, h.ExportBookings)
+	api.POST("/booking/batch", bookingsWrite, h.BatchBookings)
+	api.GET("/booking/:id", bookingsRead, h.GetBookingDataById)
+	api.GET("/booking", bookingsRead, h.GetBookings)
+	api.POST("/booking", bookingsWrite, h.AddNewBooking)
+	api.DELETE("/booking/:id", bookingsWrite, h.DeleteBookingByID)
+	api.PUT("/booking/:id", bookingsWrite, h.UpdateBookingDataById)
+	api.POST("/booking/:id/restore", bookingsWrite, h.RestoreBooking)
+	api.GET("/booking/:id/history", bookingsRead, h.GetBookingHistory)
+
+	adminOnly := auth.Require(auth.ScopeAdmin)
+	api.GET("/resource", bookingsRead, h.GetResources)
+	api.GET("/resource/:id", bookingsRead, h.GetResource)
+	api.GET("/resource/:id/calendar.ics", bookingsRead, h.GetResourceCalendar)
+	api.POST("/resource", adminOnly, h.CreateResource)
+	api.PUT("/resource/:id", adminOnly, h.UpdateResource)
+	api.DELETE("/resource/:id", adminOnly, h.DeleteResource)
+	api.GET("/audit", adminOnly, h.GetAuditLog)
+	api.GET("/audit/verify", adminOnly, h.VerifyAuditLog)
+
+	keys := api.Group("/admin/keys", authLimit, adminOnly)
+	keys.POST("", h.CreateAPIKey)
+	keys.GET("", h.GetAPIKeys)
+	keys.POST("/:id/rotate", h.RotateAPIKey)
+	keys.DELETE("/:id", h.RevokeAPIKey)
+
+	admin := api.Group("/admin", adminOnly)
+	admin.POST("/webhooks", h.CreateWebhook)
+	admin.GET("/webhooks", h.GetWebhooks)
+	admin.GET("/webhooks/:id", h.GetWebhook)
+	admin.PUT("/webhooks/:id", h.UpdateWebhook)
+	admin.DELETE("/webhooks/:id", h.DeleteWebhook)
+	admin.GET("/webhooks/:id/deliveries", h.GetWebhookDeliveries)
+	admin.GET("/deliveries", h.GetDeliveries)
+	admin.POST("/deliveries/:id/redeliver", h.RedeliverWebhookDelivery)
+}
+
+// registerV1Feeds registers the /api/v1 feeds on api, which has no auth middleware, only the limit of auth failures.
+// A feed checks its token parameter itself, requests without one are authenticated like the rest of the API.
+func registerV1Feeds(api *gin.RouterGroup, s Services) {
+	h := s.Handler
+	keyAuth := unlessQuery("token", s.Auth.Middleware(), auth.Require(auth.ScopeBookingsRead))
+	limit := s.Limiter.Limit(policy("default", s.Limits.Default))
+
+	api.GET("/user/:id/calendar.ics", keyAuth, limit, h.GetUserCalendar)
+}
+
+// unlessQuery runs handlers unless the request has the query parameter param.
+func unlessQuery(param string, handlers ...gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query(param) != "" {
+			return
+		}
+		for _, handler := range handlers {
+			if handler(c); c.IsAborted() {
+				return
+			}
+		}
+	}
+}
+
+// deprecated marks responses of the unversioned /api paths with Deprecation, Sunset (when it's set)
+// and a Link to the same path under /api/<version>.
+func deprecated(version string, sunset time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		if !sunset.IsZero() {
+			c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		successor := "/api/" + version + strings.TrimPrefix(c.Request.URL.Path, "/api")
+		c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+	}
+}
+
+// recovered logs a panic of a handler with the request's logger and answers 500.
+// http.ErrAbortHandler is panicked again, so the server drops the connection.
+func recovered(c *gin.Context, err any) {
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+	logging.FromContext(c.Request.Context()).Error("panic", "err", err)
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
+
+func policy(name string, p config.RatePolicy) ratelimit.Policy {
+	return ratelimit.Policy{Name: name, Requests: p.Requests, Period: p.Period, Burst: p.Burst, KeyByIP: p.KeyBy == "ip"}
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/subliker/backendproj/auth"
+	"github.com/subliker/backendproj/config"
+	"github.com/subliker/backendproj/logging"
+	"github.com/subliker/backendproj/ratelimit"
+	"github.com/subliker/backendproj/route"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouterVersions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const admin = "admin-token"
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore())
+	limiter.Disabled = true
+	router := func(api API) *gin.Engine {
+		return SetupRouter(Services{
+			// the requests below are rejected before the store is used
+			Handler: route.NewHandler(nil),
+			Logger:  logging.New(io.Discard, config.Log{}),
+			Auth:    auth.NewAuthenticator(nil, admin, true),
+			Limiter: limiter,
+			API:     api,
+		})
+	}
+	tests := []struct {
+		name       string
+		api        API
+		path       string
+		status     int
+		deprecated bool
+		sunset     string
+	}{
+		{"versioned", API{LegacyRoutes: true, LegacySunset: sunset}, "/api/v1/booking/x?tz=UTC", http.StatusBadRequest, false, ""},
+		{"legacy", API{LegacyRoutes: true}, "/api/booking/x?tz=UTC", http.StatusBadRequest, true, ""},
+		{"legacy with sunset", API{LegacyRoutes: true, LegacySunset: sunset}, "/api/booking/x", http.StatusBadRequest, true, "Fri, 01 Jan 2027 00:00:00 GMT"},
+		{"legacy off", API{}, "/api/booking/x", http.StatusNotFound, false, ""},
+		{"unknown version", API{LegacyRoutes: true}, "/api/v2/booking/x", http.StatusNotFound, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+admin)
+			w := httptest.NewRecorder()
+			router(tt.api).ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			h := w.Header()
+			if !tt.deprecated {
+				if h.Get("Deprecation") != "" || h.Get("Link") != "" || h.Get("Sunset") != "" {
+					t.Errorf("deprecation headers on a current path: %v", h)
+				}
+				return
+			}
+			if h.Get("Deprecation") != "true" || h.Get("Sunset") != tt.sunset {
+				t.Errorf("Deprecation %q, Sunset %q, want true and %q", h.Get("Deprecation"), h.Get("Sunset"), tt.sunset)
+			}
+			successor := "</api/v1" + req.URL.Path[len("/api"):] + `>; rel="successor-version"`
+			if h.Get("Link") != successor {
+				t.Errorf("Link %q, want %q", h.Get("Link"), successor)
+			}
+		})
+	}
+}
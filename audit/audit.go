@@ -0,0 +1,41 @@
+// Package audit tells who makes a change, the database records it with the change in the audit log.
+package audit
+
+import "context"
+
+// kinds of actors
+const (
+	// ActorAdmin is a request with the admin token
+	ActorAdmin = "admin"
+	// ActorAPIKey is a request with an API key
+	ActorAPIKey = "api_key"
+	// ActorAnonymous is a request without credentials, when authentication isn't required
+	ActorAnonymous = "anonymous"
+	// ActorSystem is the service itself, e.g. the purge worker
+	ActorSystem = "system"
+)
+
+// Actor is who makes a change.
+type Actor struct {
+	Type string
+	// KeyID is the API key of ActorAPIKey
+	KeyID     int
+	RequestID string
+	IP        string
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying a.
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// FromContext returns the actor of ctx, ActorSystem when there's none.
+func FromContext(ctx context.Context) Actor {
+	a, ok := ctx.Value(actorKey{}).(Actor)
+	if !ok {
+		return Actor{Type: ActorSystem}
+	}
+	return a
+}
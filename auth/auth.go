@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+)
+
+const (
+	ScopeUsersRead     = "users:read"
+	ScopeUsersWrite    = "users:write"
+	ScopeBookingsRead  = "bookings:read"
+	ScopeBookingsWrite = "bookings:write"
+	// ScopeAdmin allows the admin endpoints, e.g. managing API keys
+	ScopeAdmin = "admin"
+)
+
+// Scopes are all the scopes a key can have.
+var Scopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeBookingsRead, ScopeBookingsWrite, ScopeAdmin}
+
+// Principal is who makes the request.
+type Principal struct {
+	// KeyID is the API key of the request, 0 for the admin token
+	KeyID  int
+	Scopes []string
+	// UserID restricts the principal to one user and their bookings when it's set
+	UserID *int
+	// ResourceID restricts the principal to one resource and its bookings when it's set,
+	// with UserID to the bookings of the user that take the resource
+	ResourceID *int
+}
+
+// Has reports whether p has scope, admin has every scope.
+func (p *Principal) Has(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the request, nil for anonymous requests.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// CanAccessUser reports whether the request may read or change the user with userID and their bookings.
+// Anonymous requests can when authentication isn't required, otherwise Middleware has rejected them already.
+// A principal restricted to a resource can't, it only sees the bookings of the resource.
+func CanAccessUser(ctx context.Context, userID int) bool {
+	p := FromContext(ctx)
+	return p == nil || p.ResourceID == nil && (p.UserID == nil || *p.UserID == userID)
+}
+
+// CanAccessBooking reports whether the request may read or change a booking of the user with userID
+// taking the resource with resourceID, nil for a booking without a resource.
+func CanAccessBooking(ctx context.Context, userID int, resourceID *int) bool {
+	p := FromContext(ctx)
+	return p == nil || (p.UserID == nil || *p.UserID == userID) &&
+		(p.ResourceID == nil || resourceID != nil && *resourceID == *p.ResourceID)
+}
+
+// CanAccessResource reports whether the request may read or change the resource with id and its bookings.
+func CanAccessResource(ctx context.Context, id int) bool {
+	p := FromContext(ctx)
+	return p == nil || p.ResourceID == nil || *p.ResourceID == id
+}
+
+// CanAccessAllUsers reports whether the request isn't restricted to one user or resource.
+func CanAccessAllUsers(ctx context.Context) bool {
+	p := FromContext(ctx)
+	return p == nil || p.UserID == nil && p.ResourceID == nil
+}
+
+// CanAccessAllResources reports whether the request isn't restricted to one resource.
+func CanAccessAllResources(ctx context.Context) bool {
+	p := FromContext(ctx)
+	return p == nil || p.ResourceID == nil
+}
+
+// IsAdmin reports whether the request has the admin scope. Anonymous requests never have it,
+// even when authentication isn't required, like Require(ScopeAdmin) rejects them.
+func IsAdmin(ctx context.Context) bool {
+	p := FromContext(ctx)
+	return p != nil && p.Has(ScopeAdmin)
+}
+
+// keyPrefix marks the keys of this service, so they're easy to find in leaked code or logs.
+const keyPrefix = "bk_"
+
+// calendarTokenPrefix marks calendar feed tokens.
+const calendarTokenPrefix = "cal_"
+
+// GenerateKey returns a new random API key, its prefix to store and its hash to store.
+func GenerateKey() (key, prefix, hash string, err error) {
+	return generate(keyPrefix)
+}
+
+// GenerateCalendarToken returns a new random calendar feed token, its prefix to store and its hash to store.
+func GenerateCalendarToken() (token, prefix, hash string, err error) {
+	return generate(calendarTokenPrefix)
+}
+
+// generate returns a random key starting with marker. The marker and 7 more characters
+// are its prefix, stored in plain text to tell keys apart.
+func generate(marker string) (key, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = marker + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:len(marker)+7], HashKey(key), nil
+}
+
+// HashKey returns the hash an API key is stored and looked up by.
+// Keys are random 256-bit values, so a fast hash is enough, unlike for passwords.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIsAdmin(t *testing.T) {
+	userID := 7
+	tests := []struct {
+		name string
+		p    *Principal
+		want bool
+	}{
+		{"anonymous", nil, false},
+		{"admin token", &Principal{Scopes: []string{ScopeAdmin}}, true},
+		{"admin key restricted to a user", &Principal{KeyID: 1, Scopes: []string{ScopeAdmin}, UserID: &userID}, true},
+		{"key without admin", &Principal{KeyID: 2, Scopes: []string{ScopeUsersRead, ScopeBookingsRead}}, false},
+		{"key without scopes", &Principal{KeyID: 3}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.p != nil {
+				ctx = WithPrincipal(ctx, tt.p)
+			}
+			if got := IsAdmin(ctx); got != tt.want {
+				t.Errorf("IsAdmin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanAccess(t *testing.T) {
+	user, other, room, desk := 7, 8, 3, 4
+	anonymous := (*Principal)(nil)
+	unrestricted := &Principal{KeyID: 1, Scopes: []string{ScopeBookingsWrite}}
+	ofUser := &Principal{KeyID: 2, Scopes: []string{ScopeBookingsWrite}, UserID: &user}
+	ofRoom := &Principal{KeyID: 3, Scopes: []string{ScopeBookingsWrite}, ResourceID: &room}
+	ofUserInRoom := &Principal{KeyID: 4, Scopes: []string{ScopeBookingsWrite}, UserID: &user, ResourceID: &room}
+	tests := []struct {
+		name  string
+		check func(context.Context) bool
+		// want is the result for anonymous, unrestricted, ofUser, ofRoom and ofUserInRoom
+		want [5]bool
+	}{
+		{"the user", func(ctx context.Context) bool { return CanAccessUser(ctx, user) }, [5]bool{true, true, true, false, false}},
+		{"another user", func(ctx context.Context) bool { return CanAccessUser(ctx, other) }, [5]bool{true, true, false, false, false}},
+		{"all users", CanAccessAllUsers, [5]bool{true, true, false, false, false}},
+		{"the room", func(ctx context.Context) bool { return CanAccessResource(ctx, room) }, [5]bool{true, true, true, true, true}},
+		{"another resource", func(ctx context.Context) bool { return CanAccessResource(ctx, desk) }, [5]bool{true, true, true, false, false}},
+		{"all resources", CanAccessAllResources, [5]bool{true, true, true, false, false}},
+		{"booking of the user in the room", func(ctx context.Context) bool { return CanAccessBooking(ctx, user, &room) }, [5]bool{true, true, true, true, true}},
+		{"booking of another user in the room", func(ctx context.Context) bool { return CanAccessBooking(ctx, other, &room) }, [5]bool{true, true, false, true, false}},
+		{"booking of the user at a desk", func(ctx context.Context) bool { return CanAccessBooking(ctx, user, &desk) }, [5]bool{true, true, true, false, false}},
+		{"booking of the user without a resource", func(ctx context.Context) bool { return CanAccessBooking(ctx, user, nil) }, [5]bool{true, true, true, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, p := range []*Principal{anonymous, unrestricted, ofUser, ofRoom, ofUserInRoom} {
+				ctx := context.Background()
+				if p != nil {
+					ctx = WithPrincipal(ctx, p)
+				}
+				if got := tt.check(ctx); got != tt.want[i] {
+					t.Errorf("principal %d: got %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
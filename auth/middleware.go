@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/subliker/backendproj/audit"
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/logging"
+	"github.com/subliker/backendproj/model"
+	"github.com/subliker/backendproj/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyStore is the storage of API keys, *db.DataBase in production.
+type KeyStore interface {
+	GetAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, db.HttpCode, error)
+	TouchAPIKey(ctx context.Context, id int) error
+}
+
+// Authenticator finds out who makes a request.
+type Authenticator struct {
+	Keys KeyStore
+	// AdminToken is a bootstrap credential with every scope, it's disabled when empty
+	AdminToken string
+	// Required rejects requests without credentials, otherwise they're served anonymously
+	Required bool
+}
+
+func NewAuthenticator(keys KeyStore, adminToken string, required bool) *Authenticator {
+	return &Authenticator{Keys: keys, AdminToken: adminToken, Required: required}
+}
+
+// Middleware authenticates the request by "Authorization: Bearer <key>" or "X-API-Key: <key>".
+// The key is the admin token or an API key. Invalid, revoked and expired keys get 401.
+// The principal is stored in the request context, see FromContext, and so is the actor of the audit log.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := credentials(c)
+		if token == "" {
+			if a.Required {
+				c.Header("WWW-Authenticate", "Bearer")
+				dv.ResMessageCode(c, http.StatusUnauthorized, "api_key_required")
+				c.Abort()
+				return
+			}
+			setActor(c, audit.Actor{Type: audit.ActorAnonymous})
+			return
+		}
+
+		p, httpCode, err := a.authenticate(c.Request.Context(), token)
+		if err != nil {
+			dv.ResErr(c, int(httpCode), err)
+			c.Abort()
+			return
+		}
+		if p == nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			dv.ResMessageCode(c, http.StatusUnauthorized, "api_key_invalid")
+			c.Abort()
+			return
+		}
+
+		if p.KeyID == 0 {
+			ratelimit.SetClient(c, "admin")
+			setActor(c, audit.Actor{Type: audit.ActorAdmin})
+		} else {
+			ratelimit.SetClient(c, "key:"+strconv.Itoa(p.KeyID))
+			setActor(c, audit.Actor{Type: audit.ActorAPIKey, KeyID: p.KeyID})
+		}
+		ctx := WithPrincipal(c.Request.Context(), p)
+		c.Request = c.Request.WithContext(logging.WithLogger(ctx, logging.FromContext(ctx).With("api_key_id", p.KeyID)))
+	}
+}
+
+// setActor stores the actor of the request for the audit log, with the request ID and client IP.
+func setActor(c *gin.Context, actor audit.Actor) {
+	ctx := c.Request.Context()
+	actor.RequestID, actor.IP = logging.RequestID(ctx), c.ClientIP()
+	c.Request = c.Request.WithContext(audit.WithActor(ctx, actor))
+}
+
+// authenticate returns the principal of token, nil if the token isn't valid.
+func (a *Authenticator) authenticate(ctx context.Context, token string) (*Principal, db.HttpCode, error) {
+	if a.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.AdminToken)) == 1 {
+		return &Principal{Scopes: []string{ScopeAdmin}}, 200, nil
+	}
+
+	key, httpCode, err := a.Keys.GetAPIKeyByHash(ctx, HashKey(token))
+	if err != nil {
+		return nil, httpCode, err
+	}
+	if key.Id == 0 || !key.Active(time.Now()) {
+		return nil, 200, nil
+	}
+	if err := a.Keys.TouchAPIKey(ctx, key.Id); err != nil {
+		logging.FromContext(ctx).Warn("record api key use", "api_key_id", key.Id, "err", err)
+	}
+	return &Principal{KeyID: key.Id, Scopes: key.Scopes, UserID: key.User_id, ResourceID: key.Resource_id}, 200, nil
+}
+
+func credentials(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// Require rejects requests whose principal doesn't have scope with 403.
+// Anonymous requests pass, unless scope is ScopeAdmin.
+func Require(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := FromContext(c.Request.Context())
+		if (p == nil && scope != ScopeAdmin) || (p != nil && p.Has(scope)) {
+			return
+		}
+		if p == nil {
+			c.Header("WWW-Authenticate", "Bearer")
+			dv.ResMessageCode(c, http.StatusUnauthorized, "api_key_required")
+		} else {
+			dv.ResErr(c, http.StatusForbidden, dv.NewError("scope_missing", i18n.Args{"scope": scope}))
+		}
+		c.Abort()
+	}
+}
@@ -0,0 +1,375 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the whole service configuration.
+//
+// Values are applied in this order, later ones win:
+// defaults, the YAML file (-config flag or CONFIG_FILE), environment variables
+// (the .env file is loaded into the environment first), command line flags.
+// Every field has a flag named after its YAML path, e.g. -db.host.
+type Config struct {
+	DB        DB        `yaml:"db"`
+	HTTP      HTTP      `yaml:"http"`
+	Auth      Auth      `yaml:"auth"`
+	Booking   Booking   `yaml:"booking"`
+	I18n      I18n      `yaml:"i18n"`
+	Log       Log       `yaml:"log"`
+	Tracing   Tracing   `yaml:"tracing"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	API       API       `yaml:"api"`
+	Webhooks  Webhooks  `yaml:"webhooks"`
+	Outbox    Outbox    `yaml:"outbox"`
+	Stream    Stream    `yaml:"stream"`
+	Purge     Purge     `yaml:"purge"`
+}
+
+type DB struct {
+	Host     string `yaml:"host" env:"DB_HOST" usage:"PostgreSQL host"`
+	Port     int    `yaml:"port" env:"DB_PORT" usage:"PostgreSQL port"`
+	User     string `yaml:"user" env:"DB_USER" usage:"PostgreSQL user"`
+	Password Secret `yaml:"password" env:"DB_PASSWORD" usage:"PostgreSQL password"`
+	Name     string `yaml:"name" env:"DB_NAME" usage:"PostgreSQL database"`
+	SSLMode  string `yaml:"sslmode" env:"DB_SSLMODE" usage:"PostgreSQL sslmode"`
+	//zone of times stored before the TIMESTAMPTZ migration
+	LegacyTimezone string `yaml:"legacy_timezone" env:"DB_LEGACY_TIMEZONE" usage:"time zone of times stored without zone by old versions"`
+	//startup retries with exponential backoff from RetryInitial up to RetryMax between attempts
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" usage:"how long to retry connecting at startup"`
+	RetryInitial   time.Duration `yaml:"retry_initial" env:"DB_RETRY_INITIAL" usage:"first delay between connection attempts"`
+	RetryMax       time.Duration `yaml:"retry_max" env:"DB_RETRY_MAX" usage:"longest delay between connection attempts"`
+}
+
+type HTTP struct {
+	Addr              string        `yaml:"addr" env:"HTTP_ADDR" usage:"address the HTTP server listens on"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" usage:"time to read a whole request"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" usage:"time to read request headers"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" usage:"time to write a response"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" usage:"keep-alive connection idle time"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" usage:"time to drain requests and workers on SIGTERM"`
+	//client IPs are taken from X-Forwarded-For only behind these proxies
+	TrustedProxies string `yaml:"trusted_proxies" env:"HTTP_TRUSTED_PROXIES" usage:"comma separated IPs or CIDRs of trusted reverse proxies"`
+}
+
+type Auth struct {
+	Password PasswordPolicy `yaml:"password"`
+	Hash     PasswordHash   `yaml:"hash"`
+	//bootstrap credential with every scope, used to create the first API keys
+	AdminToken Secret `yaml:"admin_token" env:"AUTH_ADMIN_TOKEN" usage:"admin token, empty disables it"`
+	//false keeps the API open for requests without a key
+	Required bool `yaml:"required" env:"AUTH_REQUIRED" usage:"reject /api requests without an API key"`
+}
+
+type PasswordPolicy struct {
+	MinLength    int     `yaml:"min_length" env:"PASSWORD_MIN_LENGTH" usage:"minimal password length in characters"`
+	MaxLength    int     `yaml:"max_length" env:"PASSWORD_MAX_LENGTH" usage:"maximal password length in characters"`
+	MinEntropy   float64 `yaml:"min_entropy" env:"PASSWORD_MIN_ENTROPY" usage:"minimal estimated password strength in bits, 0 disables"`
+	BreachedList string  `yaml:"breached_list" env:"PASSWORD_BREACHED_LIST" usage:"file with breached passwords or their SHA-1 hashes, hashes sorted by hash are looked up on disk"`
+}
+
+type PasswordHash struct {
+	Algorithm     string `yaml:"algorithm" env:"PASSWORD_HASH" usage:"hash for new passwords: bcrypt or argon2id"`
+	BcryptCost    int    `yaml:"bcrypt_cost" env:"BCRYPT_COST" usage:"bcrypt cost"`
+	Argon2Memory  uint32 `yaml:"argon2_memory" env:"ARGON2_MEMORY" usage:"argon2id memory in KiB"`
+	Argon2Time    uint32 `yaml:"argon2_time" env:"ARGON2_TIME" usage:"argon2id iterations"`
+	Argon2Threads uint8  `yaml:"argon2_threads" env:"ARGON2_THREADS" usage:"argon2id parallelism"`
+}
+
+type Booking struct {
+	CommentMinLength int `yaml:"comment_min_length" env:"BOOKING_COMMENT_MIN_LENGTH" usage:"minimal length of a non-empty booking comment"`
+	CommentMaxLength int `yaml:"comment_max_length" env:"BOOKING_COMMENT_MAX_LENGTH" usage:"maximal length of a booking comment"`
+	//0 means unlimited
+	MaxDuration time.Duration `yaml:"max_duration" env:"BOOKING_MAX_DURATION" usage:"longest allowed booking, 0 is unlimited"`
+	//an .ics import with more bookings is rejected
+	ImportMaxBookings int `yaml:"import_max_bookings" env:"BOOKING_IMPORT_MAX_BOOKINGS" usage:"most bookings an .ics import may create"`
+	//repeating events without an end are expanded this far from now
+	ImportHorizon time.Duration `yaml:"import_horizon" env:"BOOKING_IMPORT_HORIZON" usage:"how far ahead endless repeating events are imported"`
+	//a batch with more operations is rejected
+	BatchMaxOperations int `yaml:"batch_max_operations" env:"BOOKING_BATCH_MAX_OPERATIONS" usage:"most operations of a booking batch"`
+}
+
+type I18n struct {
+	LocalesDir string `yaml:"locales_dir" env:"LOCALES_DIR" usage:"directory with extra <language>.json message catalogs"`
+}
+
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" usage:"lowest logged level: debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" usage:"log format: json or text"`
+}
+
+type Tracing struct {
+	//none, stdout or otlp
+	Exporter     string  `yaml:"exporter" env:"TRACING_EXPORTER" usage:"where spans are exported: none, stdout or otlp"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT" usage:"OTLP/HTTP collector host:port"`
+	OTLPInsecure bool    `yaml:"otlp_insecure" env:"TRACING_OTLP_INSECURE" usage:"send spans to the collector over plain HTTP"`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" usage:"share of new traces that are sampled, 0..1"`
+	ServiceName  string  `yaml:"service_name" env:"TRACING_SERVICE_NAME" usage:"service.name of the spans"`
+}
+
+type RateLimit struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED" usage:"limit requests per client"`
+	//every /api request
+	Default RatePolicy `yaml:"default" env:"RATE_LIMIT_DEFAULT_"`
+	//requests that check or change credentials
+	Auth RatePolicy `yaml:"auth" env:"RATE_LIMIT_AUTH_"`
+	//user registration
+	Signup RatePolicy `yaml:"signup" env:"RATE_LIMIT_SIGNUP_"`
+	//requests rejected with 401, counted by IP before the key is looked up
+	AuthFailures RatePolicy `yaml:"auth_failures" env:"RATE_LIMIT_AUTH_FAILURES_"`
+}
+
+// RatePolicy is a token bucket: Burst requests at once, refilled at Requests per Period.
+type RatePolicy struct {
+	Requests int           `yaml:"requests" env:"REQUESTS" usage:"requests allowed per period"`
+	Period   time.Duration `yaml:"period" env:"PERIOD" usage:"period of the request limit"`
+	//0 means Requests
+	Burst int    `yaml:"burst" env:"BURST" usage:"requests allowed at once, 0 is the same as requests"`
+	KeyBy string `yaml:"key_by" env:"KEY_BY" usage:"who is limited: ip, or client (API key or user, ip for anonymous requests)"`
+}
+
+type API struct {
+	//serve /api/... as a deprecated alias of /api/v1/...
+	LegacyRoutes bool `yaml:"legacy_routes" env:"API_LEGACY_ROUTES" usage:"serve unversioned /api paths as an alias of /api/v1"`
+	//RFC 3339 or YYYY-MM-DD, empty means not announced
+	LegacySunset string `yaml:"legacy_sunset" env:"API_LEGACY_SUNSET" usage:"date the unversioned /api paths are removed at, for the Sunset header"`
+	//for clients written before 404 responses
+	LegacyNotFound bool `yaml:"legacy_not_found" env:"API_LEGACY_NOT_FOUND" usage:"answer 200 {} instead of 404 when a user or booking is not found by id"`
+}
+
+type Webhooks struct {
+	Timeout time.Duration `yaml:"timeout" env:"WEBHOOKS_TIMEOUT" usage:"time a receiver has to answer a delivery"`
+	//a delivery is dead after MaxAttempts failed attempts, retried with exponential backoff from RetryInitial up to RetryMax
+	MaxAttempts  int           `yaml:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS" usage:"attempts before a delivery goes to the dead letters"`
+	RetryInitial time.Duration `yaml:"retry_initial" env:"WEBHOOKS_RETRY_INITIAL" usage:"delay after the first failed attempt"`
+	RetryMax     time.Duration `yaml:"retry_max" env:"WEBHOOKS_RETRY_MAX" usage:"longest delay between attempts"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"WEBHOOKS_POLL_INTERVAL" usage:"how often due deliveries are looked for"`
+	BatchSize    int           `yaml:"batch_size" env:"WEBHOOKS_BATCH_SIZE" usage:"deliveries sent per poll"`
+}
+
+type Outbox struct {
+	//comma separated: webhook, stdout, nats, kafka
+	Sinks        string        `yaml:"sinks" env:"OUTBOX_SINKS" usage:"comma separated sinks events are published to: webhook, stdout, nats, kafka"`
+	Timeout      time.Duration `yaml:"timeout" env:"OUTBOX_TIMEOUT" usage:"time a sink has to publish an event"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL" usage:"how often unpublished events are looked for"`
+	BatchSize    int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE" usage:"events published per poll"`
+	//failed events are retried forever, with exponential backoff from RetryInitial up to RetryMax
+	RetryInitial time.Duration `yaml:"retry_initial" env:"OUTBOX_RETRY_INITIAL" usage:"delay after the first failed attempt"`
+	RetryMax     time.Duration `yaml:"retry_max" env:"OUTBOX_RETRY_MAX" usage:"longest delay between attempts"`
+	//0 keeps published events forever
+	Retention time.Duration `yaml:"retention" env:"OUTBOX_RETENTION" usage:"how long published events are kept, 0 is forever"`
+	NATS      OutboxNATS    `yaml:"nats" env:"OUTBOX_NATS_"`
+	Kafka     OutboxKafka   `yaml:"kafka" env:"OUTBOX_KAFKA_"`
+}
+
+type OutboxNATS struct {
+	URL string `yaml:"url" env:"URL" usage:"NATS server URLs, comma separated"`
+	//events go to <subject>.<event type>, e.g. backendproj.events.booking.created
+	Subject string `yaml:"subject" env:"SUBJECT" usage:"subject prefix of the events"`
+}
+
+type OutboxKafka struct {
+	Brokers string `yaml:"brokers" env:"BROKERS" usage:"Kafka brokers host:port, comma separated"`
+	//messages are keyed by aggregate, so events of a user or booking stay in one partition in order
+	Topic string `yaml:"topic" env:"TOPIC" usage:"topic of the events"`
+}
+
+type Stream struct {
+	//postgres gets the events of every instance with LISTEN/NOTIFY, memory only the events of this one
+	Backend   string        `yaml:"backend" env:"STREAM_BACKEND" usage:"where streamed events come from: postgres or memory"`
+	Heartbeat time.Duration `yaml:"heartbeat" env:"STREAM_HEARTBEAT" usage:"keep-alive interval of idle streams"`
+	//a subscriber further behind is disconnected and resumes with Last-Event-ID
+	Buffer int `yaml:"buffer" env:"STREAM_BUFFER" usage:"events buffered per subscriber"`
+}
+
+type Purge struct {
+	//deleted users and bookings can be restored until they're purged, 0 keeps them forever
+	Retention time.Duration `yaml:"retention" env:"PURGE_RETENTION" usage:"how long deleted users and bookings are kept, 0 is forever"`
+	Interval  time.Duration `yaml:"interval" env:"PURGE_INTERVAL" usage:"how often deleted users and bookings past retention are removed"`
+}
+
+// Sunset parses LegacySunset, it's zero when LegacySunset is empty.
+func (a API) Sunset() (time.Time, error) {
+	if a.LegacySunset == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", a.LegacySunset); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, a.LegacySunset)
+}
+
+func Default() Config {
+	return Config{
+		DB: DB{
+			Host:           "localhost",
+			Port:           5432,
+			User:           "postgres",
+			Name:           "postgres",
+			SSLMode:        "disable",
+			LegacyTimezone: "Europe/Moscow",
+			ConnectTimeout: time.Minute,
+			RetryInitial:   500 * time.Millisecond,
+			RetryMax:       10 * time.Second,
+		},
+		HTTP: HTTP{
+			Addr:              ":8000",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Auth: Auth{
+			Password: PasswordPolicy{
+				MinLength:  8,
+				MaxLength:  64,
+				MinEntropy: 40,
+			},
+			Hash: PasswordHash{
+				Algorithm:     "bcrypt",
+				BcryptCost:    12,
+				Argon2Memory:  64 * 1024,
+				Argon2Time:    3,
+				Argon2Threads: 2,
+			},
+		},
+		Booking: Booking{
+			CommentMinLength:   5,
+			CommentMaxLength:   120,
+			ImportMaxBookings:  1000,
+			ImportHorizon:      365 * 24 * time.Hour,
+			BatchMaxOperations: 500,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: Tracing{
+			Exporter:     "none",
+			OTLPEndpoint: "localhost:4318",
+			SampleRatio:  1,
+			ServiceName:  "backendproj",
+		},
+		RateLimit: RateLimit{
+			Enabled:      true,
+			Default:      RatePolicy{Requests: 300, Period: time.Minute, KeyBy: "client"},
+			Auth:         RatePolicy{Requests: 10, Period: time.Minute, KeyBy: "client"},
+			Signup:       RatePolicy{Requests: 5, Period: 10 * time.Minute, KeyBy: "ip"},
+			AuthFailures: RatePolicy{Requests: 20, Period: time.Minute, KeyBy: "ip"},
+		},
+		API: API{
+			LegacyRoutes: true,
+		},
+		Webhooks: Webhooks{
+			Timeout:      10 * time.Second,
+			MaxAttempts:  8,
+			RetryInitial: 30 * time.Second,
+			RetryMax:     6 * time.Hour,
+			PollInterval: 2 * time.Second,
+			BatchSize:    20,
+		},
+		Outbox: Outbox{
+			Sinks:        "webhook",
+			Timeout:      10 * time.Second,
+			PollInterval: time.Second,
+			BatchSize:    100,
+			RetryInitial: time.Second,
+			RetryMax:     5 * time.Minute,
+			Retention:    7 * 24 * time.Hour,
+			NATS:         OutboxNATS{URL: "nats://localhost:4222", Subject: "backendproj.events"},
+			Kafka:        OutboxKafka{Brokers: "localhost:9092", Topic: "backendproj.events"},
+		},
+		Stream: Stream{
+			Backend:   "postgres",
+			Heartbeat: 15 * time.Second,
+			Buffer:    256,
+		},
+		Purge: Purge{
+			Retention: 30 * 24 * time.Hour,
+			Interval:  time.Hour,
+		},
+	}
+}
+
+// Load builds the configuration from the YAML file, environment and args (without the program name).
+func Load(args []string) (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	cfg := Default()
+	fields := fieldsOf(&cfg)
+
+	fs := flag.NewFlagSet("backendproj", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML config file")
+	flags := map[string]string{}
+	for _, f := range fields {
+		key := f.key
+		fs.Func(key, f.usage+" (env "+f.env+")", func(v string) error {
+			flags[key] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		if err := loadFile(&cfg, *path); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(f.env); ok && v != "" {
+			if err := f.set(v); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.env, err)
+			}
+		}
+	}
+	for _, f := range fields {
+		if v, ok := flags[f.key]; ok {
+			if err := f.set(v); err != nil {
+				return nil, fmt.Errorf("-%s: %w", f.key, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Print writes the configuration as YAML with secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes a YAML config file and returns its path.
+func writeFile(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "db:\n  port: 6001\n  host: file-host\nlog:\n  level: warn\n")
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		port int
+		host string
+		// level isn't set by env or flags in any case, it comes from the file or the default
+		level string
+	}{
+		{"defaults", nil, nil, 5432, "localhost", "info"},
+		{"file over defaults", nil, []string{"-config", file}, 6001, "file-host", "warn"},
+		{"file from CONFIG_FILE", map[string]string{"CONFIG_FILE": file}, nil, 6001, "file-host", "warn"},
+		{"env over file", map[string]string{"DB_PORT": "6002"}, []string{"-config", file}, 6002, "file-host", "warn"},
+		{"empty env is ignored", map[string]string{"DB_PORT": ""}, []string{"-config", file}, 6001, "file-host", "warn"},
+		{"flag over env", map[string]string{"DB_PORT": "6002", "DB_HOST": "env-host"}, []string{"-config", file, "-db.port", "6003"}, 6003, "env-host", "warn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"CONFIG_FILE", "DB_PORT", "DB_HOST", "LOG_LEVEL"} {
+				t.Setenv(name, tt.env[name])
+			}
+			cfg, err := Load(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DB.Port != tt.port || cfg.DB.Host != tt.host || cfg.Log.Level != tt.level {
+				t.Errorf("port %d host %q level %q, want %d %q %q", cfg.DB.Port, cfg.DB.Host, cfg.Log.Level, tt.port, tt.host, tt.level)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		args []string
+		// want is in the error
+		want string
+	}{
+		{"unknown key in the file", "db:\n  hots: x\n", nil, nil, "hots"},
+		{"bad env value", "", map[string]string{"DB_PORT": "five"}, nil, "DB_PORT"},
+		{"bad duration flag", "", nil, []string{"-http.read_timeout", "10"}, "-http.read_timeout"},
+		{"unknown flag", "", nil, []string{"-db.hots", "x"}, "db.hots"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"CONFIG_FILE", "DB_PORT"} {
+				t.Setenv(name, tt.env[name])
+			}
+			args := tt.args
+			if tt.yaml != "" {
+				args = append([]string{"-config", writeFile(t, tt.yaml)}, args...)
+			}
+			_, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want one about %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldSet(t *testing.T) {
+	cfg := Default()
+	fields := map[string]field{}
+	for _, f := range fieldsOf(&cfg) {
+		fields[f.key] = f
+	}
+	for key, value := range map[string]string{"http.read_timeout": " 3s ", "db.port": "6000", "db.password": "hunter2"} {
+		if err := fields[key].set(value); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+	}
+	if cfg.HTTP.ReadTimeout != 3*time.Second || cfg.DB.Port != 6000 || cfg.DB.Password.Value() != "hunter2" {
+		t.Errorf("read timeout %v port %d password %q", cfg.HTTP.ReadTimeout, cfg.DB.Port, cfg.DB.Password.Value())
+	}
+	if env := fields["db.port"].env; env != "DB_PORT" {
+		t.Errorf("env of db.port is %q, want DB_PORT", env)
+	}
+
+	var out bytes.Buffer
+	if err := cfg.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "hunter2") || !strings.Contains(out.String(), redacted) {
+		t.Errorf("printed the password:\n%s", out.String())
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Secret is a string that is never printed.
+type Secret string
+
+const redacted = "<redacted>"
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// Value returns the secret itself.
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// field is a configurable leaf value of Config.
+type field struct {
+	key   string
+	env   string
+	usage string
+	value reflect.Value
+}
+
+func fieldsOf(cfg *Config) []field {
+	return collect(reflect.ValueOf(cfg).Elem(), "", "")
+}
+
+// collect walks the struct v. The env tag of a struct field is a prefix
+// for the env tags inside it, so a struct type can be used more than once.
+func collect(v reflect.Value, prefix, envPrefix string) []field {
+	var fields []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := prefix + sf.Tag.Get("yaml")
+		if sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, collect(v.Field(i), key+".", envPrefix+sf.Tag.Get("env"))...)
+			continue
+		}
+		fields = append(fields, field{
+			key:   key,
+			env:   envPrefix + sf.Tag.Get("env"),
+			usage: sf.Tag.Get("usage"),
+			value: v.Field(i),
+		})
+	}
+	return fields
+}
+
+func (f field) set(s string) error {
+	s = strings.TrimSpace(s)
+	v := f.value
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint8, reflect.Uint32:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
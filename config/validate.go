@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Validate reports every invalid value at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.DB.Host != "", "db.host is empty")
+	check(c.DB.Port > 0 && c.DB.Port < 65536, "db.port %d is out of range", c.DB.Port)
+	check(c.DB.User != "", "db.user is empty")
+	check(c.DB.Name != "", "db.name is empty")
+	_, err := time.LoadLocation(c.DB.LegacyTimezone)
+	check(err == nil, "db.legacy_timezone: %v", err)
+
+	check(c.DB.ConnectTimeout > 0, "db.connect_timeout must be positive")
+	check(c.DB.RetryInitial > 0, "db.retry_initial must be positive")
+	check(c.DB.RetryMax >= c.DB.RetryInitial, "db.retry_max is less than retry_initial")
+
+	_, _, err = net.SplitHostPort(c.HTTP.Addr)
+	check(err == nil, "http.addr: %v", err)
+	check(c.HTTP.ReadTimeout >= 0 && c.HTTP.ReadHeaderTimeout >= 0 && c.HTTP.WriteTimeout >= 0 && c.HTTP.IdleTimeout >= 0,
+		"http timeouts must not be negative")
+	check(c.HTTP.ShutdownTimeout > 0, "http.shutdown_timeout must be positive")
+
+	p := c.Auth.Password
+	check(p.MinLength > 0, "auth.password.min_length must be positive")
+	check(p.MaxLength >= p.MinLength, "auth.password.max_length is less than min_length")
+	check(p.MinEntropy >= 0, "auth.password.min_entropy is negative")
+
+	check(c.Auth.AdminToken == "" || len(c.Auth.AdminToken) >= 32, "auth.admin_token is shorter than 32 characters")
+
+	h := c.Auth.Hash
+	check(h.Algorithm == "bcrypt" || h.Algorithm == "argon2id", "auth.hash.algorithm %q is not bcrypt or argon2id", h.Algorithm)
+	check(h.BcryptCost >= 4 && h.BcryptCost <= 31, "auth.hash.bcrypt_cost %d is out of 4..31", h.BcryptCost)
+	check(h.Argon2Memory >= 8*uint32(h.Argon2Threads), "auth.hash.argon2_memory is less than 8 KiB per thread")
+	check(h.Argon2Time > 0, "auth.hash.argon2_time must be positive")
+	check(h.Argon2Threads > 0, "auth.hash.argon2_threads must be positive")
+
+	b := c.Booking
+	check(b.CommentMinLength >= 0, "booking.comment_min_length is negative")
+	check(b.CommentMaxLength >= b.CommentMinLength, "booking.comment_max_length is less than comment_min_length")
+	check(b.MaxDuration >= 0, "booking.max_duration is negative")
+	check(b.ImportMaxBookings > 0, "booking.import_max_bookings must be positive")
+	check(b.ImportHorizon > 0, "booking.import_horizon must be positive")
+	check(b.BatchMaxOperations > 0, "booking.batch_max_operations must be positive")
+
+	l := c.Log
+	check(l.Level == "debug" || l.Level == "info" || l.Level == "warn" || l.Level == "error",
+		"log.level %q is not debug, info, warn or error", l.Level)
+	check(l.Format == "json" || l.Format == "text", "log.format %q is not json or text", l.Format)
+
+	t := c.Tracing
+	check(t.Exporter == "none" || t.Exporter == "stdout" || t.Exporter == "otlp",
+		"tracing.exporter %q is not none, stdout or otlp", t.Exporter)
+	check(t.Exporter != "otlp" || t.OTLPEndpoint != "", "tracing.otlp_endpoint is empty")
+	check(t.SampleRatio >= 0 && t.SampleRatio <= 1, "tracing.sample_ratio %v is out of 0..1", t.SampleRatio)
+
+	r := c.RateLimit
+	for i, p := range []RatePolicy{r.Default, r.Auth, r.Signup, r.AuthFailures} {
+		name := []string{"default", "auth", "signup", "auth_failures"}[i]
+		check(p.Requests > 0, "rate_limit.%s.requests must be positive", name)
+		check(p.Period > 0, "rate_limit.%s.period must be positive", name)
+		check(p.Burst >= 0, "rate_limit.%s.burst is negative", name)
+		check(p.KeyBy == "ip" || p.KeyBy == "client", "rate_limit.%s.key_by %q is not ip or client", name, p.KeyBy)
+	}
+	check(r.AuthFailures.KeyBy == "ip", "rate_limit.auth_failures.key_by must be ip, the client isn't known before authentication")
+
+	_, err = c.API.Sunset()
+	check(err == nil, "api.legacy_sunset %q is not YYYY-MM-DD or RFC 3339", c.API.LegacySunset)
+
+	w := c.Webhooks
+	check(w.Timeout > 0, "webhooks.timeout must be positive")
+	check(w.MaxAttempts > 0, "webhooks.max_attempts must be positive")
+	check(w.RetryInitial > 0, "webhooks.retry_initial must be positive")
+	check(w.RetryMax >= w.RetryInitial, "webhooks.retry_max is less than retry_initial")
+	check(w.PollInterval > 0, "webhooks.poll_interval must be positive")
+	check(w.BatchSize > 0, "webhooks.batch_size must be positive")
+
+	o := c.Outbox
+	for _, sink := range strings.Split(o.Sinks, ",") {
+		sink = strings.TrimSpace(sink)
+		check(sink == "" || sink == "webhook" || sink == "stdout" || sink == "nats" || sink == "kafka",
+			"outbox.sinks: %q is not webhook, stdout, nats or kafka", sink)
+		check(sink != "nats" || (o.NATS.URL != "" && o.NATS.Subject != ""), "outbox.nats.url or subject is empty")
+		check(sink != "kafka" || (o.Kafka.Brokers != "" && o.Kafka.Topic != ""), "outbox.kafka.brokers or topic is empty")
+	}
+	check(o.Timeout > 0, "outbox.timeout must be positive")
+	check(o.PollInterval > 0, "outbox.poll_interval must be positive")
+	check(o.BatchSize > 0, "outbox.batch_size must be positive")
+	check(o.RetryInitial > 0, "outbox.retry_initial must be positive")
+	check(o.RetryMax >= o.RetryInitial, "outbox.retry_max is less than retry_initial")
+	check(o.Retention >= 0, "outbox.retention is negative")
+
+	st := c.Stream
+	check(st.Backend == "postgres" || st.Backend == "memory", "stream.backend must be postgres or memory")
+	check(st.Heartbeat > 0, "stream.heartbeat must be positive")
+	check(st.Buffer > 0, "stream.buffer must be positive")
+
+	check(c.Purge.Retention >= 0, "purge.retention is negative")
+	check(c.Purge.Interval > 0, "purge.interval must be positive")
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,169 @@
+package datavalidator
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// MaxBreachedInMemory is the most lines of a breached password list that isn't sorted by hash,
+// such a list is kept in memory. A sorted list is looked up on disk, it may be of any size.
+const MaxBreachedInMemory = 1 << 20
+
+// breachedLineMax is enough for a line of a sorted list, a digest with a count.
+const breachedLineMax = 128
+
+type breachedList interface {
+	contains(digest [sha1.Size]byte) (bool, error)
+}
+
+// LoadBreachedList opens a breached password list.
+// Each line is either a plain password or a SHA-1 hex digest
+// in the offline Have I Been Pwned format (HASH or HASH:COUNT).
+// A list starting with a digest must have only digests sorted by hash, like the
+// "ordered by hash" download: it's binary searched on disk and stays open.
+// Other lists are read into memory and may have at most MaxBreachedInMemory lines.
+func (p *PasswordPolicy) LoadBreachedList(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	first, err := firstLine(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if _, ok := parseDigest(first); ok {
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return err
+		}
+		p.setBreached(&sortedBreached{file: file, size: info.Size()})
+		return nil
+	}
+	defer file.Close()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	set, err := readBreached(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	p.setBreached(set)
+	return nil
+}
+
+func (p *PasswordPolicy) setBreached(list breachedList) {
+	if sorted, ok := p.breached.(*sortedBreached); ok {
+		sorted.file.Close()
+	}
+	p.breached = list
+}
+
+func firstLine(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			return line, nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// parseDigest parses a line of the Have I Been Pwned format.
+func parseDigest(line string) ([sha1.Size]byte, bool) {
+	var digest [sha1.Size]byte
+	hash, _, _ := strings.Cut(line, ":")
+	if len(hash) != hex.EncodedLen(sha1.Size) {
+		return digest, false
+	}
+	_, err := hex.Decode(digest[:], []byte(hash))
+	return digest, err == nil
+}
+
+// breachedSet is a list read into memory.
+type breachedSet map[[sha1.Size]byte]struct{}
+
+func readBreached(r io.Reader) (breachedSet, error) {
+	set := make(breachedSet)
+	scanner := bufio.NewScanner(r)
+	for lines := 0; scanner.Scan(); {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if lines++; lines > MaxBreachedInMemory {
+			return nil, fmt.Errorf("more than %d passwords, sort the list by hash to look it up on disk", MaxBreachedInMemory)
+		}
+		if digest, ok := parseDigest(line); ok {
+			set[digest] = struct{}{}
+			continue
+		}
+		set[sha1.Sum([]byte(line))] = struct{}{}
+	}
+	return set, scanner.Err()
+}
+
+func (s breachedSet) contains(digest [sha1.Size]byte) (bool, error) {
+	_, ok := s[digest]
+	return ok, nil
+}
+
+// sortedBreached is a list of digests sorted by hash, binary searched on disk.
+type sortedBreached struct {
+	file *os.File
+	size int64
+}
+
+func (s *sortedBreached) contains(digest [sha1.Size]byte) (bool, error) {
+	key := strings.ToUpper(hex.EncodeToString(digest[:]))
+	// the first offset whose line isn't before key, a line belongs to the offsets up to its start
+	lo, hi := int64(0), s.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, err := s.lineAt(mid)
+		if err != nil {
+			return false, err
+		}
+		if line == "" || digestKey(line) >= key {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	line, err := s.lineAt(lo)
+	return line != "" && digestKey(line) == key, err
+}
+
+// lineAt returns the first line starting at off or after it, "" at the end of the file.
+func (s *sortedBreached) lineAt(off int64) (string, error) {
+	start := max(off-1, 0)
+	buf := make([]byte, breachedLineMax*2)
+	n, err := s.file.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	buf = buf[:n]
+	if off > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			return "", nil
+		}
+		buf = buf[i+1:]
+	}
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		buf = buf[:i]
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+func digestKey(line string) string {
+	hash, _, _ := strings.Cut(line, ":")
+	return strings.ToUpper(hash)
+}
@@ -0,0 +1,55 @@
+package datavalidator
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSortedBreachedList(t *testing.T) {
+	var lines []string
+	for i := 0; i < 1000; i++ {
+		digest := sha1.Sum([]byte(fmt.Sprintf("breached %d", i)))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(digest[:])), i+1))
+	}
+	sort.Strings(lines)
+	list := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+	if err := os.WriteFile(list, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy := DefaultPasswordPolicy()
+	if err := policy.LoadBreachedList(list); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := policy.breached.(*sortedBreached); !ok {
+		t.Fatalf("list of digests is loaded as %T, want it looked up on disk", policy.breached)
+	}
+	t.Cleanup(func() { policy.setBreached(nil) })
+
+	for i := 0; i < 1000; i++ {
+		for password, want := range map[string]bool{fmt.Sprintf("breached %d", i): true, fmt.Sprintf("not breached %d", i): false} {
+			got, err := policy.breached.contains(sha1.Sum([]byte(password)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("contains(%q) = %v, want %v", password, got, want)
+			}
+		}
+	}
+	// digests before the first line and after the last one
+	var last [sha1.Size]byte
+	for i := range last {
+		last[i] = 0xff
+	}
+	for _, digest := range [][sha1.Size]byte{{}, last} {
+		if got, _ := policy.breached.contains(digest); got {
+			t.Errorf("contains(%x) = true, want false", digest)
+		}
+	}
+}
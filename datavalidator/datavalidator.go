@@ -4,95 +4,200 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/logging"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+// booking rules, set from the configuration
+var (
+	CommentMinLength = 5
+	CommentMaxLength = 120
+	//0 means unlimited
+	MaxBookingDuration time.Duration
+)
+
+// Error is a validation or request error identified by a message code.
+// The code is looked up in the i18n catalogs when the error is sent to a client.
+type Error struct {
+	Code string
+	Args i18n.Args
+	// Err is the cause, if any, errors.Is and errors.As look into it
+	Err error
+}
+
+func NewError(code string, args i18n.Args) *Error {
+	return &Error{Code: code, Args: args}
+}
+
+// WrapError returns a coded error caused by err.
+func WrapError(err error, code string, args i18n.Args) *Error {
+	return &Error{Code: code, Args: args, Err: err}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Error() string {
+	return i18n.Translate(i18n.DefaultLanguage, e.Code, e.Args)
 }
 
 // swagger:model
 type ResError struct {
+	Code string `json:"code,omitempty" example:"username_banned_symbols"`
 	//exclude = \"\\\/
 	Message string `json:"message" example:"banned symbols in username"`
 }
 
 // swagger:model
 type ResMesOK struct {
+	Code string `json:"code,omitempty" example:"user_deleted"`
 	//exclude = \"\\\/
 	Message string `json:"message" example:"... successfully ..." `
 }
 
+// Problem is an RFC 9457 problem details body, 404 responses are sent as problems.
+//
+// swagger:model
+type Problem struct {
+	Type     string `json:"type" example:"about:blank"`
+	Title    string `json:"title" example:"Not Found"`
+	Status   int    `json:"status" example:"404"`
+	Detail   string `json:"detail" example:"booking wasn't found"`
+	Instance string `json:"instance" example:"/api/v1/booking/1021"`
+	Code     string `json:"code,omitempty" example:"booking_not_found"`
+}
+
 func ResMessage(c *gin.Context, httpStatus int, message string) {
+	resMessage(c, httpStatus, "", message)
+}
+
+// ResMessageCode responds with the message for code in the language negotiated from Accept-Language.
+func ResMessageCode(c *gin.Context, httpStatus int, code string) {
+	ResErr(c, httpStatus, NewError(code, nil))
+}
+
+// ResErr responds with err. Coded errors are translated, others are sent as is.
+func ResErr(c *gin.Context, httpStatus int, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		resMessage(c, httpStatus, "", ErrToString(err))
+		return
+	}
+	tag := i18n.Negotiate(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", tag.String())
+	resMessage(c, httpStatus, e.Code, i18n.Translate(tag, e.Code, e.Args))
+}
+
+func resMessage(c *gin.Context, httpStatus int, code, message string) {
+	if httpStatus == http.StatusNotFound {
+		resProblem(c, httpStatus, code, message)
+		return
+	}
 	var err ResError
+	err.Code = code
 	err.Message = message
 	errorData, e := json.Marshal(err)
 	if e != nil {
-		fmt.Println(e)
+		logging.FromContext(c.Request.Context()).Error("marshal response", "err", e)
 		return
 	}
 	c.Data(httpStatus, "application/json", errorData)
 }
 
+func resProblem(c *gin.Context, httpStatus int, code, message string) {
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(httpStatus),
+		Status:   httpStatus,
+		Detail:   message,
+		Instance: c.Request.URL.Path,
+		Code:     code,
+	}
+	problemData, e := json.Marshal(problem)
+	if e != nil {
+		logging.FromContext(c.Request.Context()).Error("marshal response", "err", e)
+		return
+	}
+	c.Data(httpStatus, "application/problem+json", problemData)
+}
+
 func ErrToString(err error) string {
 	replacer := strings.NewReplacer(`"`, `\"`, `\`, `\\`, `/`, `\/`)
 	return replacer.Replace(fmt.Sprint(err))
 }
 
-func CheckCorrectTimeDuration(t1, t2 string) error {
-	replacer := strings.NewReplacer("T", " ", "Z", "")
-	t1 = replacer.Replace(t1)
-	t2 = replacer.Replace(t2)
-	start_timeP, errS := time.Parse("2006-01-02 15:04:05", t1)
-	if errS != nil {
-		return errors.New("incorrect start_time")
+// ParseTime parses an RFC 3339 time, the offset in it is respected.
+// Times without an offset (YYYY-MM-DD HH:MM:SS or YYYY-MM-DDTHH:MM:SS) are taken in loc.
+func ParseTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
 	}
-
-	end_timeP, errE := time.Parse("2006-01-02 15:04:05", t2)
-	if errE != nil {
-		return errors.New("incorrect end_time")
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", value, loc)
+	if err != nil {
+		t, err = time.ParseInLocation("2006-01-02 15:04:05", value, loc)
 	}
+	return t, err
+}
 
-	if !end_timeP.After(start_timeP) {
-		err := errors.New("incorrect time duration")
-		return err
+// LoadTimezone loads an IANA time zone, an empty name is UTC.
+func LoadTimezone(name string) (*time.Location, error) {
+	// "Local" depends on the server and isn't an IANA name
+	if name == "Local" {
+		return nil, NewError("timezone_incorrect", nil)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, NewError("timezone_incorrect", nil)
 	}
+	return loc, nil
+}
 
+func CheckCorrectTimeDuration(start, end time.Time) error {
+	if !end.After(start) {
+		return NewError("time_duration_incorrect", nil)
+	}
+	if MaxBookingDuration > 0 && end.Sub(start) > MaxBookingDuration {
+		return NewError("time_duration_too_long", i18n.Args{"max": MaxBookingDuration})
+	}
 	return nil
 }
 
+// ValidateUsername checks a username normalized with Normalize.
+// Length is counted in characters, not bytes.
 func ValidateUsername(username string) error {
-	if strings.Contains(username, `"`) || strings.Contains(username, `\`) || strings.Contains(username, `/`) {
-		return errors.New("banned symbols in username")
+	if strings.Contains(username, `"`) || strings.Contains(username, `\`) || strings.Contains(username, `/`) || hasInvisible(username) {
+		return NewError("username_banned_symbols", nil)
+	}
+	if length := utf8.RuneCountInString(username); length > 20 || length < 3 {
+		return NewError("username_length", i18n.Args{"min": 3, "max": 20})
 	}
-	if len(username) > 20 || len(username) < 3 {
-		return errors.New("incorrect username length (3 <= length <= 20)")
+	if mixedScripts(username) {
+		return NewError("username_mixed_scripts", nil)
 	}
-	fmt.Println(username)
 	return nil
 }
 
+// ValidatePassword checks password against Policy.
 func ValidatePassword(password string) error {
-	if strings.Contains(password, `"`) || strings.Contains(password, `\`) || strings.Contains(password, `/`) {
-		return errors.New("banned symbols in password")
-	}
-	if len(password) > 20 || len(password) < 6 {
-		return errors.New("incorrect password length (6 <= length <= 20)")
-	}
-	return nil
+	return Policy.Validate(password)
 }
 
+// ValidateComment checks a comment normalized with Normalize.
+// Length is counted in characters, not bytes.
 func ValidateComment(comment string) error {
 	if strings.Contains(comment, `"`) || strings.Contains(comment, `\`) || strings.Contains(comment, `/`) {
-		return errors.New("banned symbols in comments")
+		return NewError("comment_banned_symbols", nil)
 	}
-	if (len(comment) > 120 || len(comment) < 5) && (len(comment) != 0) {
-		return errors.New("incorrect comment length (5 <= length <= 120)")
+	if length := utf8.RuneCountInString(comment); (length > CommentMaxLength || length < CommentMinLength) && (length != 0) {
+		return NewError("comment_length", i18n.Args{"min": CommentMinLength, "max": CommentMaxLength})
 	}
 	return nil
 }
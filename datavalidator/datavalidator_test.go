@@ -0,0 +1,54 @@
+package datavalidator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/subliker/backendproj/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResErr(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{"coded", NewError("query_param_incorrect", i18n.Args{"name": `"from/to"`}), http.StatusBadRequest,
+			"query_param_incorrect", `"from/to" must be an integer`},
+		{"coded problem", NewError("user_not_found", nil), http.StatusNotFound, "user_not_found", "user wasn't found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.Header.Set("Accept-Language", "en")
+			ResErr(c, tt.status, tt.err)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			var body struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+				Detail  string `json:"detail"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("%v: %s", err, w.Body)
+			}
+			message := body.Message
+			if tt.status == http.StatusNotFound {
+				message = body.Detail
+			}
+			if body.Code != tt.code || message != tt.message {
+				t.Errorf("got %q %q, want %q %q", body.Code, message, tt.code, tt.message)
+			}
+		})
+	}
+}
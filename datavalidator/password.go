@@ -0,0 +1,231 @@
+package datavalidator
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/subliker/backendproj/i18n"
+)
+
+// PasswordPolicy describes which passwords are accepted.
+// Every rule reports its own error code so clients know what to fix.
+type PasswordPolicy struct {
+	//length in characters
+	MinLength int
+	MaxLength int
+	//length in bytes, 0 means no limit. It's passhash.BcryptMaxBytes with bcrypt,
+	//which hashes no more: MaxLength letters outside Latin may not fit
+	MaxBytes int
+	//estimated strength in bits, 0 disables the check
+	MinEntropy float64
+	//known breached passwords, see LoadBreachedList
+	breached breachedList
+}
+
+// Policy is the policy used by ValidatePassword.
+var Policy = DefaultPasswordPolicy()
+
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:  8,
+		MaxLength:  64,
+		MinEntropy: 40,
+	}
+}
+
+func (p *PasswordPolicy) Validate(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return NewError("password_too_short", i18n.Args{"min": p.MinLength})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return NewError("password_too_long", i18n.Args{"max": p.MaxLength})
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		return NewError("password_too_long_bytes", i18n.Args{"max": p.MaxBytes})
+	}
+	if hasInvisible(password) {
+		return NewError("password_invisible_symbols", nil)
+	}
+	if p.MinEntropy > 0 && PasswordEntropy(password) < p.MinEntropy {
+		return NewError("password_too_weak", nil)
+	}
+	if p.breached != nil {
+		breached, err := p.breached.contains(sha1.Sum([]byte(password)))
+		if err != nil {
+			return fmt.Errorf("check breached passwords: %w", err)
+		}
+		if breached {
+			return NewError("password_breached", nil)
+		}
+	}
+	return nil
+}
+
+// PasswordEntropy estimates password strength in bits, roughly how many guesses it takes.
+// A character counts as much as a random one of the character classes the password uses,
+// except for the parts guessers try first, which count only a few bits each: common passwords
+// and words (also in l33t spelling, "P@ssw0rd"), runs of one character, sequences ("abcd", "4321"),
+// keyboard rows ("qwerty") and years. It's a rough estimate, "Password1!" is weak but a long
+// unusual phrase is strong; the breached list catches the known passwords it misses.
+func PasswordEntropy(password string) float64 {
+	runes := []rune(password)
+	// folded is password in lower case with l33t substitutions undone, rune by rune
+	folded := make([]rune, len(runes))
+	for i, r := range runes {
+		folded[i] = unicode.ToLower(r)
+		if plain, ok := leet[r]; ok {
+			folded[i] = plain
+		}
+	}
+	randomBits := math.Log2(float64(characterPool(runes)))
+
+	bits := 0.0
+	for i := 0; i < len(runes); {
+		if n := commonWordAt(folded, i); n > 0 {
+			bits += commonWordBits
+			if string(runes[i:i+n]) != string(folded[i:i+n]) {
+				// capitalized or l33t
+				bits++
+			}
+			i += n
+		} else if n := runAt(folded, i); n > 0 {
+			bits += randomBits + math.Log2(float64(n))
+			i += n
+		} else if n := yearAt(runes, i); n > 0 {
+			bits += yearBits
+			i += n
+		} else {
+			bits += randomBits
+			i++
+		}
+	}
+	return bits
+}
+
+// characterPool returns how many characters the classes runes uses have.
+func characterPool(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r < utf8.RuneSelf && unicode.IsLower(r):
+			lower = true
+		case r < utf8.RuneSelf && unicode.IsUpper(r):
+			upper = true
+		case r < utf8.RuneSelf && unicode.IsDigit(r):
+			digit = true
+		case r < utf8.RuneSelf:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+	return max(pool, 1)
+}
+
+// commonWordBits is what a common password or word counts, about log2(len(commonWords)).
+const commonWordBits = 8
+
+// yearBits is what a year from 1900 to 2099 counts.
+const yearBits = 8
+
+// leet are the l33t substitutions undone before looking for common words.
+var leet = map[rune]rune{'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '@': 'a', '$': 's', '!': 'i'}
+
+// commonWords are the words passwords are most often made of, guessers try them first.
+var commonWords = []string{
+	"password", "passw", "pass", "qwerty", "letmein", "welcome", "admin", "administrator", "login", "master",
+	"dragon", "monkey", "iloveyou", "love", "sunshine", "princess", "football", "baseball", "soccer", "hockey",
+	"shadow", "superman", "batman", "trustno", "secret", "freedom", "whatever", "hello", "charlie", "michael",
+	"jordan", "jennifer", "hunter", "killer", "starwars", "pokemon", "computer", "internet", "summer", "winter",
+	"spring", "autumn", "flower", "cookie", "chocolate", "orange", "banana", "apple", "cheese", "coffee",
+	"money", "google", "yahoo", "user", "guest", "test", "root", "default", "changeme", "access",
+	"ninja", "mustang", "ranger", "thomas", "robert", "daniel", "andrew", "maria", "anna", "alex",
+	"angel", "baby", "buster", "tigger", "ginger", "pepper", "matrix", "silver", "golden", "diamond",
+	"lovely", "family", "friend", "forever", "qazwsx", "zaq", "asdf", "zxcv", "parol", "privet",
+	"booking", "company", "office", "private", "system", "server", "database", "manager", "student", "ilove",
+}
+
+// commonWordAt returns the length of the longest common word at folded[i:], 0 if there's none.
+func commonWordAt(folded []rune, i int) int {
+	longest := 0
+	for _, word := range commonWords {
+		n := utf8.RuneCountInString(word)
+		if n > longest && i+n <= len(folded) && string(folded[i:i+n]) == word {
+			longest = n
+		}
+	}
+	return longest
+}
+
+// sequences are the orders guessers follow, a run goes either way along one of them.
+var sequences = []string{"abcdefghijklmnopqrstuvwxyz", "0123456789", "qwertyuiop", "asdfghjkl", "zxcvbnm", "1qaz2wsx3edc", "абвгдеёжзийклмнопрстуфхцчшщъыьэюя", "йцукенгшщзхъ", "фывапролджэ", "ячсмитьбю"}
+
+// runAt returns the length of the run at folded[i:] of one character repeated or of a sequence
+// going up or down, 0 if it's shorter than 3.
+func runAt(folded []rune, i int) int {
+	longest := 1
+	for j := i + 1; j < len(folded) && folded[j] == folded[i]; j++ {
+		longest = j - i + 1
+	}
+	for _, sequence := range sequences {
+		seq := []rune(sequence)
+		for _, step := range []int{1, -1} {
+			pos := slices.Index(seq, folded[i])
+			if pos < 0 {
+				continue
+			}
+			n := 1
+			for j := i + 1; j < len(folded); j++ {
+				pos += step
+				if pos < 0 || pos >= len(seq) || seq[pos] != folded[j] {
+					break
+				}
+				n++
+			}
+			longest = max(longest, n)
+		}
+	}
+	if longest < 3 {
+		return 0
+	}
+	return longest
+}
+
+// yearAt returns 4 if runes[i:] starts with a year from 1900 to 2099, 0 otherwise.
+func yearAt(runes []rune, i int) int {
+	if i+4 > len(runes) {
+		return 0
+	}
+	year := string(runes[i : i+4])
+	for _, r := range year {
+		if r < '0' || r > '9' {
+			return 0
+		}
+	}
+	if !strings.HasPrefix(year, "19") && !strings.HasPrefix(year, "20") {
+		return 0
+	}
+	return 4
+}
@@ -0,0 +1,83 @@
+package datavalidator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	// "correct horse battery staple" as plain text, "Tr0ub4dor&3 is long enough" as its SHA-1 with a count
+	content := "correct horse battery staple\n\n7B7ECA1E44FC57E2AF0D060781FCADF4FC528C0F:12\n"
+	if err := os.WriteFile(list, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy := DefaultPasswordPolicy()
+	policy.MaxBytes = 72
+	if err := policy.LoadBreachedList(list); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		// code is the error code, "" if the password is accepted
+		code string
+	}{
+		{"too short", "x7#Qp", "password_too_short"},
+		{"too long", strings.Repeat("kQ7#", 17), "password_too_long"},
+		{"fits in characters but not in bytes", strings.Repeat("ж", 40), "password_too_long_bytes"},
+		{"invisible character", "mauve​wombat quietly", "password_invisible_symbols"},
+		{"common word with a digit and a symbol", "Password1!", "password_too_weak"},
+		{"l33t common word and a year", "P@ssw0rd2023", "password_too_weak"},
+		{"keyboard row and digits", "qwertyuiop123", "password_too_weak"},
+		{"one character repeated", "aaaaaaaaaaaa", "password_too_weak"},
+		{"alphabet", "abcdefghijkl", "password_too_weak"},
+		{"digits backwards", "9876543210", "password_too_weak"},
+		{"breached in plain text", "correct horse battery staple", "password_breached"},
+		{"breached as a digest", "Tr0ub4dor&3 is long enough", "password_breached"},
+		{"random characters", "x7#Qp!vT2m", ""},
+		{"passphrase", "mauve wombat quietly juggles", ""},
+		{"cyrillic passphrase", "лиловый вомбат жонглирует", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password)
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var e *Error
+			if !errors.As(err, &e) || e.Code != tt.code {
+				t.Fatalf("Validate() = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		min, max float64
+	}{
+		{"", 0, 0},
+		{"Password1!", 15, 30},
+		{"password", 8, 8},
+		{"Password", 9, 9},
+		{"aaaa", 6, 7},
+		{"x7#Qp!vT2m", 60, 70},
+		{"mauve wombat quietly juggles", 120, 180},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := PasswordEntropy(tt.password); got < tt.min || got > tt.max {
+				t.Errorf("PasswordEntropy(%q) = %.1f, want %v to %v", tt.password, got, tt.min, tt.max)
+			}
+		})
+	}
+}
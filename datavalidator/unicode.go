@@ -0,0 +1,85 @@
+package datavalidator
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps characters that look like Latin letters or digits
+// to their Latin prototype. It covers the Cyrillic and Greek homoglyphs
+// that are usually used to spoof Latin usernames.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p',
+	'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's', 'ԁ': 'd',
+	'һ': 'h', 'ԛ': 'q', 'ԝ': 'w', 'ӏ': 'l', 'ь': 'b',
+	'А': 'a', 'В': 'b', 'Е': 'e', 'К': 'k', 'М': 'm', 'Н': 'h', 'О': 'o', 'Р': 'p',
+	'С': 'c', 'Т': 't', 'У': 'y', 'Х': 'x', 'І': 'l', 'Ј': 'j', 'Ѕ': 's', 'Ӏ': 'l',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x',
+	'Α': 'a', 'Β': 'b', 'Ε': 'e', 'Ζ': 'z', 'Η': 'h', 'Ι': 'l', 'Κ': 'k', 'Μ': 'm',
+	'Ν': 'n', 'Ο': 'o', 'Ρ': 'p', 'Τ': 't', 'Υ': 'y', 'Χ': 'x',
+	// Latin and digits
+	'I': 'l', '1': 'l', '|': 'l', '0': 'o',
+}
+
+var scripts = map[string]*unicode.RangeTable{
+	"Latin":    unicode.Latin,
+	"Cyrillic": unicode.Cyrillic,
+	"Greek":    unicode.Greek,
+}
+
+// Normalize applies NFKC normalization and trims surrounding spaces.
+func Normalize(s string) string {
+	return strings.TrimSpace(norm.NFKC.String(s))
+}
+
+// UsernameSkeleton returns the form two usernames share when they are
+// indistinguishable to a reader: "Andrew", "andrew" and "Аndrew" (Cyrillic А)
+// have the same skeleton.
+func UsernameSkeleton(username string) string {
+	var b strings.Builder
+	for _, r := range Normalize(username) {
+		if p, ok := confusables[r]; ok {
+			r = p
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// mixedScripts reports whether s has letters from more than one script.
+func mixedScripts(s string) bool {
+	found := ""
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		script := "Other"
+		for name, table := range scripts {
+			if unicode.Is(table, r) {
+				script = name
+				break
+			}
+		}
+		if found == "" {
+			found = script
+		} else if found != script {
+			return true
+		}
+	}
+	return false
+}
+
+// hasInvisible reports whether s has control, format (zero-width) or non-ASCII space characters.
+func hasInvisible(s string) bool {
+	for _, r := range s {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || (unicode.IsSpace(r) && r != ' ') {
+			return true
+		}
+	}
+	return false
+}
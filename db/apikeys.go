@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/subliker/backendproj/model"
+)
+
+func (c *DataBase) AddAPIKey(ctx context.Context, key model.APIKey) (_ model.APIKey, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "AddAPIKey", "insert_api_key", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return model.APIKey{}, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowxContext(ctx, `INSERT INTO api_keys (name, prefix, key_hash, scopes, user_id, resource_id, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *`, key.Name, key.Prefix, key.Key_hash, key.Scopes, key.User_id, key.Resource_id, key.Expires_at).StructScan(&key)
+	if err != nil {
+		return model.APIKey{}, http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditAPIKey, key.Id, AuditCreate, nil, key.In(time.UTC)}); err != nil {
+		return model.APIKey{}, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return model.APIKey{}, http.StatusInternalServerError, err
+	}
+	return key, 200, nil
+}
+
+func (c *DataBase) GetAPIKeys(ctx context.Context) (_ []model.APIKey, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetAPIKeys", "select_api_keys")
+	defer end(&err)
+	keys := make([]model.APIKey, 0)
+	err = c.base.SelectContext(ctx, &keys, `SELECT * FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return keys, 200, nil
+}
+
+// GetAPIKeyByHash returns the key with hash, revoked and expired ones too.
+// A missing key is returned as a zero model.APIKey.
+func (c *DataBase) GetAPIKeyByHash(ctx context.Context, hash string) (_ model.APIKey, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetAPIKeyByHash", "select_api_key_by_hash")
+	defer end(&err)
+	var key model.APIKey
+	err = c.base.QueryRowxContext(ctx, `SELECT * FROM api_keys WHERE key_hash=$1`, hash).StructScan(&key)
+	if err == sql.ErrNoRows {
+		return model.APIKey{}, 200, nil
+	} else if err != nil {
+		return model.APIKey{}, http.StatusInternalServerError, err
+	}
+	return key, 200, nil
+}
+
+// RotateAPIKey replaces the hash of a key that isn't revoked, the old key stops working at once.
+func (c *DataBase) RotateAPIKey(ctx context.Context, id int, prefix, hash string) (_ model.APIKey, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "RotateAPIKey", "select_api_key_for_update", "update_api_key_hash", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return model.APIKey{}, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	var before, key model.APIKey
+	err = tx.QueryRowxContext(ctx, `SELECT * FROM api_keys WHERE id=$1 AND revoked_at IS NULL FOR UPDATE`, id).StructScan(&before)
+	if err == sql.ErrNoRows {
+		return model.APIKey{}, http.StatusNotFound, notFound("api_key_not_found")
+	} else if err != nil {
+		return model.APIKey{}, http.StatusInternalServerError, err
+	}
+	err = tx.QueryRowxContext(ctx, `UPDATE api_keys SET prefix=$1, key_hash=$2, rotated_at=now() WHERE id=$3 RETURNING *`, prefix, hash, id).StructScan(&key)
+	if err != nil {
+		return model.APIKey{}, http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditAPIKey, id, AuditRotate, before.In(time.UTC), key.In(time.UTC)}); err != nil {
+		return model.APIKey{}, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return model.APIKey{}, http.StatusInternalServerError, err
+	}
+	return key, 200, nil
+}
+
+func (c *DataBase) RevokeAPIKey(ctx context.Context, id int) (_ HttpCode, err error) {
+	ctx, end := observe(ctx, "RevokeAPIKey", "update_api_key_revoked", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	var key model.APIKey
+	err = tx.QueryRowxContext(ctx, `UPDATE api_keys SET revoked_at=now() WHERE id=$1 AND revoked_at IS NULL RETURNING *`, id).StructScan(&key)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, notFound("api_key_not_found")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	before := key
+	before.Revoked_at = nil
+	if err = addAudit(ctx, tx, change{AuditAPIKey, id, AuditRevoke, before.In(time.UTC), key.In(time.UTC)}); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return 200, nil
+}
+
+// TouchAPIKey records that the key was used. It writes at most once a minute per key.
+func (c *DataBase) TouchAPIKey(ctx context.Context, id int) (err error) {
+	ctx, end := observe(ctx, "TouchAPIKey", "update_api_key_last_used")
+	defer end(&err)
+	_, err = c.base.ExecContext(ctx, `UPDATE api_keys SET last_used_at=now() WHERE id=$1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`, id)
+	return err
+}
@@ -0,0 +1,328 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/subliker/backendproj/audit"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// entities of the audit log
+const (
+	AuditUser            = "user"
+	AuditBooking         = "booking"
+	AuditAPIKey          = "api_key"
+	AuditWebhook         = "webhook"
+	AuditWebhookDelivery = "webhook_delivery"
+	AuditCalendarToken   = "calendar_token"
+	AuditResource        = "resource"
+)
+
+// operations of the audit log
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	//removed for good after the retention of deleted rows
+	AuditPurge     = "purge"
+	AuditRotate    = "rotate"
+	AuditRevoke    = "revoke"
+	AuditRedeliver = "redeliver"
+)
+
+// auditLock is the key of the advisory lock on the end of the hash chain.
+const auditLock = 0x61756469
+
+// auditRedacted are the fields whose values aren't recorded, only that they changed.
+var auditRedacted = map[string]bool{"password": true}
+
+// change is a change to record in the audit log: the row before and after it as JSON
+// objects, before is nil for a created row and after for a removed one.
+type change struct {
+	entity    string
+	id        int
+	operation string
+	before    any
+	after     any
+}
+
+// addAudit records changes in the audit log with the actor of ctx. It must be the last statement
+// before tx commits: the end of the hash chain is locked until tx ends, so a transaction holding
+// the lock never waits for rows another one locked.
+func addAudit(ctx context.Context, tx *sqlx.Tx, changes ...change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditLock); err != nil {
+		return err
+	}
+	var prev string
+	err := tx.GetContext(ctx, &prev, `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	actor := audit.FromContext(ctx)
+	// microseconds are what Postgres keeps, the hash is checked against the stored time
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for _, ch := range changes {
+		entry := model.AuditEntry{
+			Created_at: now,
+			Actor:      actor.Type,
+			Request_id: actor.RequestID,
+			Ip:         actor.IP,
+			Entity:     ch.entity,
+			Entity_id:  ch.id,
+			Operation:  ch.operation,
+			Prev_hash:  prev,
+		}
+		if actor.KeyID != 0 {
+			keyID := actor.KeyID
+			entry.Api_key_id = &keyID
+		}
+		if entry.Diff, err = auditDiff(ch.before, ch.after); err != nil {
+			return err
+		}
+		if err = tx.GetContext(ctx, &entry.Id, `SELECT nextval('audit_log_id_seq')`); err != nil {
+			return err
+		}
+		if entry.Hash, err = auditHash(entry); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO audit_log (id, created_at, actor, api_key_id, request_id, ip, entity, entity_id, operation, diff, prev_hash, hash) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			entry.Id, entry.Created_at, entry.Actor, entry.Api_key_id, entry.Request_id, entry.Ip, entry.Entity, entry.Entity_id, entry.Operation, string(entry.Diff), entry.Prev_hash, entry.Hash)
+		if err != nil {
+			return err
+		}
+		prev = entry.Hash
+	}
+	return nil
+}
+
+// auditValue is a changed field of the audit log.
+type auditValue struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// userAudit is a user as the audit log records it. The password hash, which responses leave out,
+// is kept so a password change is recorded, redacted.
+type userAudit struct {
+	model.User
+	Password string `json:"password,omitempty"`
+}
+
+func auditUser(user model.User) userAudit {
+	return userAudit{user.In(time.UTC), user.Password}
+}
+
+// auditDiff returns the fields that differ between before and after as JSON,
+// a field missing on one side is null there.
+func auditDiff(before, after any) (json.RawMessage, error) {
+	b, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+	diff := map[string]auditValue{}
+	for _, fields := range []map[string]json.RawMessage{b, a} {
+		for name := range fields {
+			value := auditValue{Before: b[name], After: a[name]}
+			if bytes.Equal(value.Before, value.After) {
+				continue
+			}
+			if auditRedacted[name] {
+				value.Before, value.After = redacted(value.Before), redacted(value.After)
+			}
+			diff[name] = value
+		}
+	}
+	return json.Marshal(diff)
+}
+
+// auditFields returns the fields of v as JSON, none when v is nil.
+func auditFields(v any) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if v == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return fields, json.Unmarshal(data, &fields)
+}
+
+func redacted(value json.RawMessage) json.RawMessage {
+	if value == nil || string(value) == "null" || string(value) == `""` {
+		return value
+	}
+	return json.RawMessage(`"[redacted]"`)
+}
+
+// auditHash returns the hex SHA-256 of e with e.Prev_hash and without e.Hash. The diff is hashed
+// in a canonical form, so an entry read back from the JSONB column has the same hash.
+func auditHash(e model.AuditEntry) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(e.Diff))
+	decoder.UseNumber()
+	var diff any
+	if err := decoder.Decode(&diff); err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(diff)
+	if err != nil {
+		return "", err
+	}
+	e.Diff, e.Hash = canonical, ""
+	e.Created_at = e.Created_at.UTC()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditFilter selects entries of the audit log, empty fields select every entry.
+type AuditFilter struct {
+	Entity    string
+	EntityID  int
+	Operation string
+	Actor     string
+	APIKeyID  int
+	RequestID string
+	// From and To bound the time of the entries, [From, To), when they're set
+	From, To time.Time
+	// BeforeID is the id the entries are older than, for the next page
+	BeforeID int64
+	Limit    int
+}
+
+// GetAuditLog returns the entries of filter, newest first.
+func (c *DataBase) GetAuditLog(ctx context.Context, filter AuditFilter) (_ []model.AuditEntry, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetAuditLog", "select_audit_log")
+	defer end(&err)
+	var conds []string
+	var args []any
+	cond := func(format string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(format, len(args)))
+	}
+	if filter.Entity != "" {
+		cond("entity = $%d", filter.Entity)
+	}
+	if filter.EntityID != 0 {
+		cond("entity_id = $%d", filter.EntityID)
+	}
+	if filter.Operation != "" {
+		cond("operation = $%d", filter.Operation)
+	}
+	if filter.Actor != "" {
+		cond("actor = $%d", filter.Actor)
+	}
+	if filter.APIKeyID != 0 {
+		cond("api_key_id = $%d", filter.APIKeyID)
+	}
+	if filter.RequestID != "" {
+		cond("request_id = $%d", filter.RequestID)
+	}
+	if !filter.From.IsZero() {
+		cond("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		cond("created_at < $%d", filter.To)
+	}
+	if filter.BeforeID != 0 {
+		cond("id < $%d", filter.BeforeID)
+	}
+	query := `SELECT * FROM audit_log`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT %d", filter.Limit)
+
+	entries := make([]model.AuditEntry, 0)
+	if err = c.base.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return entries, 200, nil
+}
+
+// GetEntityHistory returns the entries of the entity with id, oldest first.
+// It outlives the entity: the history of a purged booking is kept.
+func (c *DataBase) GetEntityHistory(ctx context.Context, entity string, id int) (_ []model.AuditEntry, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetEntityHistory", "select_audit_log_by_entity")
+	defer end(&err)
+	entries := make([]model.AuditEntry, 0)
+	err = c.base.SelectContext(ctx, &entries, `SELECT * FROM audit_log WHERE entity=$1 AND entity_id=$2 ORDER BY id`, entity, id)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return entries, 200, nil
+}
+
+// AuditVerification is the result of checking the hash chain of the audit log.
+type AuditVerification struct {
+	//entries checked
+	Checked int64 `json:"checked" example:"52311"`
+	Valid   bool  `json:"valid" example:"true"`
+	//the first entry whose hash or previous hash doesn't match, the chain from it on can't be trusted
+	Broken_at *int64 `json:"broken_at,omitempty" example:"1200"`
+	//hash of the newest entry, keep it elsewhere to tell later if entries at the end were removed
+	Last_hash string `json:"last_hash,omitempty" example:"e3b0c44298fc1c149afbf4c8996fb92427ae41e4"`
+}
+
+// VerifyAuditLog recomputes the hash of every entry in order and checks that it's chained to the previous one.
+func (c *DataBase) VerifyAuditLog(ctx context.Context) (_ AuditVerification, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "VerifyAuditLog", "select_audit_log")
+	defer end(&err)
+	rows, err := c.base.QueryxContext(ctx, `SELECT * FROM audit_log ORDER BY id`)
+	if err != nil {
+		return AuditVerification{}, http.StatusInternalServerError, err
+	}
+	defer rows.Close()
+
+	result := AuditVerification{Valid: true}
+	for rows.Next() {
+		var entry model.AuditEntry
+		if err = rows.StructScan(&entry); err != nil {
+			return AuditVerification{}, http.StatusInternalServerError, err
+		}
+		if !result.check(entry) {
+			return result, 200, nil
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return AuditVerification{}, http.StatusInternalServerError, err
+	}
+	return result, 200, nil
+}
+
+// check checks that entry, the one after the entries checked so far, has its hash and is chained
+// to the previous one. It reports false and sets Broken_at when it isn't.
+func (v *AuditVerification) check(entry model.AuditEntry) bool {
+	v.Checked++
+	hash, err := auditHash(entry)
+	if err != nil || hash != entry.Hash || entry.Prev_hash != v.Last_hash {
+		id := entry.Id
+		v.Valid, v.Broken_at = false, &id
+		return false
+	}
+	v.Last_hash = entry.Hash
+	return true
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subliker/backendproj/model"
+)
+
+// auditChain returns n chained entries the way addAudit records them.
+func auditChain(t *testing.T, n int) []model.AuditEntry {
+	t.Helper()
+	created := time.Date(2024, 3, 1, 9, 30, 0, 123456000, time.UTC)
+	entries := make([]model.AuditEntry, 0, n)
+	prev := ""
+	for i := 1; i <= n; i++ {
+		diff, err := auditDiff(map[string]any{"comment": "before", "password": "old"}, map[string]any{"comment": strings.Repeat("x", i), "password": "new"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := model.AuditEntry{
+			Id:         int64(i),
+			Created_at: created.Add(time.Duration(i) * time.Second),
+			Actor:      "api_key",
+			Request_id: "req",
+			Ip:         "10.0.0.1",
+			Entity:     AuditBooking,
+			Entity_id:  i,
+			Operation:  AuditUpdate,
+			Diff:       diff,
+			Prev_hash:  prev,
+		}
+		if entry.Hash, err = auditHash(entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+		prev = entry.Hash
+	}
+	return entries
+}
+
+func verify(entries []model.AuditEntry) AuditVerification {
+	result := AuditVerification{Valid: true}
+	for _, entry := range entries {
+		if !result.check(entry) {
+			break
+		}
+	}
+	return result
+}
+
+func TestAuditChainVerification(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]model.AuditEntry) []model.AuditEntry
+		// broken is the id of the first entry that doesn't match, 0 for a valid chain
+		broken int64
+	}{
+		{"untouched", func(e []model.AuditEntry) []model.AuditEntry { return e }, 0},
+		{"diff read back from JSONB", func(e []model.AuditEntry) []model.AuditEntry {
+			var diff map[string]any
+			_ = json.Unmarshal(e[2].Diff, &diff)
+			e[2].Diff, _ = json.MarshalIndent(diff, "", "    ")
+			return e
+		}, 0},
+		{"time read back in another zone", func(e []model.AuditEntry) []model.AuditEntry {
+			e[1].Created_at = e[1].Created_at.In(time.FixedZone("UTC+3", 3*3600))
+			return e
+		}, 0},
+		{"changed diff", func(e []model.AuditEntry) []model.AuditEntry {
+			e[2].Diff = json.RawMessage(`{"comment":{"before":"before","after":"forged"}}`)
+			return e
+		}, 3},
+		{"changed ip", func(e []model.AuditEntry) []model.AuditEntry {
+			e[1].Ip = "192.168.0.1"
+			return e
+		}, 2},
+		{"rehashed after a change", func(e []model.AuditEntry) []model.AuditEntry {
+			e[1].Operation = AuditDelete
+			e[1].Hash, _ = auditHash(e[1])
+			return e
+		}, 3},
+		{"removed entry", func(e []model.AuditEntry) []model.AuditEntry {
+			return append(e[:2], e[3:]...)
+		}, 4},
+		{"swapped entries", func(e []model.AuditEntry) []model.AuditEntry {
+			e[1], e[2] = e[2], e[1]
+			return e
+		}, 3},
+		{"removed first entry", func(e []model.AuditEntry) []model.AuditEntry {
+			return e[1:]
+		}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := auditChain(t, 5)
+			want := entries[len(entries)-1].Hash
+			result := verify(tt.tamper(entries))
+
+			if tt.broken == 0 {
+				if !result.Valid || result.Broken_at != nil {
+					t.Fatalf("chain is broken at %d, want valid", *result.Broken_at)
+				}
+				if result.Checked != 5 || result.Last_hash != want {
+					t.Errorf("checked %d up to %q, want 5 up to %q", result.Checked, result.Last_hash, want)
+				}
+				return
+			}
+			if result.Valid || result.Broken_at == nil {
+				t.Fatalf("chain is valid, want broken at %d", tt.broken)
+			}
+			if *result.Broken_at != tt.broken {
+				t.Errorf("broken at %d, want %d", *result.Broken_at, tt.broken)
+			}
+		})
+	}
+}
+
+func TestAuditDiffRedactsPasswords(t *testing.T) {
+	diff, err := auditDiff(map[string]any{"username": "ann", "password": "old"}, map[string]any{"username": "ann", "password": "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"password":{"before":"[redacted]","after":"[redacted]"}}`; string(diff) != want {
+		t.Errorf("diff = %s, want %s", diff, want)
+	}
+}
+
+func TestAuditDiffRecordsPasswordChange(t *testing.T) {
+	user := model.User{Id: 5, Username: "ann", Password: "old"}
+	before := auditUser(user)
+	user.Password = "new"
+	diff, err := auditDiff(before, auditUser(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"password":{"before":"[redacted]","after":"[redacted]"}}`; string(diff) != want {
+		t.Errorf("diff = %s, want %s", diff, want)
+	}
+}
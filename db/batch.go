@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/events"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// operations of a booking batch
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// BookingOp is an operation of a batch, Booking is the booking to create, or has the id
+// and user of the booking to update or delete.
+type BookingOp struct {
+	Op      string
+	Booking model.Booking
+	//what an update sets, the other fields keep their value in the database
+	Changes BookingChanges
+}
+
+// BookingChanges are the fields an update sets, nil ones aren't changed.
+type BookingChanges struct {
+	Start_time *time.Time
+	End_time   *time.Time
+	Comment    *string
+}
+
+// merge returns existing with the changes. The times are checked again, existing may have
+// changed since the update was checked against an older copy.
+func (ch BookingChanges) merge(existing model.Booking) (model.Booking, HttpCode, error) {
+	booking := existing
+	if ch.Start_time != nil {
+		booking.Start_time = *ch.Start_time
+	}
+	if ch.End_time != nil {
+		booking.End_time = *ch.End_time
+	}
+	if ch.Comment != nil {
+		booking.Comment = *ch.Comment
+	}
+	if err := dv.CheckCorrectTimeDuration(booking.Start_time, booking.End_time); err != nil {
+		return model.Booking{}, http.StatusBadRequest, err
+	}
+	return booking, 200, nil
+}
+
+// BookingOpResult is the created, updated or deleted booking, or the error of the operation.
+type BookingOpResult struct {
+	Booking  model.Booking
+	HttpCode HttpCode
+	Err      error
+}
+
+// BatchBookings runs ops in order in one transaction, every operation in a savepoint:
+// a failed operation is undone alone and the others go on, so every result is known.
+// Conflicts are checked against the bookings as the earlier operations left them.
+// When atomic is set and an operation fails, nothing is committed, the results tell what
+// would've happened. The users and resources of the bookings are locked like in AddNewBookings.
+// The returned error fails the whole batch, it's set when the database fails.
+func (c *DataBase) BatchBookings(ctx context.Context, ops []BookingOp, atomic bool) (_ []BookingOpResult, committed bool, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "BatchBookings", "lock_users", "lock_resources", "select_booking_overlap", "insert_booking", "select_booking_for_update", "update_booking", "delete_booking", "insert_outbox", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	bookings := make([]model.Booking, len(ops))
+	for i, op := range ops {
+		bookings[i] = op.Booking
+	}
+	if err = lockBookings(ctx, tx, bookings...); err != nil {
+		return nil, false, http.StatusInternalServerError, err
+	}
+
+	results := make([]BookingOpResult, len(ops))
+	var changes []change
+	failed := false
+	for i, op := range ops {
+		if _, err = tx.ExecContext(ctx, `SAVEPOINT batch_op`); err != nil {
+			return nil, false, http.StatusInternalServerError, err
+		}
+		booking, ch, httpCode, opErr := runBookingOp(ctx, tx, op)
+		var coded *dv.Error
+		if opErr != nil && !errors.As(opErr, &coded) {
+			return nil, false, httpCode, opErr
+		}
+		if opErr != nil {
+			failed = true
+			results[i] = BookingOpResult{HttpCode: httpCode, Err: opErr}
+			_, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT batch_op`)
+		} else {
+			results[i] = BookingOpResult{Booking: booking, HttpCode: httpCode}
+			changes = append(changes, ch)
+			_, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT batch_op`)
+		}
+		if err != nil {
+			return nil, false, http.StatusInternalServerError, err
+		}
+	}
+	if atomic && failed {
+		return results, false, 200, nil
+	}
+	// the operations are recorded at the end, see addAudit
+	if err = addAudit(ctx, tx, changes...); err != nil {
+		return nil, false, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, false, http.StatusInternalServerError, err
+	}
+	return results, true, 200, nil
+}
+
+// runBookingOp runs op in tx and returns the booking with the change to record in the audit log.
+func runBookingOp(ctx context.Context, tx *sqlx.Tx, op BookingOp) (model.Booking, change, HttpCode, error) {
+	booking := op.Booking
+	var before *model.Booking
+	var event, operation string
+	switch op.Op {
+	case OpCreate, OpUpdate:
+		if op.Op == OpUpdate {
+			// the changes are merged onto the locked row, so a concurrent update of other fields isn't lost
+			existing, httpCode, err := bookingForUpdate(ctx, tx, booking.Id)
+			if err != nil {
+				return model.Booking{}, change{}, httpCode, err
+			}
+			before = &existing
+			if booking, httpCode, err = op.Changes.merge(existing); err != nil {
+				return model.Booking{}, change{}, httpCode, err
+			}
+		}
+		if httpCode, err := checkOverlap(ctx, tx, booking); err != nil {
+			return model.Booking{}, change{}, httpCode, err
+		}
+		var err error
+		if op.Op == OpCreate {
+			event, operation = events.BookingCreated, AuditCreate
+			var httpCode HttpCode
+			if booking, httpCode, err = insertBooking(ctx, tx, booking); err != nil {
+				return model.Booking{}, change{}, httpCode, err
+			}
+		} else {
+			event, operation = events.BookingUpdated, AuditUpdate
+			err = tx.QueryRowxContext(ctx, `UPDATE bookings SET start_time=$1, end_time=$2, comment=$3, sequence=sequence+1 WHERE id=$4 AND deleted_at IS NULL RETURNING *`, booking.Start_time, booking.End_time, booking.Comment, booking.Id).StructScan(&booking)
+		}
+		if err == sql.ErrNoRows {
+			return model.Booking{}, change{}, http.StatusNotFound, notFound("booking_not_found")
+		} else if err != nil {
+			return model.Booking{}, change{}, http.StatusInternalServerError, err
+		}
+	case OpDelete:
+		event, operation = events.BookingDeleted, AuditDelete
+		err := tx.QueryRowxContext(ctx, `UPDATE bookings SET deleted_at=now() WHERE id=$1 AND deleted_at IS NULL RETURNING *`, booking.Id).StructScan(&booking)
+		if err == sql.ErrNoRows {
+			return model.Booking{}, change{}, http.StatusNotFound, notFound("booking_not_found")
+		} else if err != nil {
+			return model.Booking{}, change{}, http.StatusInternalServerError, err
+		}
+		existing := undeletedBooking(booking)
+		before = &existing
+	default:
+		return model.Booking{}, change{}, http.StatusInternalServerError, fmt.Errorf("unknown booking operation %q", op.Op)
+	}
+	if err := addEvent(ctx, tx, events.AggregateBooking, booking.Id, event, booking.In(time.UTC)); err != nil {
+		return model.Booking{}, change{}, http.StatusInternalServerError, err
+	}
+	ch := change{AuditBooking, booking.Id, operation, nil, booking.In(time.UTC)}
+	if before != nil {
+		ch.before = before.In(time.UTC)
+	}
+	return booking, ch, 200, nil
+}
+
+// GetUsersByIDs returns the users with ids by id, missing and deleted users aren't in the map.
+func (c *DataBase) GetUsersByIDs(ctx context.Context, ids []int) (_ map[int]model.User, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetUsersByIDs", "select_users_by_ids")
+	defer end(&err)
+	var users []model.User
+	if err = c.base.SelectContext(ctx, &users, `SELECT * FROM users WHERE id = ANY($1) AND deleted_at IS NULL`, pq.Array(int64s(ids))); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	byID := make(map[int]model.User, len(users))
+	for _, user := range users {
+		byID[user.Id] = user
+	}
+	return byID, 200, nil
+}
+
+// GetBookingsByIDs returns the bookings with ids by id, missing and deleted bookings aren't in the map.
+func (c *DataBase) GetBookingsByIDs(ctx context.Context, ids []int) (_ map[int]model.Booking, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetBookingsByIDs", "select_bookings_by_ids")
+	defer end(&err)
+	var bookings []model.Booking
+	if err = c.base.SelectContext(ctx, &bookings, `SELECT * FROM bookings WHERE id = ANY($1) AND deleted_at IS NULL`, pq.Array(int64s(ids))); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	byID := make(map[int]model.Booking, len(bookings))
+	for _, booking := range bookings {
+		byID[booking.Id] = booking
+	}
+	return byID, 200, nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/model"
+)
+
+func TestBookingChangesMerge(t *testing.T) {
+	at := func(hour int) *time.Time {
+		t := time.Date(2023, 10, 1, hour, 0, 0, 0, time.UTC)
+		return &t
+	}
+	comment := func(s string) *string { return &s }
+	// existing is the booking when the batch runs, another request changed it after the batch was checked
+	existing := model.Booking{Id: 1, User_id: 5, Start_time: *at(10), End_time: *at(12), Comment: "moved by someone else"}
+
+	tests := []struct {
+		name    string
+		changes BookingChanges
+		want    model.Booking
+		// code is the error code, "" if the changes apply
+		code string
+	}{
+		{"nothing", BookingChanges{}, existing, ""},
+		{"end keeps the other comment", BookingChanges{End_time: at(13)},
+			model.Booking{Id: 1, User_id: 5, Start_time: *at(10), End_time: *at(13), Comment: "moved by someone else"}, ""},
+		{"comment keeps the other times", BookingChanges{Comment: comment("weekly sync")},
+			model.Booking{Id: 1, User_id: 5, Start_time: *at(10), End_time: *at(12), Comment: "weekly sync"}, ""},
+		{"every field", BookingChanges{Start_time: at(8), End_time: at(9), Comment: comment("early")},
+			model.Booking{Id: 1, User_id: 5, Start_time: *at(8), End_time: *at(9), Comment: "early"}, ""},
+		{"start after the other end", BookingChanges{Start_time: at(12)}, model.Booking{}, "time_duration_incorrect"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, httpCode, err := tt.changes.merge(existing)
+			if tt.code != "" {
+				var coded *dv.Error
+				if !errors.As(err, &coded) || coded.Code != tt.code || httpCode != http.StatusBadRequest {
+					t.Fatalf("merge() = %d, %v, want 400 %s", httpCode, err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("merge() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/subliker/backendproj/model"
+)
+
+// GetUserBookings returns the bookings of the user with userID by start time.
+func (c *DataBase) GetUserBookings(ctx context.Context, userID int) (_ []model.Booking, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetUserBookings", "select_user_bookings")
+	defer end(&err)
+	bookings := make([]model.Booking, 0)
+	err = c.base.SelectContext(ctx, &bookings, `SELECT * FROM bookings WHERE user_id=$1 AND deleted_at IS NULL ORDER BY start_time, id`, userID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return bookings, 200, nil
+}
+
+// GetResourceBookings returns the bookings of the resource with resourceID by start time, whoever made them.
+func (c *DataBase) GetResourceBookings(ctx context.Context, resourceID int) (_ []model.Booking, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetResourceBookings", "select_resource_bookings")
+	defer end(&err)
+	bookings := make([]model.Booking, 0)
+	err = c.base.SelectContext(ctx, &bookings, `SELECT * FROM bookings WHERE resource_id=$1 AND deleted_at IS NULL ORDER BY start_time, id`, resourceID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return bookings, 200, nil
+}
+
+// SetCalendarToken replaces the calendar token of the user, the old one stops working at once.
+func (c *DataBase) SetCalendarToken(ctx context.Context, userID int, prefix, hash string) (_ model.CalendarToken, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "SetCalendarToken", "select_calendar_token_for_update", "upsert_calendar_token", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return model.CalendarToken{}, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	ch := change{entity: AuditCalendarToken, id: userID, operation: AuditCreate}
+	var before, token model.CalendarToken
+	err = tx.QueryRowxContext(ctx, `SELECT * FROM calendar_tokens WHERE user_id=$1 FOR UPDATE`, userID).StructScan(&before)
+	if err == nil {
+		ch.operation, ch.before = AuditRotate, before.In(time.UTC)
+	} else if err != sql.ErrNoRows {
+		return model.CalendarToken{}, http.StatusInternalServerError, err
+	}
+	err = tx.QueryRowxContext(ctx, `
+INSERT INTO calendar_tokens (user_id, prefix, token_hash) VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET prefix=EXCLUDED.prefix, token_hash=EXCLUDED.token_hash, created_at=now()
+RETURNING *`, userID, prefix, hash).StructScan(&token)
+	if err != nil {
+		return model.CalendarToken{}, http.StatusInternalServerError, err
+	}
+	ch.after = token.In(time.UTC)
+	if err = addAudit(ctx, tx, ch); err != nil {
+		return model.CalendarToken{}, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return model.CalendarToken{}, http.StatusInternalServerError, err
+	}
+	return token, 200, nil
+}
+
+func (c *DataBase) GetCalendarToken(ctx context.Context, userID int) (_ model.CalendarToken, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetCalendarToken", "select_calendar_token")
+	defer end(&err)
+	var token model.CalendarToken
+	err = c.base.QueryRowxContext(ctx, `SELECT * FROM calendar_tokens WHERE user_id=$1`, userID).StructScan(&token)
+	if err == sql.ErrNoRows {
+		return model.CalendarToken{}, http.StatusNotFound, notFound("calendar_token_not_found")
+	} else if err != nil {
+		return model.CalendarToken{}, http.StatusInternalServerError, err
+	}
+	return token, 200, nil
+}
+
+func (c *DataBase) DeleteCalendarToken(ctx context.Context, userID int) (_ HttpCode, err error) {
+	ctx, end := observe(ctx, "DeleteCalendarToken", "delete_calendar_token", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	var token model.CalendarToken
+	err = tx.QueryRowxContext(ctx, `DELETE FROM calendar_tokens WHERE user_id=$1 RETURNING *`, userID).StructScan(&token)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, notFound("calendar_token_not_found")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditCalendarToken, userID, AuditDelete, token.In(time.UTC), nil}); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return 200, nil
+}
@@ -1,38 +1,48 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
 	"net/http"
-	"os"
+	"net/url"
 	"strconv"
-
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/subliker/backendproj/config"
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/events"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/logging"
 	"github.com/subliker/backendproj/model"
+	"github.com/subliker/backendproj/passhash"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-var schema = `
-CREATE TABLE IF NOT EXISTS users (
-    id SERIAL PRIMARY KEY,
-    username TEXT NOT NULL UNIQUE,
-    password TEXT NOT NULL,
-	created_at TIMESTAMP NOT NULL,
-	updated_at TIMESTAMP NOT NULL
-);
-
-CREATE TABLE IF NOT EXISTS bookings (
-    id SERIAL PRIMARY KEY,
-    user_id INTEGER NOT NULL,
-    start_time TIMESTAMP NOT NULL,
-	end_time TIMESTAMP NOT NULL,
-	comment TEXT
-)`
-
-type httpCode int
+type HttpCode int
+
+// ErrNotFound is matched by errors.Is for every error about a missing user, booking or other row.
+var ErrNotFound = errors.New("not found")
+
+// notFound is the 404 error of a missing row, code is the message for the client.
+func notFound(code string) error {
+	return dv.WrapError(ErrNotFound, code, nil)
+}
+
+// usernameTaken reports whether err violates a unique index of usernames: a concurrent
+// request took the username after the handler checked it was free.
+func usernameTaken(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && strings.HasPrefix(pqErr.Constraint, "users_username_")
+}
 
 type BookingsData struct {
 	Count int             `json:"count"`
@@ -43,66 +53,138 @@ type DataBase struct {
 	base *sqlx.DB
 }
 
-func (c *DataBase) Init() *sqlx.DB {
-	e := godotenv.Load()
-	if e != nil {
-		fmt.Println(e)
+// Init connects to the database and applies migrations.
+// While the database is unreachable it retries with exponential backoff
+// until cfg.ConnectTimeout passes or ctx is done.
+func (c *DataBase) Init(ctx context.Context, cfg config.DB) error {
+	LegacyTimezone = cfg.LegacyTimezone
+	base, err := sqlx.Open("postgres", DSN(cfg))
+	if err != nil {
+		return err
 	}
 
-	db_host := os.Getenv("DB_HOST")
-	db_port := os.Getenv("DB_PORT")
-	db_user := os.Getenv("DB_USER")
-	db_password := os.Getenv("DB_PASSWORD")
-	db_name := os.Getenv("DB_NAME")
+	ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	defer cancel()
+	delay := cfg.RetryInitial
+	for attempt := 1; ; attempt++ {
+		err = base.PingContext(ctx)
+		if err == nil {
+			break
+		}
+		slog.WarnContext(ctx, "database isn't available, retrying", "attempt", attempt, "delay", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			base.Close()
+			return fmt.Errorf("connect to database: %w", err)
+		case <-time.After(delay + time.Duration(rand.Int63n(int64(delay)/2+1))):
+		}
+		delay = min(delay*2, cfg.RetryMax)
+	}
+
+	c.base = base
+	if err = c.Migrate(); err != nil {
+		base.Close()
+		return err
+	}
+	return nil
+}
+
+// DSN returns the connection URL of cfg, values with spaces, quotes or
+// other special characters are escaped.
+func DSN(cfg config.DB) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password.Value()),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:     "/" + cfg.Name,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+func (c *DataBase) Close() error {
+	return c.base.Close()
+}
+
+func (c *DataBase) Ping(ctx context.Context) error {
+	return c.base.PingContext(ctx)
+}
+
+// SQL returns the underlying connection pool.
+func (c *DataBase) SQL() *sql.DB {
+	return c.base.DB
+}
 
-	if db_host == "" {
-		db_host = "localhost"
+// Stats returns connection pool statistics.
+func (c *DataBase) Stats() sql.DBStats {
+	return c.base.Stats()
+}
+
+func (c *DataBase) AddNewUser(ctx context.Context, user model.User) (_ int, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "AddNewUser", "insert_user", "insert_outbox", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return -1, http.StatusInternalServerError, err
 	}
-	if db_port == "" {
-		db_port = "5432"
+	defer tx.Rollback()
+
+	err = tx.QueryRowxContext(ctx, `INSERT INTO users (username, username_skeleton, password, timezone, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *`, user.Username, dv.UsernameSkeleton(user.Username), user.Password, user.Timezone, user.Created_at, user.Updated_at).StructScan(&user)
+	if usernameTaken(err) {
+		return -1, http.StatusBadRequest, dv.NewError("username_exists", nil)
+	} else if err != nil {
+		return -1, http.StatusInternalServerError, err
+	}
+	if err = addEvent(ctx, tx, events.AggregateUser, user.Id, events.UserCreated, userEvent(user)); err != nil {
+		return -1, http.StatusInternalServerError, err
 	}
-	if db_user == "" {
-		db_user = "postgres"
+	if err = addAudit(ctx, tx, change{AuditUser, user.Id, AuditCreate, nil, auditUser(user)}); err != nil {
+		return -1, http.StatusInternalServerError, err
 	}
-	if db_name == "" {
-		db_name = "postgres"
+	if err = tx.Commit(); err != nil {
+		return -1, http.StatusInternalServerError, err
 	}
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", db_host, db_port, db_user, db_password, db_name)
-	fmt.Println(connStr)
-	c.base = sqlx.MustConnect("postgres", connStr)
-	c.base.MustExec(schema)
-	c.base.MustExec("SET timezone = 'Europe/Moscow'")
-	return c.base
+	return user.Id, 200, nil
 }
 
-func (c *DataBase) AddNewUser(user model.User) (int, httpCode, error) {
-	tx := c.base.MustBegin()
-	var user_id int
-	err := tx.QueryRow(`INSERT INTO users (username, password, created_at, updated_at) VALUES ($1, $2, $3, $4) RETURNING id`, user.Username, user.Password, user.Created_at, user.Updated_at).Scan(&user_id)
+// AddNewBooking adds booking, it fails with 409 booking_conflict when it overlaps another booking
+// of its resource. Bookings without a resource may overlap, only batches and imports check them.
+func (c *DataBase) AddNewBooking(ctx context.Context, booking model.Booking) (_ int, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "AddNewBooking", "lock_resources", "select_booking_overlap", "insert_booking", "insert_outbox", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
 	if err != nil {
 		return -1, http.StatusInternalServerError, err
 	}
-	tx.Commit()
-	return user_id, 200, nil
-}
+	defer tx.Rollback()
 
-func (c *DataBase) AddNewBooking(booking model.Booking) (int, httpCode, error) {
-	tx := c.base.MustBegin()
-	var booking_id int
-	err := tx.QueryRow(`INSERT INTO bookings (user_id, start_time, end_time, comment) VALUES ($1, $2, $3, $4) RETURNING id`, booking.User_id, booking.Start_time, booking.End_time, booking.Comment).Scan(&booking_id)
+	if httpCode, err := checkResourceOverlap(ctx, tx, booking); err != nil {
+		return -1, httpCode, err
+	}
+
+	booking, httpCode, err := insertBooking(ctx, tx, booking)
 	if err != nil {
+		return -1, httpCode, err
+	}
+	if err = addEvent(ctx, tx, events.AggregateBooking, booking.Id, events.BookingCreated, booking.In(time.UTC)); err != nil {
+		return -1, http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditBooking, booking.Id, AuditCreate, nil, booking.In(time.UTC)}); err != nil {
 		return -1, http.StatusInternalServerError, err
 	}
-	tx.Commit()
-	return booking_id, 200, nil
+	if err = tx.Commit(); err != nil {
+		return -1, http.StatusInternalServerError, err
+	}
+	return booking.Id, 200, nil
 }
 
-func (c *DataBase) GetUserDataByID(id int) (model.User, httpCode, error) {
-	tx := c.base.MustBegin()
+func (c *DataBase) GetUserDataByID(ctx context.Context, id int) (_ model.User, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetUserDataByID", "select_user_by_id")
+	defer end(&err)
 	var user model.User
-	err := tx.QueryRowx(`SELECT * FROM users WHERE id=$1`, strconv.Itoa(id)).StructScan(&user)
+	err = c.base.QueryRowxContext(ctx, `SELECT * FROM users WHERE id=$1 AND deleted_at IS NULL`, strconv.Itoa(id)).StructScan(&user)
 	if err == sql.ErrNoRows {
-		return model.User{}, 200, nil
+		return model.User{}, http.StatusNotFound, notFound("user_not_found")
 	} else if err != nil {
 		return model.User{}, http.StatusInternalServerError, err
 	} else {
@@ -110,12 +192,27 @@ func (c *DataBase) GetUserDataByID(id int) (model.User, httpCode, error) {
 	}
 }
 
-func (c *DataBase) GetBookingDataByID(id int) (model.Booking, httpCode, error) {
-	tx := c.base.MustBegin()
+// GetUserDataByIDWithDeleted is GetUserDataByID that finds deleted users too.
+func (c *DataBase) GetUserDataByIDWithDeleted(ctx context.Context, id int) (_ model.User, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetUserDataByIDWithDeleted", "select_user_by_id_with_deleted")
+	defer end(&err)
+	var user model.User
+	err = c.base.QueryRowxContext(ctx, `SELECT * FROM users WHERE id=$1`, id).StructScan(&user)
+	if err == sql.ErrNoRows {
+		return model.User{}, http.StatusNotFound, notFound("user_not_found")
+	} else if err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	return user, 200, nil
+}
+
+func (c *DataBase) GetBookingDataByID(ctx context.Context, id int) (_ model.Booking, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetBookingDataByID", "select_booking_by_id")
+	defer end(&err)
 	var booking model.Booking
-	err := tx.QueryRowx(`SELECT * FROM bookings WHERE id=$1`, id).StructScan(&booking)
+	err = c.base.QueryRowxContext(ctx, `SELECT * FROM bookings WHERE id=$1 AND deleted_at IS NULL`, id).StructScan(&booking)
 	if err == sql.ErrNoRows {
-		return model.Booking{}, 200, nil
+		return model.Booking{}, http.StatusNotFound, notFound("booking_not_found")
 	} else if err != nil {
 		return model.Booking{}, http.StatusInternalServerError, err
 	} else {
@@ -123,11 +220,31 @@ func (c *DataBase) GetBookingDataByID(id int) (model.Booking, httpCode, error) {
 	}
 }
 
-func (c *DataBase) GetBookings(limit, page, offset string) (BookingsData, httpCode, error) {
-	tx := c.base.MustBegin()
+// GetBookingDataByIDWithDeleted is GetBookingDataByID that finds deleted bookings too.
+func (c *DataBase) GetBookingDataByIDWithDeleted(ctx context.Context, id int) (_ model.Booking, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetBookingDataByIDWithDeleted", "select_booking_by_id_with_deleted")
+	defer end(&err)
+	var booking model.Booking
+	err = c.base.QueryRowxContext(ctx, `SELECT * FROM bookings WHERE id=$1`, id).StructScan(&booking)
+	if err == sql.ErrNoRows {
+		return model.Booking{}, http.StatusNotFound, notFound("booking_not_found")
+	} else if err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	return booking, 200, nil
+}
+
+// GetBookings returns the bookings by id, deleted ones only when includeDeleted is set.
+func (c *DataBase) GetBookings(ctx context.Context, limit, page, offset string, includeDeleted bool) (_ BookingsData, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetBookings", "count_bookings", "select_bookings")
+	defer end(&err)
 	bookingsData := BookingsData{}
+	where := " WHERE deleted_at IS NULL"
+	if includeDeleted {
+		where = ""
+	}
 	var count int
-	err := tx.QueryRow(`SELECT COUNT(*) as count FROM bookings`).Scan(&count)
+	err = c.base.QueryRowContext(ctx, `SELECT COUNT(*) as count FROM bookings`+where).Scan(&count)
 	if err != nil {
 		return BookingsData{}, http.StatusInternalServerError, err
 	}
@@ -137,93 +254,135 @@ func (c *DataBase) GetBookings(limit, page, offset string) (BookingsData, httpCo
 	if limit != "" && offset != "" {
 		limitI, errL := strconv.Atoi(limit)
 		if errL != nil {
-			return BookingsData{}, http.StatusBadRequest, errL
+			return BookingsData{}, http.StatusBadRequest, dv.NewError("query_param_incorrect", i18n.Args{"name": "limit"})
 		}
 
 		offsetI, errO := strconv.Atoi(offset)
 		if errO != nil {
-			return BookingsData{}, http.StatusBadRequest, errO
+			return BookingsData{}, http.StatusBadRequest, dv.NewError("query_param_incorrect", i18n.Args{"name": "offset"})
 		}
 
-		strQuery = fmt.Sprintf(` SELECT * FROM bookings ORDER BY id LIMIT %d OFFSET %d`, limitI, offsetI)
+		strQuery = fmt.Sprintf(` SELECT * FROM bookings%s ORDER BY id LIMIT %d OFFSET %d`, where, limitI, offsetI)
 	} else if limit != "" && page != "" {
 		limitI, errL := strconv.Atoi(limit)
 		if errL != nil {
-			return BookingsData{}, http.StatusBadRequest, errL
+			return BookingsData{}, http.StatusBadRequest, dv.NewError("query_param_incorrect", i18n.Args{"name": "limit"})
 		}
 
 		pageI, errP := strconv.Atoi(page)
 		if errP != nil {
-			return BookingsData{}, http.StatusBadRequest, errP
+			return BookingsData{}, http.StatusBadRequest, dv.NewError("query_param_incorrect", i18n.Args{"name": "page"})
 		}
 
-		strQuery = fmt.Sprintf(` SELECT * FROM bookings ORDER BY id LIMIT %d OFFSET %d`, limitI, limitI*(pageI-1))
+		strQuery = fmt.Sprintf(` SELECT * FROM bookings%s ORDER BY id LIMIT %d OFFSET %d`, where, limitI, limitI*(pageI-1))
 	} else if limit != "" {
 		limitI, errL := strconv.Atoi(limit)
 		if errL != nil {
-			return BookingsData{}, http.StatusBadRequest, errL
+			return BookingsData{}, http.StatusBadRequest, dv.NewError("query_param_incorrect", i18n.Args{"name": "limit"})
 		}
 
-		strQuery = fmt.Sprintf(` SELECT * FROM bookings ORDER BY id LIMIT %d`, limitI)
+		strQuery = fmt.Sprintf(` SELECT * FROM bookings%s ORDER BY id LIMIT %d`, where, limitI)
 	} else {
-		strQuery = " SELECT * FROM bookings ORDER BY id"
+		strQuery = " SELECT * FROM bookings" + where + " ORDER BY id"
 	}
-	rows, err := tx.Queryx(strQuery)
+	rows, err := c.base.QueryxContext(ctx, strQuery)
 	if err != nil {
 		return BookingsData{}, http.StatusInternalServerError, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var booking model.Booking
-		err := rows.StructScan(&booking)
+		err = rows.StructScan(&booking)
 		if err != nil {
 			return BookingsData{}, http.StatusInternalServerError, err
 		}
 		bookings = append(bookings, booking)
 	}
+	if err = rows.Err(); err != nil {
+		return BookingsData{}, http.StatusInternalServerError, err
+	}
 
 	bookingsData.Rows = bookings
 	return bookingsData, 200, nil
 }
 
-func (c *DataBase) DeleteUserByID(id int) (httpCode, error) {
-	tx := c.base.MustBegin()
+// DeleteUserByID deletes the user and its bookings, they can be restored until they're purged.
+func (c *DataBase) DeleteUserByID(ctx context.Context, id int) (_ HttpCode, err error) {
+	ctx, end := observe(ctx, "DeleteUserByID", "delete_user", "delete_user_bookings", "insert_outbox", "insert_audit")
+	defer end(&err)
 
-	isExists, httpCodeE, errE := c.CheckUserExists(id)
-	if errE != nil {
-		return httpCodeE, errE
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
 	}
-	if !isExists {
-		return http.StatusBadRequest, errors.New("user with this id doesn't exis")
+	defer tx.Rollback()
+
+	var user model.User
+	err = tx.QueryRowxContext(ctx, "UPDATE users SET deleted_at=now() WHERE id=$1 AND deleted_at IS NULL RETURNING *", id).StructScan(&user)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, notFound("user_not_found")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
 	}
 
-	_, err := tx.Exec("DELETE FROM users WHERE id=$1", id)
+	// now() is the same in the whole transaction, RestoreUser restores the bookings deleted at the user's deleted_at
+	var bookings []model.Booking
+	err = tx.SelectContext(ctx, &bookings, "UPDATE bookings SET deleted_at=now() WHERE user_id=$1 AND deleted_at IS NULL RETURNING *", id)
 	if err != nil {
-		return http.StatusBadRequest, err
+		return http.StatusInternalServerError, err
 	}
-
-	_, err = tx.Exec("DELETE FROM bookings WHERE user_id =$1", id)
-	if err != nil && err != sql.ErrNoRows {
+	if err = addEvent(ctx, tx, events.AggregateUser, id, events.UserDeleted, userEvent(user)); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	changes := []change{{AuditUser, id, AuditDelete, auditUser(undeleted(user)), auditUser(user)}}
+	for _, booking := range bookings {
+		changes = append(changes, change{AuditBooking, booking.Id, AuditDelete, undeletedBooking(booking).In(time.UTC), booking.In(time.UTC)})
+	}
+	if err = addAudit(ctx, tx, changes...); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
 		return http.StatusInternalServerError, err
 	}
-	tx.Commit()
 	return 200, nil
 }
 
-func (c *DataBase) DeleteBookingByID(id int) (httpCode, error) {
-	tx := c.base.MustBegin()
-	_, err := tx.Exec("DELETE FROM bookings WHERE id =$1", id)
+// DeleteBookingByID deletes the booking, it can be restored until it's purged.
+func (c *DataBase) DeleteBookingByID(ctx context.Context, id int) (_ HttpCode, err error) {
+	ctx, end := observe(ctx, "DeleteBookingByID", "delete_booking", "insert_outbox", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
 	if err != nil {
-		return http.StatusBadRequest, err
+		return http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	var booking model.Booking
+	err = tx.QueryRowxContext(ctx, "UPDATE bookings SET deleted_at=now() WHERE id=$1 AND deleted_at IS NULL RETURNING *", id).StructScan(&booking)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, notFound("booking_not_found")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = addEvent(ctx, tx, events.AggregateBooking, id, events.BookingDeleted, booking.In(time.UTC)); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditBooking, id, AuditDelete, undeletedBooking(booking).In(time.UTC), booking.In(time.UTC)}); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return http.StatusInternalServerError, err
 	}
-	tx.Commit()
 	return 200, nil
 }
 
-func (c *DataBase) CheckUsernameExists(username string) (bool, httpCode, error) {
-	tx := c.base.MustBegin()
+// CheckUsernameExists reports whether a username that differs from username
+// only by case or homoglyphs is taken.
+func (c *DataBase) CheckUsernameExists(ctx context.Context, username string) (_ bool, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "CheckUsernameExists", "select_username_exists")
+	defer end(&err)
 	var user model.User
-	err := tx.QueryRowx("SELECT * FROM users WHERE username=$1", username).StructScan(&user)
-	tx.Commit()
+	err = c.base.QueryRowxContext(ctx, "SELECT * FROM users WHERE (LOWER(username)=LOWER($1) OR username_skeleton=$2) AND deleted_at IS NULL", username, dv.UsernameSkeleton(username)).StructScan(&user)
 	if err == sql.ErrNoRows {
 		return false, 200, nil
 	} else if err != nil {
@@ -233,11 +392,11 @@ func (c *DataBase) CheckUsernameExists(username string) (bool, httpCode, error)
 	}
 }
 
-func (c *DataBase) CheckUserExists(id int) (bool, httpCode, error) {
-	tx := c.base.MustBegin()
+func (c *DataBase) CheckUserExists(ctx context.Context, id int) (_ bool, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "CheckUserExists", "select_user_exists")
+	defer end(&err)
 	var user model.User
-	err := tx.QueryRowx("SELECT * FROM users WHERE id=$1", id).StructScan(&user)
-	tx.Commit()
+	err = c.base.QueryRowxContext(ctx, "SELECT * FROM users WHERE id=$1 AND deleted_at IS NULL", id).StructScan(&user)
 	if err == sql.ErrNoRows {
 		return false, 200, nil
 	} else if err != nil {
@@ -247,32 +406,479 @@ func (c *DataBase) CheckUserExists(id int) (bool, httpCode, error) {
 	}
 }
 
-func (c *DataBase) UpdateUserData(user model.User) (model.User, httpCode, error) {
-	tx := c.base.MustBegin()
-	_, err := tx.Exec(`UPDATE users SET username=$1, password=$2, updated_at=$3 WHERE id=$4`, user.Username, user.Password, user.Updated_at, user.Id)
+// dummyHash is checked when there's no user to check the password of, so an unknown
+// username takes as long as a wrong password and response times don't tell which usernames exist.
+var dummyHash = sync.OnceValues(func() (string, error) {
+	return passhash.Default.Hash("no such user")
+})
+
+// VerifyUserPassword checks password of the user with username, which differs from the stored one
+// only by case or homoglyphs like in CheckUsernameExists. When userID isn't nil, only the password
+// of that user can be checked, the others fail like a wrong password.
+// A hash made with an outdated algorithm or parameters is replaced
+// with a fresh one after a successful check.
+func (c *DataBase) VerifyUserPassword(ctx context.Context, username, password string, userID *int) (_ model.User, _ bool, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "VerifyUserPassword", "select_user_by_username", "update_user_password", "insert_audit")
+	defer end(&err)
+	var user model.User
+	err = c.base.QueryRowxContext(ctx, "SELECT * FROM users WHERE (LOWER(username)=LOWER($1) OR username_skeleton=$2) AND deleted_at IS NULL", username, dv.UsernameSkeleton(username)).StructScan(&user)
+	if err == sql.ErrNoRows || err == nil && userID != nil && user.Id != *userID {
+		hash, err := dummyHash()
+		if err != nil {
+			return model.User{}, false, http.StatusInternalServerError, err
+		}
+		passhash.Default.Verify(password, hash)
+		return model.User{}, false, 200, nil
+	} else if err != nil {
+		return model.User{}, false, http.StatusInternalServerError, err
+	}
+
+	ok, needsRehash, err := passhash.Default.Verify(password, user.Password)
+	if err != nil {
+		return model.User{}, false, http.StatusInternalServerError, err
+	}
+	if !ok {
+		return model.User{}, false, 200, nil
+	}
+
+	if needsRehash {
+		// the password is right, a failed rehash is tried again on the next check
+		passwordHashed, err := passhash.Default.Hash(password)
+		if err == nil {
+			err = c.rehashUserPassword(ctx, user, passwordHashed)
+		}
+		if err != nil {
+			logging.FromContext(ctx).Warn("rehash password", "user_id", user.Id, "err", err)
+		} else {
+			user.Password = passwordHashed
+		}
+	}
+	return user, true, 200, nil
+}
+
+// rehashUserPassword replaces the password hash of user with passwordHashed,
+// unless the password was changed meanwhile.
+func (c *DataBase) rehashUserPassword(ctx context.Context, user model.User, passwordHashed string) error {
+	tx, err := c.base.BeginTxx(ctx, nil)
 	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the old hash in WHERE keeps a concurrent password change
+	rehashed := user
+	err = tx.QueryRowxContext(ctx, "UPDATE users SET password=$1 WHERE id=$2 AND password=$3 RETURNING *", passwordHashed, user.Id, user.Password).StructScan(&rehashed)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err = addAudit(ctx, tx, change{AuditUser, user.Id, AuditUpdate, auditUser(user), auditUser(rehashed)}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (c *DataBase) UpdateUserData(ctx context.Context, user model.User) (_ model.User, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "UpdateUserData", "select_user_for_update", "update_user", "insert_outbox", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	var before model.User
+	err = tx.QueryRowxContext(ctx, `SELECT * FROM users WHERE id=$1 AND deleted_at IS NULL FOR UPDATE`, user.Id).StructScan(&before)
+	if err == sql.ErrNoRows {
+		return model.User{}, http.StatusNotFound, notFound("user_not_found")
+	} else if err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	err = tx.QueryRowxContext(ctx, `UPDATE users SET username=$1, username_skeleton=$2, password=$3, timezone=$4, updated_at=$5 WHERE id=$6 AND deleted_at IS NULL RETURNING *`, user.Username, dv.UsernameSkeleton(user.Username), user.Password, user.Timezone, user.Updated_at, user.Id).StructScan(&user)
+	if err == sql.ErrNoRows {
+		return model.User{}, http.StatusNotFound, notFound("user_not_found")
+	} else if usernameTaken(err) {
+		return model.User{}, http.StatusBadRequest, dv.NewError("username_exists", nil)
+	} else if err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	if err = addEvent(ctx, tx, events.AggregateUser, user.Id, events.UserUpdated, userEvent(user)); err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditUser, user.Id, AuditUpdate, auditUser(before), auditUser(user)}); err != nil {
 		return model.User{}, http.StatusInternalServerError, err
 	}
+	if err = tx.Commit(); err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	return user, 200, nil
+}
 
-	err = tx.QueryRowx("SELECT * FROM users WHERE id=$1", user.Id).StructScan(&user)
+// UpdateBookingData updates the times and comment of booking, it fails with 409 booking_conflict
+// when the booking would overlap another booking of its resource.
+func (c *DataBase) UpdateBookingData(ctx context.Context, booking model.Booking) (_ model.Booking, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "UpdateBookingData", "select_booking_for_update", "lock_resources", "select_booking_overlap", "update_booking", "insert_outbox", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	before, httpCode, err := bookingForUpdate(ctx, tx, booking.Id)
+	if err != nil {
+		return model.Booking{}, httpCode, err
+	}
+	// a booking never changes its user or resource
+	booking.User_id, booking.Resource_id = before.User_id, before.Resource_id
+	if httpCode, err := checkResourceOverlap(ctx, tx, booking); err != nil {
+		return model.Booking{}, httpCode, err
+	}
+	err = tx.QueryRowxContext(ctx, `UPDATE bookings SET start_time=$1, end_time=$2, comment=$3, sequence=sequence+1 WHERE id=$4 AND deleted_at IS NULL RETURNING *`, booking.Start_time, booking.End_time, booking.Comment, booking.Id).StructScan(&booking)
+	if err == sql.ErrNoRows {
+		return model.Booking{}, http.StatusNotFound, notFound("booking_not_found")
+	} else if err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	if err = addEvent(ctx, tx, events.AggregateBooking, booking.Id, events.BookingUpdated, booking.In(time.UTC)); err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditBooking, booking.Id, AuditUpdate, before.In(time.UTC), booking.In(time.UTC)}); err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	return booking, 200, nil
+}
+
+// CountActiveBookings returns the number of bookings that haven't ended yet.
+func (c *DataBase) CountActiveBookings(ctx context.Context) (count int, err error) {
+	ctx, end := observe(ctx, "CountActiveBookings", "count_active_bookings")
+	defer end(&err)
+	err = c.base.GetContext(ctx, &count, `SELECT COUNT(*) FROM bookings WHERE end_time > now() AND deleted_at IS NULL`)
+	return count, err
+}
+
+// GetUserBookingsBetween returns the bookings of the user with userID that overlap [from, to), by start time.
+func (c *DataBase) GetUserBookingsBetween(ctx context.Context, userID int, from, to time.Time) (_ []model.Booking, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetUserBookingsBetween", "select_user_bookings_between")
+	defer end(&err)
+	bookings := make([]model.Booking, 0)
+	err = c.base.SelectContext(ctx, &bookings, `SELECT * FROM bookings WHERE user_id=$1 AND start_time < $3 AND end_time > $2 AND deleted_at IS NULL ORDER BY start_time, id`, userID, from, to)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return bookings, 200, nil
+}
+
+// GetResourceBookingsBetween returns the bookings of the resource with resourceID that overlap [from, to), by start time.
+func (c *DataBase) GetResourceBookingsBetween(ctx context.Context, resourceID int, from, to time.Time) (_ []model.Booking, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetResourceBookingsBetween", "select_resource_bookings_between")
+	defer end(&err)
+	bookings := make([]model.Booking, 0)
+	err = c.base.SelectContext(ctx, &bookings, `SELECT * FROM bookings WHERE resource_id=$1 AND start_time < $3 AND end_time > $2 AND deleted_at IS NULL ORDER BY start_time, id`, resourceID, from, to)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return bookings, 200, nil
+}
+
+// AddNewBookings adds bookings in one transaction, all of them or none.
+// The users and resources of the bookings are locked, so bookings added meanwhile can't overlap them:
+// a booking that conflicts with an existing booking fails with 409 booking_conflict, see Conflicts.
+// The bookings are returned with their ids in the same order.
+func (c *DataBase) AddNewBookings(ctx context.Context, bookings []model.Booking) (_ []model.Booking, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "AddNewBookings", "lock_users", "lock_resources", "select_booking_overlap", "insert_booking", "insert_outbox", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	if err = lockBookings(ctx, tx, bookings...); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	added := make([]model.Booking, 0, len(bookings))
+	var changes []change
+	for _, booking := range bookings {
+		if httpCode, err := checkOverlap(ctx, tx, booking); err != nil {
+			return nil, httpCode, err
+		}
+
+		booking, httpCode, err := insertBooking(ctx, tx, booking)
+		if err != nil {
+			return nil, httpCode, err
+		}
+		if err = addEvent(ctx, tx, events.AggregateBooking, booking.Id, events.BookingCreated, booking.In(time.UTC)); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		added = append(added, booking)
+		changes = append(changes, change{AuditBooking, booking.Id, AuditCreate, nil, booking.In(time.UTC)})
+	}
+	if err = addAudit(ctx, tx, changes...); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return added, 200, nil
+}
+
+// lockUsers locks the users with ids until tx ends, so bookings of them can't be added or moved meanwhile.
+func lockUsers(ctx context.Context, tx *sqlx.Tx, ids []int) error {
+	// locked in id order, so two transactions for the same users don't deadlock
+	_, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(int64s(ids)))
+	return err
+}
+
+// int64s converts ids for pq.Array.
+func int64s(ids []int) []int64 {
+	converted := make([]int64, len(ids))
+	for i, id := range ids {
+		converted[i] = int64(id)
+	}
+	return converted
+}
+
+// Conflicts reports whether bookings a and b can't both be kept: they overlap and take the same resource,
+// or neither takes one and they're of the same user. checkOverlap finds such bookings in the database.
+func Conflicts(a, b model.Booking) bool {
+	if a.Id != 0 && a.Id == b.Id || !a.Start_time.Before(b.End_time) || !a.End_time.After(b.Start_time) {
+		return false
+	}
+	if a.Resource_id != nil || b.Resource_id != nil {
+		return a.Resource_id != nil && b.Resource_id != nil && *a.Resource_id == *b.Resource_id
+	}
+	return a.User_id == b.User_id
+}
+
+// overlapQuery returns the query of a booking that conflicts with booking, see Conflicts.
+func overlapQuery(booking model.Booking) (string, []any) {
+	if booking.Resource_id != nil {
+		return `SELECT id FROM bookings WHERE resource_id=$1 AND start_time < $3 AND end_time > $2 AND id <> $4 AND deleted_at IS NULL LIMIT 1`,
+			[]any{*booking.Resource_id, booking.Start_time, booking.End_time, booking.Id}
+	}
+	return `SELECT id FROM bookings WHERE user_id=$1 AND resource_id IS NULL AND start_time < $3 AND end_time > $2 AND id <> $4 AND deleted_at IS NULL LIMIT 1`,
+		[]any{booking.User_id, booking.Start_time, booking.End_time, booking.Id}
+}
+
+// checkResourceOverlap locks the resource of booking and checks it like checkOverlap,
+// it checks nothing for a booking without a resource.
+func checkResourceOverlap(ctx context.Context, tx *sqlx.Tx, booking model.Booking) (HttpCode, error) {
+	if booking.Resource_id == nil {
+		return 200, nil
+	}
+	if err := lockResources(ctx, tx, []int{*booking.Resource_id}); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return checkOverlap(ctx, tx, booking)
+}
+
+// checkOverlap fails with 409 booking_conflict when booking conflicts with another booking, see Conflicts.
+// The user or resource of booking must be locked.
+func checkOverlap(ctx context.Context, tx *sqlx.Tx, booking model.Booking) (HttpCode, error) {
+	var overlap int
+	query, args := overlapQuery(booking)
+	err := tx.QueryRowxContext(ctx, query, args...).Scan(&overlap)
+	if err == nil {
+		return http.StatusConflict, dv.NewError("booking_conflict", i18n.Args{"id": overlap})
+	} else if err != sql.ErrNoRows {
+		return http.StatusInternalServerError, err
+	}
+	return 200, nil
+}
+
+// insertBooking adds booking in tx and returns it with its id. It fails with 400 booking_resource_not_exists
+// when the resource was deleted after the booking was checked.
+func insertBooking(ctx context.Context, tx *sqlx.Tx, booking model.Booking) (model.Booking, HttpCode, error) {
+	err := tx.QueryRowxContext(ctx, `INSERT INTO bookings (user_id, resource_id, start_time, end_time, comment) VALUES ($1, $2, $3, $4, $5) RETURNING *`, booking.User_id, booking.Resource_id, booking.Start_time, booking.End_time, booking.Comment).StructScan(&booking)
+	if resourceGone(err) {
+		return model.Booking{}, http.StatusBadRequest, dv.NewError("booking_resource_not_exists", nil)
+	} else if err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	return booking, 200, nil
+}
+
+// RestoreUser restores the deleted user with the bookings deleted with it. It fails with 409
+// user_not_deleted when the user isn't deleted and username_exists when its username is taken again.
+func (c *DataBase) RestoreUser(ctx context.Context, id int) (_ model.User, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "RestoreUser", "select_user_for_update", "select_username_exists", "restore_user", "restore_user_bookings", "insert_outbox", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
 	if err != nil {
 		return model.User{}, http.StatusInternalServerError, err
 	}
-	tx.Commit()
-	return user, 200, err
+	defer tx.Rollback()
+
+	var user model.User
+	err = tx.QueryRowxContext(ctx, `SELECT * FROM users WHERE id=$1 FOR UPDATE`, id).StructScan(&user)
+	if err == sql.ErrNoRows {
+		return model.User{}, http.StatusNotFound, notFound("user_not_found")
+	} else if err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	if user.Deleted_at == nil {
+		return model.User{}, http.StatusConflict, dv.NewError("user_not_deleted", nil)
+	}
+	var taken bool
+	err = tx.GetContext(ctx, &taken, `SELECT EXISTS (SELECT 1 FROM users WHERE (LOWER(username)=LOWER($1) OR username_skeleton=$2) AND deleted_at IS NULL)`, user.Username, user.Username_skeleton)
+	if err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	if taken {
+		return model.User{}, http.StatusConflict, dv.NewError("username_exists", nil)
+	}
+
+	var bookings []model.Booking
+	if err = tx.SelectContext(ctx, &bookings, `UPDATE bookings SET deleted_at=NULL WHERE user_id=$1 AND deleted_at=$2 RETURNING *`, id, user.Deleted_at); err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	before := user
+	err = tx.QueryRowxContext(ctx, `UPDATE users SET deleted_at=NULL WHERE id=$1 RETURNING *`, id).StructScan(&user)
+	if usernameTaken(err) {
+		return model.User{}, http.StatusConflict, dv.NewError("username_exists", nil)
+	} else if err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	if err = addEvent(ctx, tx, events.AggregateUser, id, events.UserRestored, userEvent(user)); err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	changes := []change{{AuditUser, id, AuditRestore, auditUser(before), auditUser(user)}}
+	for _, booking := range bookings {
+		deleted := booking
+		deleted.Deleted_at = before.Deleted_at
+		changes = append(changes, change{AuditBooking, booking.Id, AuditRestore, deleted.In(time.UTC), booking.In(time.UTC)})
+	}
+	if err = addAudit(ctx, tx, changes...); err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return model.User{}, http.StatusInternalServerError, err
+	}
+	return user, 200, nil
 }
 
-func (c *DataBase) UpdateBookingData(booking model.Booking) (model.Booking, httpCode, error) {
-	tx := c.base.MustBegin()
-	_, err := tx.Exec(`UPDATE bookings SET start_time=$1, end_time=$2, comment=$3 WHERE id=$4`, booking.Start_time, booking.End_time, booking.Comment, booking.Id)
+// RestoreBooking restores the deleted booking. It fails with 409 booking_not_deleted when the booking
+// isn't deleted, booking_user_deleted when its user is deleted and booking_conflict when it overlaps
+// a booking made since. A booking whose resource was deleted is restored without it.
+func (c *DataBase) RestoreBooking(ctx context.Context, id int) (_ model.Booking, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "RestoreBooking", "select_booking_by_id_with_deleted", "lock_users", "lock_resources", "select_booking_overlap", "restore_booking", "insert_outbox", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
 	if err != nil {
 		return model.Booking{}, http.StatusInternalServerError, err
 	}
+	defer tx.Rollback()
 
-	err = tx.QueryRowx("SELECT * FROM bookings WHERE id=$1", booking.Id).StructScan(&booking)
+	var booking model.Booking
+	err = tx.QueryRowxContext(ctx, `SELECT * FROM bookings WHERE id=$1`, id).StructScan(&booking)
+	if err == sql.ErrNoRows {
+		return model.Booking{}, http.StatusNotFound, notFound("booking_not_found")
+	} else if err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	// the user and resource are locked first like everywhere else, then the booking is read again under the lock
+	if err = lockBookings(ctx, tx, booking); err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	var userDeleted bool
+	err = tx.QueryRowxContext(ctx, `SELECT deleted_at IS NOT NULL FROM users WHERE id=$1`, booking.User_id).Scan(&userDeleted)
+	if err != nil && err != sql.ErrNoRows {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	if err == sql.ErrNoRows || userDeleted {
+		return model.Booking{}, http.StatusConflict, dv.NewError("booking_user_deleted", nil)
+	}
+	err = tx.QueryRowxContext(ctx, `SELECT * FROM bookings WHERE id=$1 FOR UPDATE`, id).StructScan(&booking)
+	if err == sql.ErrNoRows {
+		return model.Booking{}, http.StatusNotFound, notFound("booking_not_found")
+	} else if err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	if booking.Deleted_at == nil {
+		return model.Booking{}, http.StatusConflict, dv.NewError("booking_not_deleted", nil)
+	}
+	if httpCode, err := checkOverlap(ctx, tx, booking); err != nil {
+		return model.Booking{}, httpCode, err
+	}
+
+	// calendar apps take the booking back as an update
+	before := booking
+	err = tx.QueryRowxContext(ctx, `UPDATE bookings SET deleted_at=NULL, sequence=sequence+1 WHERE id=$1 RETURNING *`, id).StructScan(&booking)
 	if err != nil {
 		return model.Booking{}, http.StatusInternalServerError, err
 	}
-	tx.Commit()
-	return booking, 200, err
+	if err = addEvent(ctx, tx, events.AggregateBooking, id, events.BookingRestored, booking.In(time.UTC)); err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditBooking, id, AuditRestore, before.In(time.UTC), booking.In(time.UTC)}); err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	return booking, 200, nil
+}
+
+// PurgeDeleted removes users and bookings deleted before before for good,
+// with the bookings of the removed users. Their events were sent when they were deleted.
+func (c *DataBase) PurgeDeleted(ctx context.Context, before time.Time) (bookings, users int64, err error) {
+	ctx, end := observe(ctx, "PurgeDeleted", "purge_bookings", "purge_users", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var purgedBookings []model.Booking
+	err = tx.SelectContext(ctx, &purgedBookings, `DELETE FROM bookings WHERE deleted_at < $1 OR user_id IN (SELECT id FROM users WHERE deleted_at < $1) RETURNING *`, before)
+	if err != nil {
+		return 0, 0, err
+	}
+	var purgedUsers []model.User
+	err = tx.SelectContext(ctx, &purgedUsers, `DELETE FROM users WHERE deleted_at < $1 RETURNING *`, before)
+	if err != nil {
+		return 0, 0, err
+	}
+	var changes []change
+	for _, booking := range purgedBookings {
+		changes = append(changes, change{AuditBooking, booking.Id, AuditPurge, booking.In(time.UTC), nil})
+	}
+	for _, user := range purgedUsers {
+		changes = append(changes, change{AuditUser, user.Id, AuditPurge, auditUser(user), nil})
+	}
+	if err = addAudit(ctx, tx, changes...); err != nil {
+		return 0, 0, err
+	}
+	return int64(len(purgedBookings)), int64(len(purgedUsers)), tx.Commit()
+}
+
+// bookingForUpdate returns the booking with id that isn't deleted and locks it until tx ends.
+func bookingForUpdate(ctx context.Context, tx *sqlx.Tx, id int) (model.Booking, HttpCode, error) {
+	var booking model.Booking
+	err := tx.QueryRowxContext(ctx, `SELECT * FROM bookings WHERE id=$1 AND deleted_at IS NULL FOR UPDATE`, id).StructScan(&booking)
+	if err == sql.ErrNoRows {
+		return model.Booking{}, http.StatusNotFound, notFound("booking_not_found")
+	} else if err != nil {
+		return model.Booking{}, http.StatusInternalServerError, err
+	}
+	return booking, 200, nil
+}
+
+// undeleted returns the user as it was before it was deleted.
+func undeleted(user model.User) model.User {
+	user.Deleted_at = nil
+	return user
+}
+
+// undeletedBooking returns the booking as it was before it was deleted.
+func undeletedBooking(booking model.Booking) model.Booking {
+	booking.Deleted_at = nil
+	return booking
 }
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subliker/backendproj/config"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/lib/pq"
+)
+
+func TestDSN(t *testing.T) {
+	cfg := config.DB{Host: "db.local", Port: 5433, User: "app user", Password: `p@ss w/o'rd" sslmode=disable`, Name: "bookings", SSLMode: "verify-full"}
+	u, err := url.Parse(DSN(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, _ := u.User.Password()
+	if u.Scheme != "postgres" || u.Host != "db.local:5433" || u.Path != "/bookings" {
+		t.Errorf("scheme %q, host %q, path %q", u.Scheme, u.Host, u.Path)
+	}
+	if u.User.Username() != cfg.User || password != cfg.Password.Value() {
+		t.Errorf("user %q with password %q, want %q with %q", u.User.Username(), password, cfg.User, cfg.Password.Value())
+	}
+	if q := u.Query(); len(q) != 1 || q.Get("sslmode") != "verify-full" {
+		t.Errorf("query %v, want only sslmode=verify-full", q)
+	}
+}
+
+func TestUsernameTaken(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no error", nil, false},
+		{"lower username index", &pq.Error{Code: "23505", Constraint: "users_username_lower_key"}, true},
+		{"skeleton index", &pq.Error{Code: "23505", Constraint: "users_username_skeleton_key"}, true},
+		{"wrapped", fmt.Errorf("insert: %w", &pq.Error{Code: "23505", Constraint: "users_username_lower_key"}), true},
+		{"other unique index", &pq.Error{Code: "23505", Constraint: "api_keys_key_hash_key"}, false},
+		{"other violation", &pq.Error{Code: "23503", Constraint: "users_username_lower_key"}, false},
+		{"other error", errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usernameTaken(tt.err); got != tt.want {
+				t.Errorf("usernameTaken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	room, desk := 3, 4
+	start := time.Date(2023, 10, 1, 9, 0, 0, 0, time.UTC)
+	booking := func(id, user int, resource *int, from, to int) model.Booking {
+		return model.Booking{Id: id, User_id: user, Resource_id: resource, Start_time: start.Add(time.Duration(from) * time.Hour), End_time: start.Add(time.Duration(to) * time.Hour)}
+	}
+	tests := []struct {
+		name string
+		a, b model.Booking
+		want bool
+	}{
+		{"same resource", booking(1, 5, &room, 0, 2), booking(2, 6, &room, 1, 3), true},
+		{"same resource one after the other", booking(1, 5, &room, 0, 2), booking(2, 6, &room, 2, 3), false},
+		{"same user on two resources", booking(1, 5, &room, 0, 2), booking(2, 5, &desk, 1, 3), false},
+		{"same user with and without a resource", booking(1, 5, &room, 0, 2), booking(2, 5, nil, 1, 3), false},
+		{"same user without resources", booking(1, 5, nil, 0, 2), booking(2, 5, nil, 1, 3), true},
+		{"other users without resources", booking(1, 5, nil, 0, 2), booking(2, 6, nil, 1, 3), false},
+		{"the booking itself", booking(1, 5, &room, 0, 2), booking(1, 5, &room, 0, 2), false},
+		{"a new booking", booking(0, 5, nil, 0, 2), booking(0, 5, nil, 1, 3), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Conflicts(tt.a, tt.b); got != tt.want {
+				t.Errorf("Conflicts() = %v, want %v", got, tt.want)
+			}
+			if got := Conflicts(tt.b, tt.a); got != tt.want {
+				t.Errorf("Conflicts() swapped = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverlapQuery(t *testing.T) {
+	room := 3
+	query, args := overlapQuery(model.Booking{Id: 1, User_id: 5, Resource_id: &room})
+	if !strings.Contains(query, "resource_id=$1") || strings.Contains(query, "user_id") || args[0] != room {
+		t.Errorf("query of a booking of a resource is %q with %v, want it by resource only", query, args)
+	}
+	query, args = overlapQuery(model.Booking{Id: 1, User_id: 5})
+	if !strings.Contains(query, "user_id=$1 AND resource_id IS NULL") || args[0] != 5 {
+		t.Errorf("query of a booking without a resource is %q with %v, want it by user", query, args)
+	}
+}
+
+func TestCheckResourceOverlapWithoutResource(t *testing.T) {
+	start := time.Date(2023, 10, 1, 9, 0, 0, 0, time.UTC)
+	// a single create or update of a booking without a resource doesn't query, the nil transaction would panic
+	httpCode, err := checkResourceOverlap(context.Background(), nil, model.Booking{User_id: 5, Start_time: start, End_time: start.Add(time.Hour)})
+	if httpCode != 200 || err != nil {
+		t.Errorf("checkResourceOverlap() = %d, %v, want 200 without checking", httpCode, err)
+	}
+}
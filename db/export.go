@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/subliker/backendproj/model"
+)
+
+// ExportFilter selects the rows of an export.
+type ExportFilter struct {
+	// Limit is 0 for every row
+	Limit, Offset int
+	// UserID is 0 for the bookings of every user, it's ignored for users
+	UserID int
+	// From and To bound the start time of bookings or the creation time of users, [From, To), when they're set
+	From, To time.Time
+	// IncludeDeleted exports deleted rows too
+	IncludeDeleted bool
+}
+
+// where returns the WHERE and LIMIT clauses of f with their arguments, timeColumn is the column From and To bound.
+func (f ExportFilter) where(timeColumn string, withUser bool) (string, []any) {
+	var conds []string
+	var args []any
+	cond := func(format string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(format, len(args)))
+	}
+	if !f.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if withUser && f.UserID != 0 {
+		cond("user_id = $%d", f.UserID)
+	}
+	if !f.From.IsZero() {
+		cond(timeColumn+" >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		cond(timeColumn+" < $%d", f.To)
+	}
+	var clause string
+	if len(conds) > 0 {
+		clause = " WHERE " + strings.Join(conds, " AND ")
+	}
+	clause += " ORDER BY id"
+	if f.Limit > 0 {
+		clause += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+	if f.Offset > 0 {
+		clause += fmt.Sprintf(" OFFSET %d", f.Offset)
+	}
+	return clause, args
+}
+
+// ExportBookings calls row with every booking of filter by id. Rows are read from the
+// connection one by one as row takes them, an error of row stops the export and is returned.
+func (c *DataBase) ExportBookings(ctx context.Context, filter ExportFilter, row func(model.Booking) error) (_ HttpCode, err error) {
+	ctx, end := observe(ctx, "ExportBookings", "select_bookings_export")
+	defer end(&err)
+	where, args := filter.where("start_time", true)
+	rows, err := c.base.QueryxContext(ctx, `SELECT * FROM bookings`+where, args...)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var booking model.Booking
+		if err = rows.StructScan(&booking); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if err = row(booking); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return 200, nil
+}
+
+// ExportUsers calls row with every user of filter by id, like ExportBookings.
+// Password hashes aren't read.
+func (c *DataBase) ExportUsers(ctx context.Context, filter ExportFilter, row func(model.User) error) (_ HttpCode, err error) {
+	ctx, end := observe(ctx, "ExportUsers", "select_users_export")
+	defer end(&err)
+	where, args := filter.where("created_at", false)
+	rows, err := c.base.QueryxContext(ctx, `SELECT id, username, timezone, created_at, updated_at, deleted_at FROM users`+where, args...)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var user model.User
+		if err = rows.StructScan(&user); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if err = row(user); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return 200, nil
+}
@@ -0,0 +1,319 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// LegacyTimezone is the zone the TIMESTAMP columns were written in
+// before they were migrated to TIMESTAMPTZ.
+var LegacyTimezone = "Europe/Moscow"
+
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sqlx.Tx) error
+}
+
+// migrations are applied in order, each one in its own transaction.
+// Applied versions are recorded in schema_migrations. Never change an applied migration, add a new one.
+var migrations = []migration{
+	{1, "initial schema", execMigration(`
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    username TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS bookings (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+	end_time TIMESTAMP NOT NULL,
+	comment TEXT
+)`)},
+	// uniqueness of usernames ignores case and homoglyphs
+	{2, "username skeleton", func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS username_skeleton TEXT`)
+		if err != nil {
+			return err
+		}
+		var users []model.User
+		err = tx.Select(&users, `SELECT id, username FROM users WHERE username_skeleton IS NULL`)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			_, err := tx.Exec(`UPDATE users SET username_skeleton=$1 WHERE id=$2`, dv.UsernameSkeleton(user.Username), user.Id)
+			if err != nil {
+				return err
+			}
+		}
+		// the indexes can't be created over duplicates, which ones to rename is for an admin to decide
+		users = nil
+		if err = tx.Select(&users, `SELECT id, username, username_skeleton FROM users ORDER BY id`); err != nil {
+			return err
+		}
+		if err = duplicateUsernames(users); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_lower_key ON users (LOWER(username));
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_skeleton_key ON users (username_skeleton)`)
+		return err
+	}},
+	{3, "timestamptz and user timezone", func(tx *sqlx.Tx) error {
+		if _, err := time.LoadLocation(LegacyTimezone); err != nil {
+			return err
+		}
+		zone := pq.QuoteLiteral(LegacyTimezone)
+		_, err := tx.Exec(fmt.Sprintf(`
+ALTER TABLE users
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE %[1]s,
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE %[1]s,
+	ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE bookings
+	ALTER COLUMN start_time TYPE TIMESTAMPTZ USING start_time AT TIME ZONE %[1]s,
+	ALTER COLUMN end_time TYPE TIMESTAMPTZ USING end_time AT TIME ZONE %[1]s;
+
+UPDATE users SET timezone = %[1]s`, zone))
+		return err
+	}},
+	// a resource can't be booked twice at once
+	{4, "resources", execMigration(`
+CREATE TABLE IF NOT EXISTS resources (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	timezone TEXT NOT NULL DEFAULT 'UTC',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS resource_id INTEGER REFERENCES resources (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS bookings_resource_time ON bookings (resource_id, start_time) WHERE resource_id IS NOT NULL`)},
+	{5, "api keys", execMigration(`
+CREATE TABLE IF NOT EXISTS api_keys (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT[] NOT NULL DEFAULT '{}',
+	user_id INTEGER REFERENCES users (id) ON DELETE CASCADE,
+	resource_id INTEGER REFERENCES resources (id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	rotated_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ
+)`)},
+	{6, "webhooks", execMigration(`
+CREATE TABLE IF NOT EXISTS webhooks (
+	id SERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT[] NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT true,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id BIGSERIAL PRIMARY KEY,
+	webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+	event_id TEXT NOT NULL,
+	event TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_status_code INTEGER,
+	last_error TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id)`)},
+	// events are written in the transaction of the change and published by the relay
+	{7, "outbox", execMigration(`
+CREATE TABLE IF NOT EXISTS outbox (
+	id BIGSERIAL PRIMARY KEY,
+	aggregate_type TEXT NOT NULL,
+	aggregate_id TEXT NOT NULL,
+	event_id TEXT NOT NULL UNIQUE,
+	event_type TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_error TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	published_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_unpublished ON outbox (aggregate_type, aggregate_id, id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_published ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+-- the outbox relay publishes at least once, an event is enqueued once per webhook anyway
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event ON webhook_deliveries (webhook_id, event_id)`)},
+	// calendar apps take an event with a greater SEQUENCE as an update
+	{8, "calendar feeds", execMigration(`
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS sequence INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+	user_id INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+	prefix TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`)},
+	// deleted users and bookings are kept until they're purged, a deleted user's username can be taken again.
+	// Deleted bookings don't take their resource.
+	{9, "soft delete", execMigration(`
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+DROP INDEX IF EXISTS users_username_lower_key;
+DROP INDEX IF EXISTS users_username_skeleton_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_lower_key ON users (LOWER(username)) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_skeleton_key ON users (username_skeleton) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS bookings_deleted_at ON bookings (deleted_at) WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS bookings_resource_time;
+CREATE INDEX IF NOT EXISTS bookings_resource_time ON bookings (resource_id, start_time) WHERE resource_id IS NOT NULL AND deleted_at IS NULL`)},
+	// every change is recorded with who made it, entries are chained by hash and can't be changed or removed
+	{10, "audit log", execMigration(`
+CREATE TABLE IF NOT EXISTS audit_log (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL,
+	actor TEXT NOT NULL,
+	api_key_id INTEGER,
+	request_id TEXT NOT NULL DEFAULT '',
+	ip TEXT NOT NULL DEFAULT '',
+	entity TEXT NOT NULL,
+	entity_id BIGINT NOT NULL,
+	operation TEXT NOT NULL,
+	diff JSONB NOT NULL,
+	prev_hash TEXT NOT NULL,
+	hash TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity ON audit_log (entity, entity_id, id);
+CREATE INDEX IF NOT EXISTS audit_log_created_at ON audit_log (created_at);
+CREATE INDEX IF NOT EXISTS audit_log_request ON audit_log (request_id) WHERE request_id <> '';
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only()`)},
+}
+
+// maxDuplicatesReported is how many groups of duplicate usernames duplicateUsernames lists.
+const maxDuplicatesReported = 20
+
+// duplicateUsernames fails with the users whose usernames differ only by case or homoglyphs,
+// grouped by their skeleton. Users created before usernames were unique in that sense may be such.
+func duplicateUsernames(users []model.User) error {
+	groups := map[string][]model.User{}
+	var skeletons []string
+	for _, user := range users {
+		if len(groups[user.Username_skeleton]) == 1 {
+			skeletons = append(skeletons, user.Username_skeleton)
+		}
+		groups[user.Username_skeleton] = append(groups[user.Username_skeleton], user)
+	}
+	if len(skeletons) == 0 {
+		return nil
+	}
+	var report []string
+	for _, skeleton := range skeletons[:min(len(skeletons), maxDuplicatesReported)] {
+		var names []string
+		for _, user := range groups[skeleton] {
+			names = append(names, fmt.Sprintf("%d %q", user.Id, user.Username))
+		}
+		report = append(report, strings.Join(names, ", "))
+	}
+	if len(skeletons) > maxDuplicatesReported {
+		report = append(report, fmt.Sprintf("and %d more", len(skeletons)-maxDuplicatesReported))
+	}
+	return fmt.Errorf("usernames that differ only by case or look-alike letters, rename all but one of each (id \"username\"): %s",
+		strings.Join(report, "; "))
+}
+
+func execMigration(query string) func(tx *sqlx.Tx) error {
+	return func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// Migrate applies migrations that aren't recorded in schema_migrations yet.
+func (c *DataBase) Migrate() error {
+	_, err := c.base.Exec(`
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		tx, err := c.base.Beginx()
+		if err != nil {
+			return err
+		}
+		// the lock serializes instances that start at the same time
+		if _, err := tx.Exec(`LOCK TABLE schema_migrations IN EXCLUSIVE MODE`); err != nil {
+			tx.Rollback()
+			return err
+		}
+		var applied bool
+		err = tx.Get(&applied, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version=$1)`, m.version)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if applied {
+			tx.Rollback()
+			continue
+		}
+		if err := m.up(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the latest applied migration and the latest one this build has.
+func (c *DataBase) SchemaVersion(ctx context.Context) (applied, expected int, err error) {
+	err = c.base.GetContext(ctx, &applied, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`)
+	return applied, migrations[len(migrations)-1].version, err
+}
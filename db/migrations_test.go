@@ -0,0 +1,59 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/model"
+)
+
+func TestDuplicateUsernames(t *testing.T) {
+	tests := []struct {
+		name      string
+		usernames []string
+		// report has what the error lists, empty when there are no duplicates
+		report []string
+	}{
+		{"unique", []string{"Andrew", "Maria", "Ivan"}, nil},
+		{"case", []string{"Andrew", "Maria", "andrew"}, []string{`1 "Andrew", 3 "andrew"`}},
+		{"homoglyph", []string{"Аndrew", "Andrew"}, []string{`1 "Аndrew", 2 "Andrew"`}},
+		{"several groups", []string{"maria", "ANDREW", "Maria", "andrew", "MARia"}, []string{`1 "maria", 3 "Maria", 5 "MARia"`, `2 "ANDREW", 4 "andrew"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var users []model.User
+			for i, username := range tt.usernames {
+				users = append(users, model.User{Id: i + 1, Username: username, Username_skeleton: dv.UsernameSkeleton(username)})
+			}
+			err := duplicateUsernames(users)
+			if len(tt.report) == 0 {
+				if err != nil {
+					t.Fatalf("error %v, want none", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("no error, want the duplicates")
+			}
+			if want := strings.Join(tt.report, "; "); !strings.HasSuffix(err.Error(), ": "+want) {
+				t.Errorf("error %q, want it to list %s", err, want)
+			}
+		})
+	}
+}
+
+func TestDuplicateUsernamesReportIsCut(t *testing.T) {
+	var users []model.User
+	for i := 0; i < 2*(maxDuplicatesReported+3); i++ {
+		username := "user" + strings.Repeat("x", i/2)
+		if i%2 == 1 {
+			username = strings.ToUpper(username)
+		}
+		users = append(users, model.User{Id: i + 1, Username: username, Username_skeleton: dv.UsernameSkeleton(username)})
+	}
+	err := duplicateUsernames(users)
+	if err == nil || !strings.HasSuffix(err.Error(), "; and 3 more") {
+		t.Errorf("error %v, want it cut after %d groups", err, maxDuplicatesReported)
+	}
+}
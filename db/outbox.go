@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/subliker/backendproj/events"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// OutboxChannel is the channel notified with the id of every outbox message when it's committed.
+const OutboxChannel = "outbox"
+
+// addEvent writes the event about data to the outbox in tx,
+// so it's published if and only if tx commits.
+func addEvent(ctx context.Context, tx *sqlx.Tx, aggregateType string, aggregateID int, eventType string, data any) error {
+	e, err := events.New(eventType, data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// the notification is sent when tx commits, and not at all if it's rolled back
+	_, err = tx.ExecContext(ctx, `
+WITH msg AS (
+	INSERT INTO outbox (aggregate_type, aggregate_id, event_id, event_type, payload) VALUES ($1, $2, $3, $4, $5::jsonb) RETURNING id
+)
+SELECT pg_notify($6, id::text) FROM msg`,
+		aggregateType, strconv.Itoa(aggregateID), e.ID, e.Type, string(payload), OutboxChannel)
+	return err
+}
+
+// userEvent is user as events carry it.
+func userEvent(user model.User) model.User {
+	return user.In(time.UTC)
+}
+
+// ClaimOutbox returns up to limit unpublished messages that are due and postpones them until leaseUntil,
+// so other instances don't publish them at the same time. Only the oldest unpublished message
+// of each aggregate is returned: later ones wait until it's published, which keeps them in order.
+func (c *DataBase) ClaimOutbox(ctx context.Context, limit int, leaseUntil time.Time) (_ []model.OutboxMessage, err error) {
+	ctx, end := observe(ctx, "ClaimOutbox", "claim_outbox")
+	defer end(&err)
+	messages := make([]model.OutboxMessage, 0)
+	err = c.base.SelectContext(ctx, &messages, `
+UPDATE outbox SET next_attempt_at = $2
+WHERE id IN (
+	SELECT o.id FROM outbox o
+	WHERE o.published_at IS NULL AND o.next_attempt_at <= now()
+		AND NOT EXISTS (
+			SELECT 1 FROM outbox earlier
+			WHERE earlier.aggregate_type = o.aggregate_type AND earlier.aggregate_id = o.aggregate_id
+				AND earlier.published_at IS NULL AND earlier.id < o.id
+		)
+	ORDER BY o.id
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *`, limit, leaseUntil)
+	return messages, err
+}
+
+func (c *DataBase) MarkOutboxPublished(ctx context.Context, id int64) (err error) {
+	ctx, end := observe(ctx, "MarkOutboxPublished", "update_outbox_published")
+	defer end(&err)
+	_, err = c.base.ExecContext(ctx, `UPDATE outbox SET published_at=now(), attempts=attempts+1, last_error=NULL WHERE id=$1`, id)
+	return err
+}
+
+// MarkOutboxFailed records a failed attempt, the message is retried at next.
+func (c *DataBase) MarkOutboxFailed(ctx context.Context, id int64, reason string, next time.Time) (err error) {
+	ctx, end := observe(ctx, "MarkOutboxFailed", "update_outbox_failed")
+	defer end(&err)
+	_, err = c.base.ExecContext(ctx, `UPDATE outbox SET attempts=attempts+1, last_error=$1, next_attempt_at=$2 WHERE id=$3`, reason, next, id)
+	return err
+}
+
+// PurgeOutbox deletes messages published before before and returns how many.
+func (c *DataBase) PurgeOutbox(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, end := observe(ctx, "PurgeOutbox", "delete_outbox_published")
+	defer end(&err)
+	res, err := c.base.ExecContext(ctx, `DELETE FROM outbox WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CountUnpublished returns the number of messages waiting in the outbox.
+func (c *DataBase) CountUnpublished(ctx context.Context) (count int, err error) {
+	ctx, end := observe(ctx, "CountUnpublished", "count_outbox_unpublished")
+	defer end(&err)
+	err = c.base.GetContext(ctx, &count, `SELECT COUNT(*) FROM outbox WHERE published_at IS NULL`)
+	return count, err
+}
+
+// GetOutboxAfter returns up to limit messages with ids greater than afterID in order, published or not.
+func (c *DataBase) GetOutboxAfter(ctx context.Context, afterID int64, limit int) (_ []model.OutboxMessage, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetOutboxAfter", "select_outbox_after")
+	defer end(&err)
+	messages := make([]model.OutboxMessage, 0)
+	err = c.base.SelectContext(ctx, &messages, `SELECT * FROM outbox WHERE id > $1 ORDER BY id LIMIT $2`, afterID, limit)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return messages, 200, nil
+}
+
+// GetOutboxByIDs returns the messages with ids in order, missing ones are skipped.
+func (c *DataBase) GetOutboxByIDs(ctx context.Context, ids []int64) (_ []model.OutboxMessage, err error) {
+	ctx, end := observe(ctx, "GetOutboxByIDs", "select_outbox_by_ids")
+	defer end(&err)
+	messages := make([]model.OutboxMessage, 0)
+	err = c.base.SelectContext(ctx, &messages, `SELECT * FROM outbox WHERE id = ANY($1) ORDER BY id`, pq.Array(ids))
+	return messages, err
+}
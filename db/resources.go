@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func (c *DataBase) AddResource(ctx context.Context, resource model.Resource) (_ model.Resource, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "AddResource", "insert_resource", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return model.Resource{}, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowxContext(ctx, `INSERT INTO resources (name, timezone) VALUES ($1, $2) RETURNING *`, resource.Name, resource.Timezone).StructScan(&resource)
+	if err != nil {
+		return model.Resource{}, http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditResource, resource.Id, AuditCreate, nil, resource.In(time.UTC)}); err != nil {
+		return model.Resource{}, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return model.Resource{}, http.StatusInternalServerError, err
+	}
+	return resource, 200, nil
+}
+
+func (c *DataBase) GetResources(ctx context.Context) (_ []model.Resource, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetResources", "select_resources")
+	defer end(&err)
+	resources := make([]model.Resource, 0)
+	err = c.base.SelectContext(ctx, &resources, `SELECT * FROM resources ORDER BY id`)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return resources, 200, nil
+}
+
+func (c *DataBase) GetResourceByID(ctx context.Context, id int) (_ model.Resource, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetResourceByID", "select_resource_by_id")
+	defer end(&err)
+	var resource model.Resource
+	err = c.base.QueryRowxContext(ctx, `SELECT * FROM resources WHERE id=$1`, id).StructScan(&resource)
+	if err == sql.ErrNoRows {
+		return model.Resource{}, http.StatusNotFound, notFound("resource_not_found")
+	} else if err != nil {
+		return model.Resource{}, http.StatusInternalServerError, err
+	}
+	return resource, 200, nil
+}
+
+// GetResourcesByIDs returns the resources with ids by id, missing resources aren't in the map.
+func (c *DataBase) GetResourcesByIDs(ctx context.Context, ids []int) (_ map[int]model.Resource, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetResourcesByIDs", "select_resources_by_ids")
+	defer end(&err)
+	var resources []model.Resource
+	if err = c.base.SelectContext(ctx, &resources, `SELECT * FROM resources WHERE id = ANY($1)`, pq.Array(int64s(ids))); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	byID := make(map[int]model.Resource, len(resources))
+	for _, resource := range resources {
+		byID[resource.Id] = resource
+	}
+	return byID, 200, nil
+}
+
+// UpdateResource saves the name and time zone of resource. The times of its bookings
+// are kept, only times given without an offset later are taken in the new zone.
+func (c *DataBase) UpdateResource(ctx context.Context, resource model.Resource) (_ model.Resource, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "UpdateResource", "select_resource_for_update", "update_resource", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return model.Resource{}, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	var before model.Resource
+	err = tx.QueryRowxContext(ctx, `SELECT * FROM resources WHERE id=$1 FOR UPDATE`, resource.Id).StructScan(&before)
+	if err == sql.ErrNoRows {
+		return model.Resource{}, http.StatusNotFound, notFound("resource_not_found")
+	} else if err != nil {
+		return model.Resource{}, http.StatusInternalServerError, err
+	}
+	err = tx.QueryRowxContext(ctx, `UPDATE resources SET name=$1, timezone=$2, updated_at=now() WHERE id=$3 RETURNING *`, resource.Name, resource.Timezone, resource.Id).StructScan(&resource)
+	if err != nil {
+		return model.Resource{}, http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditResource, resource.Id, AuditUpdate, before.In(time.UTC), resource.In(time.UTC)}); err != nil {
+		return model.Resource{}, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return model.Resource{}, http.StatusInternalServerError, err
+	}
+	return resource, 200, nil
+}
+
+// DeleteResource deletes the resource, it fails with 409 resource_in_use while a booking
+// that isn't deleted takes it. Deleted bookings of it lose their resource_id.
+func (c *DataBase) DeleteResource(ctx context.Context, id int) (_ HttpCode, err error) {
+	ctx, end := observe(ctx, "DeleteResource", "lock_resources", "select_resource_booking", "delete_resource", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	// locked like bookings of it lock it, so none is added meanwhile
+	if err = lockResources(ctx, tx, []int{id}); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	var booking int
+	err = tx.QueryRowxContext(ctx, `SELECT id FROM bookings WHERE resource_id=$1 AND deleted_at IS NULL LIMIT 1`, id).Scan(&booking)
+	if err == nil {
+		return http.StatusConflict, dv.NewError("resource_in_use", i18n.Args{"id": booking})
+	} else if err != sql.ErrNoRows {
+		return http.StatusInternalServerError, err
+	}
+
+	var resource model.Resource
+	err = tx.QueryRowxContext(ctx, `DELETE FROM resources WHERE id=$1 RETURNING *`, id).StructScan(&resource)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, notFound("resource_not_found")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditResource, id, AuditDelete, resource.In(time.UTC), nil}); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return 200, nil
+}
+
+// lockResources locks the resources with ids until tx ends, so bookings of them can't be added or moved meanwhile.
+// Resources are locked after users, in id order like them.
+func lockResources(ctx context.Context, tx *sqlx.Tx, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `SELECT id FROM resources WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(int64s(ids)))
+	return err
+}
+
+// bookingLocks returns the users and resources of bookings to lock.
+func bookingLocks(bookings ...model.Booking) (users, resources []int) {
+	for _, booking := range bookings {
+		users = append(users, booking.User_id)
+		if booking.Resource_id != nil {
+			resources = append(resources, *booking.Resource_id)
+		}
+	}
+	return users, resources
+}
+
+// lockBookings locks the users and resources of bookings, see lockUsers and lockResources.
+func lockBookings(ctx context.Context, tx *sqlx.Tx, bookings ...model.Booking) error {
+	users, resources := bookingLocks(bookings...)
+	if err := lockUsers(ctx, tx, users); err != nil {
+		return err
+	}
+	return lockResources(ctx, tx, resources)
+}
+
+// resourceGone reports whether err is the foreign key violation of a booking whose
+// resource was deleted after the handler found it.
+func resourceGone(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503" && pqErr.Constraint == "bookings_resource_id_fkey"
+}
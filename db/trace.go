@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/metrics"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/subliker/backendproj/db")
+
+// observe starts the span and the duration metric of a DataBase method.
+// statements are the names of the SQL statements the method may run.
+// The returned func ends both, it records *err in the span unless it's
+// a validation error or sql.ErrNoRows:
+//
+//	ctx, end := observe(ctx, "GetUserDataByID", "select_user_by_id")
+//	defer end(&err)
+func observe(ctx context.Context, method string, statements ...string) (context.Context, func(err *error)) {
+	done := metrics.ObserveQuery(method)
+	ctx, span := tracer.Start(ctx, "db."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(method),
+			attribute.StringSlice("db.statement.names", statements),
+		))
+	return ctx, func(err *error) {
+		var coded *dv.Error
+		if e := *err; e != nil && !errors.Is(e, sql.ErrNoRows) && !errors.As(e, &coded) {
+			span.RecordError(e)
+			span.SetStatus(codes.Error, e.Error())
+		}
+		span.End()
+		done()
+	}
+}
@@ -0,0 +1,228 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/subliker/backendproj/model"
+)
+
+func (c *DataBase) AddWebhook(ctx context.Context, webhook model.Webhook) (_ model.Webhook, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "AddWebhook", "insert_webhook", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return model.Webhook{}, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowxContext(ctx, `INSERT INTO webhooks (url, secret, events, active) VALUES ($1, $2, $3, $4) RETURNING *`, webhook.Url, webhook.Secret, webhook.Events, webhook.Active).StructScan(&webhook)
+	if err != nil {
+		return model.Webhook{}, http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditWebhook, webhook.Id, AuditCreate, nil, webhook.In(time.UTC)}); err != nil {
+		return model.Webhook{}, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return model.Webhook{}, http.StatusInternalServerError, err
+	}
+	return webhook, 200, nil
+}
+
+func (c *DataBase) GetWebhooks(ctx context.Context) (_ []model.Webhook, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetWebhooks", "select_webhooks")
+	defer end(&err)
+	webhooks := make([]model.Webhook, 0)
+	err = c.base.SelectContext(ctx, &webhooks, `SELECT * FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return webhooks, 200, nil
+}
+
+func (c *DataBase) GetWebhookByID(ctx context.Context, id int) (_ model.Webhook, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetWebhookByID", "select_webhook_by_id")
+	defer end(&err)
+	var webhook model.Webhook
+	err = c.base.QueryRowxContext(ctx, `SELECT * FROM webhooks WHERE id=$1`, id).StructScan(&webhook)
+	if err == sql.ErrNoRows {
+		return model.Webhook{}, http.StatusNotFound, notFound("webhook_not_found")
+	} else if err != nil {
+		return model.Webhook{}, http.StatusInternalServerError, err
+	}
+	return webhook, 200, nil
+}
+
+// UpdateWebhook saves the url, events and active flag of webhook, the secret is kept.
+func (c *DataBase) UpdateWebhook(ctx context.Context, webhook model.Webhook) (_ model.Webhook, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "UpdateWebhook", "select_webhook_for_update", "update_webhook", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return model.Webhook{}, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	var before model.Webhook
+	err = tx.QueryRowxContext(ctx, `SELECT * FROM webhooks WHERE id=$1 FOR UPDATE`, webhook.Id).StructScan(&before)
+	if err == sql.ErrNoRows {
+		return model.Webhook{}, http.StatusNotFound, notFound("webhook_not_found")
+	} else if err != nil {
+		return model.Webhook{}, http.StatusInternalServerError, err
+	}
+	err = tx.QueryRowxContext(ctx, `UPDATE webhooks SET url=$1, events=$2, active=$3, updated_at=now() WHERE id=$4 RETURNING *`, webhook.Url, webhook.Events, webhook.Active, webhook.Id).StructScan(&webhook)
+	if err != nil {
+		return model.Webhook{}, http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditWebhook, webhook.Id, AuditUpdate, before.In(time.UTC), webhook.In(time.UTC)}); err != nil {
+		return model.Webhook{}, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return model.Webhook{}, http.StatusInternalServerError, err
+	}
+	return webhook, 200, nil
+}
+
+// DeleteWebhook deletes the webhook with its deliveries.
+func (c *DataBase) DeleteWebhook(ctx context.Context, id int) (_ HttpCode, err error) {
+	ctx, end := observe(ctx, "DeleteWebhook", "delete_webhook", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	var webhook model.Webhook
+	err = tx.QueryRowxContext(ctx, `DELETE FROM webhooks WHERE id=$1 RETURNING *`, id).StructScan(&webhook)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, notFound("webhook_not_found")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = addAudit(ctx, tx, change{AuditWebhook, id, AuditDelete, webhook.In(time.UTC), nil}); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return 200, nil
+}
+
+// EnqueueWebhookDeliveries adds a pending delivery of the event for every active webhook subscribed to it
+// that doesn't have one yet, and returns how many were added.
+func (c *DataBase) EnqueueWebhookDeliveries(ctx context.Context, eventID, event string, payload []byte) (_ int64, err error) {
+	ctx, end := observe(ctx, "EnqueueWebhookDeliveries", "insert_webhook_deliveries")
+	defer end(&err)
+	res, err := c.base.ExecContext(ctx, `
+INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+SELECT id, $1, $2, $3::jsonb FROM webhooks WHERE active AND $2 = ANY(events)
+ON CONFLICT (webhook_id, event_id) DO NOTHING`, eventID, event, string(payload))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DueDelivery is a pending delivery with the webhook it goes to.
+type DueDelivery struct {
+	model.WebhookDelivery
+	Url    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries that are due and postpones them until leaseUntil,
+// so other instances don't send them at the same time. A claimed delivery that isn't marked
+// delivered or failed before leaseUntil is sent again.
+func (c *DataBase) ClaimWebhookDeliveries(ctx context.Context, limit int, leaseUntil time.Time) (_ []DueDelivery, err error) {
+	ctx, end := observe(ctx, "ClaimWebhookDeliveries", "claim_webhook_deliveries")
+	defer end(&err)
+	deliveries := make([]DueDelivery, 0)
+	err = c.base.SelectContext(ctx, &deliveries, `
+WITH due AS (
+	UPDATE webhook_deliveries SET next_attempt_at = $2
+	WHERE id IN (
+		SELECT id FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= now()
+		ORDER BY next_attempt_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING *
+)
+SELECT due.*, webhooks.url, webhooks.secret FROM due JOIN webhooks ON webhooks.id = due.webhook_id ORDER BY due.id`, limit, leaseUntil)
+	return deliveries, err
+}
+
+func (c *DataBase) MarkWebhookDelivered(ctx context.Context, id int64, statusCode int) (err error) {
+	ctx, end := observe(ctx, "MarkWebhookDelivered", "update_webhook_delivery_delivered")
+	defer end(&err)
+	_, err = c.base.ExecContext(ctx, `UPDATE webhook_deliveries SET status='delivered', attempts=attempts+1, last_status_code=$1, last_error=NULL, delivered_at=now() WHERE id=$2`, statusCode, id)
+	return err
+}
+
+// MarkWebhookFailed records a failed attempt. The delivery is retried at next,
+// or becomes dead when dead is set. statusCode is 0 when there was no response.
+func (c *DataBase) MarkWebhookFailed(ctx context.Context, id int64, statusCode int, reason string, next time.Time, dead bool) (err error) {
+	ctx, end := observe(ctx, "MarkWebhookFailed", "update_webhook_delivery_failed")
+	defer end(&err)
+	status := model.DeliveryPending
+	if dead {
+		status = model.DeliveryDead
+	}
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+	_, err = c.base.ExecContext(ctx, `UPDATE webhook_deliveries SET status=$1, attempts=attempts+1, last_status_code=$2, last_error=$3, next_attempt_at=$4 WHERE id=$5`, status, code, reason, next, id)
+	return err
+}
+
+// GetWebhookDeliveries returns the latest deliveries, newest first.
+// webhookID 0 means every webhook, status "" means every status.
+func (c *DataBase) GetWebhookDeliveries(ctx context.Context, webhookID int, status string, limit int) (_ []model.WebhookDelivery, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "GetWebhookDeliveries", "select_webhook_deliveries")
+	defer end(&err)
+	deliveries := make([]model.WebhookDelivery, 0)
+	err = c.base.SelectContext(ctx, &deliveries, `
+SELECT * FROM webhook_deliveries
+WHERE ($1 = 0 OR webhook_id = $1) AND ($2 = '' OR status = $2)
+ORDER BY id DESC LIMIT $3`, webhookID, status, limit)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return deliveries, 200, nil
+}
+
+// RedeliverWebhookDelivery makes a delivery pending again with fresh attempts, it's sent at once.
+func (c *DataBase) RedeliverWebhookDelivery(ctx context.Context, id int64) (_ model.WebhookDelivery, _ HttpCode, err error) {
+	ctx, end := observe(ctx, "RedeliverWebhookDelivery", "select_webhook_delivery_for_update", "update_webhook_delivery_redeliver", "insert_audit")
+	defer end(&err)
+	tx, err := c.base.BeginTxx(ctx, nil)
+	if err != nil {
+		return model.WebhookDelivery{}, http.StatusInternalServerError, err
+	}
+	defer tx.Rollback()
+
+	var before, delivery model.WebhookDelivery
+	err = tx.QueryRowxContext(ctx, `SELECT * FROM webhook_deliveries WHERE id=$1 FOR UPDATE`, id).StructScan(&before)
+	if err == sql.ErrNoRows {
+		return model.WebhookDelivery{}, http.StatusNotFound, notFound("webhook_delivery_not_found")
+	} else if err != nil {
+		return model.WebhookDelivery{}, http.StatusInternalServerError, err
+	}
+	err = tx.QueryRowxContext(ctx, `UPDATE webhook_deliveries SET status='pending', attempts=0, next_attempt_at=now() WHERE id=$1 RETURNING *`, id).StructScan(&delivery)
+	if err != nil {
+		return model.WebhookDelivery{}, http.StatusInternalServerError, err
+	}
+	// the payload doesn't change, the diff shows the status and attempts
+	if err = addAudit(ctx, tx, change{AuditWebhookDelivery, int(id), AuditRedeliver, before.In(time.UTC), delivery.In(time.UTC)}); err != nil {
+		return model.WebhookDelivery{}, http.StatusInternalServerError, err
+	}
+	if err = tx.Commit(); err != nil {
+		return model.WebhookDelivery{}, http.StatusInternalServerError, err
+	}
+	return delivery, 200, nil
+}
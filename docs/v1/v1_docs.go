@@ -0,0 +1,3546 @@
+// Package v1 Code generated by swaggo/swag. DO NOT EDIT
+package v1
+
+import "github.com/swaggo/swag"
+
+const docTemplatev1 = `{
+    "schemes": {{ marshal .Schemes }},
+    "swagger": "2.0",
+    "info": {
+        "description": "{{escape .Description}}",
+        "title": "{{.Title}}",
+        "contact": {},
+        "version": "{{.Version}}"
+    },
+    "host": "{{.Host}}",
+    "basePath": "{{.BasePath}}",
+    "paths": {
+        "/admin/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Newest first, status=dead lists the dead letters: deliveries that ran out of attempts and wait for a redelivery. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List deliveries of all webhooks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "pending, delivered or dead",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "limit (default 50, at most 500)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/deliveries/{id}/redeliver": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Makes a delivered or dead delivery pending with all its attempts, it's sent within a few seconds. The body and event id are the same, the signature is new. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Send a delivery again",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "delivery id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.WebhookDelivery"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Revoked and expired keys are listed too. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List API keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.APIKey"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The key is returned only in this response, store it. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Create an API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name (1 \u003c= length \u003c= 64)",
+                        "name": "name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma separated scopes: users:read, users:write, bookings:read, bookings:write, admin",
+                        "name": "scopes",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "restrict the key to this user and their bookings",
+                        "name": "user_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "restrict the key to this resource and its bookings, with user_id to the user's bookings of it",
+                        "name": "resource_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time the key stops working at",
+                        "name": "expires_at",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/route.NewAPIKey"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The key stops working at once and can't be rotated anymore. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Revoke an API key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "API key id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResMesOK"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys/{id}/rotate": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replaces the key keeping its name, scopes, user and expiry. The old key stops working at once. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Rotate an API key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "API key id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/route.NewAPIKey"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List webhooks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Webhook"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Events are POSTed to the URL as JSON signed with the secret, see the README. The secret is returned only in this response. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Subscribe a URL to events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "http or https URL",
+                        "name": "url",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma separated events: booking.created, booking.updated, booking.deleted, booking.restored, user.created, user.updated, user.deleted, user.restored",
+                        "name": "events",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "signing secret (at least 16 characters), generated if not set",
+                        "name": "secret",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "send events to the webhook (default true)",
+                        "name": "active",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/route.NewWebhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Return a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "(option) update url, events, active. The secret can't be changed, create a new webhook instead. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Update a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "http or https URL",
+                        "name": "url",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma separated events",
+                        "name": "events",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "send events to the webhook",
+                        "name": "active",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Deletes the webhook with its deliveries, pending ones aren't sent. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResMesOK"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Newest first. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List deliveries of a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "pending, delivered or dead",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "limit (default 50, at most 500)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/audit": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Every change of users, bookings, resources, API keys, webhooks and calendar tokens with who made it (actor, api_key_id, request_id, ip), when, and the changed fields before and after. Newest first, pass the id of the last entry as before_id for the next page. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List the audit log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user, booking, resource, api_key, webhook, webhook_delivery or calendar_token",
+                        "name": "entity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "id of the entity, the user id for calendar_token",
+                        "name": "entity_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "create, update, delete, restore, purge, rotate, revoke or redeliver",
+                        "name": "operation",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "admin, api_key, anonymous or system",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "changes made with this API key",
+                        "name": "api_key_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "changes made by this request (X-Request-ID)",
+                        "name": "request_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "entries at or after, RFC 3339 or YYYY-MM-DD",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "entries before, RFC 3339 or YYYY-MM-DD",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "entries older than this id",
+                        "name": "before_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "limit (default 50, at most 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.AuditEntry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/audit/verify": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Recomputes the hash of every entry in order. valid is false and broken_at is the first entry that doesn't match when an entry was changed, removed or inserted.\nEntries removed from the end can't be told from the chain alone: keep last_hash elsewhere and check that the chain still has it. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Verify the hash chain of the audit log",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.AuditVerification"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/booking": {
+            "get": {
+                "description": "(optional) set limit or limit with page or limit with offset. Deleted bookings are listed only with include_deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Return all bookings",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "offset",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "list deleted bookings too (admin scope)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.BookingsData"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Prepairing booking data for new booking (linked to user) in db\n409 booking_conflict if it overlaps another booking of the resource, bookings without a resource aren't checked.",
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Add new booking data in db",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user_id (user is exists)",
+                        "name": "user_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "resource_id (resource is exists)",
+                        "name": "resource_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "start_time (RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user)",
+                        "name": "start_time",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "end_time (RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user)",
+                        "name": "end_time",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "comment (5 \u003c= length \u003c= 120, exclude=\\",
+                        "name": "comment",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Booking"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/booking/batch": {
+            "post": {
+                "description": "Runs the operations in order in one transaction. Conflicts are checked against the bookings as the earlier operations leave them, so bookings of the batch can't overlap each other.\nAn atomic batch (the default) saves every operation or none: when one fails, 422 reports every operation and nothing is saved. With atomic false the failed operations are skipped and the others are saved.\nFields of update are optional, the booking keeps the ones that aren't set. A booking can be updated or deleted once per batch.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Create, update and delete bookings in one request",
+                "parameters": [
+                    {
+                        "description": "operations",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/route.BatchRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/route.BatchResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/route.BatchResult"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/booking/export": {
+            "get": {
+                "description": "Streams the bookings by id as CSV, NDJSON or XLSX, chosen by format or else by Accept (CSV by default). Rows are sent as they're read, so exports of any size take little memory.\nCSV and XLSX start with a header row, NDJSON has a booking per line. Texts that spreadsheets would take for formulas are prefixed with ' in CSV.\nIf the export fails midway the connection is closed without ending the response, so a cut off export can't be taken for a whole one.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Export bookings",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv, ndjson or xlsx, instead of Accept",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "only the bookings of this user, required for keys restricted to a user",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only bookings starting at or after (RFC 3339, YYYY-MM-DD HH:MM:SS or YYYY-MM-DD in tz)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only bookings starting before (RFC 3339, YYYY-MM-DD HH:MM:SS or YYYY-MM-DD in tz)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page (required limit)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "offset (required limit)",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "export deleted rows too (admin scope)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "the bookings",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/booking/import": {
+            "post": {
+                "description": "Every VEVENT of the .ics file becomes a booking of the user (taking the resource with resource_id), repeating events (RRULE, RDATE, EXDATE and changed occurrences) a booking per occurrence. Endless ones are imported up to booking.import_horizon (a year) from now.\nTimes without a zone are taken in the resource's time zone, or else the user's. SUMMARY becomes the comment, cancelled events are skipped.\nA booking conflicts when it overlaps an existing booking of the resource (or of the user without a resource when resource_id isn't set), or an earlier booking of the file.\ndry_run reports every booking without creating any. Otherwise the import is all or nothing: with an invalid or conflicting booking nothing is created and 422 reports them, unless skip_invalid creates the others.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Import bookings from an iCalendar file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": ".ics file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "user the bookings are for",
+                        "name": "user_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "resource the bookings take",
+                        "name": "resource_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "only report what would be imported",
+                        "name": "dry_run",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "import the valid bookings when some are invalid or conflict",
+                        "name": "skip_invalid",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/route.ImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/route.ImportResult"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/booking/stream": {
+            "get": {
+                "description": "Server-Sent Events of booking (and optionally user) changes: \"id\" is the event id, \"event\" its type (booking.created, booking.updated, booking.deleted, ...), \"data\" the event as webhooks get it.\nThe same stream is served over WebSocket when the request is a WebSocket upgrade, every message is {\"id\", \"event\", \"data\"}.\nA client resumes after the Last-Event-ID header or the last_event_id parameter, events of the last 7 days (outbox.retention) are replayed.\nEvents committed late may have ids below ones already sent, so a resumed stream repeats the events of up to 1000 ids before the one resumed after: clients de-duplicate them by the event id in \"data\".",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Stream booking changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "comma separated resources: booking (default), user (requires users:read)",
+                        "name": "resource",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "only events of this user and their bookings",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "only events of bookings taking this resource",
+                        "name": "resource_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "resume after this event (or the Last-Event-ID header)",
+                        "name": "last_event_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/route.streamMessage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/booking/{id}": {
+            "get": {
+                "description": "404 if the booking isn't found or is deleted ({} with api.legacy_not_found), admins find deleted bookings with include_deleted",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Return booking data (json) by id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id to find booking",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "find a deleted booking too (admin scope)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Booking"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "(option) update start_time, end_time, comment\n409 booking_conflict if the booking would overlap another booking of its resource, bookings without a resource aren't checked. The resource can't be changed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Update booking data by id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "booking id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "start_time (RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user)",
+                        "name": "start_time",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "end_time (RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user)",
+                        "name": "end_time",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comment (5 \u003c= length \u003c= 120, exclude=\\",
+                        "name": "comment",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Booking"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Delete booking data by id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id to find booking",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResMesOK"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/booking/{id}/history": {
+            "get": {
+                "description": "The audit log entries of the booking, oldest first: who created, moved, deleted or restored it and what changed.\nThe history of a deleted booking is kept, the history of a purged one only clients not restricted to a user can see.\nip, request_id and api_key_id are only returned to clients with the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Return the history of a booking",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "booking id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.AuditEntry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/booking/{id}/restore": {
+            "post": {
+                "description": "Restores the booking until it's purged (purge.retention after the deletion).\n409 if the booking isn't deleted, its user is deleted (restore the user instead) or it overlaps a booking made since.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "booking"
+                ],
+                "summary": "Restore a deleted booking",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "booking id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Booking"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/resource": {
+            "get": {
+                "description": "A key restricted to a resource gets only it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resource"
+                ],
+                "summary": "List resources",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Resource"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "A resource is a room or anything else that is booked, its bookings can't overlap. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resource"
+                ],
+                "summary": "Add a resource",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "name (1 \u003c= length \u003c= 80)",
+                        "name": "name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone for times of its bookings without an offset (default UTC)",
+                        "name": "timezone",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Resource"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/resource/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resource"
+                ],
+                "summary": "Return a resource",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "resource id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Resource"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "(option) update name, timezone. Times of its bookings are kept, a new zone applies to times given later. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resource"
+                ],
+                "summary": "Update a resource",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "resource id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "name (1 \u003c= length \u003c= 80)",
+                        "name": "name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone for times of its bookings without an offset",
+                        "name": "timezone",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Resource"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "409 resource_in_use while a booking takes it, delete its bookings first. Deleted bookings of it lose their resource_id. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resource"
+                ],
+                "summary": "Delete a resource",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "resource id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResMesOK"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/resource/{id}/calendar.ics": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "RFC 5545 calendar of the bookings taking the resource, whoever made them, like the feed of a user.\nCalendar tokens belong to a user, so this feed needs an API key that isn't restricted to a user.",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "resource"
+                ],
+                "summary": "Bookings of a resource as an iCalendar feed",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "resource id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of the events (or Time-Zone header), default the resource's time zone",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "text/calendar",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user": {
+            "post": {
+                "description": "Prepairing user data for new user in db",
+                "tags": [
+                    "user"
+                ],
+                "summary": "Add new user data in db",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username (3 \u003c= length \u003c= 20, exclude=\\",
+                        "name": "username",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "password (8 \u003c= length \u003c= 64 by default, not common or breached)",
+                        "name": "password",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone for times without an offset (default UTC)",
+                        "name": "timezone",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/export": {
+            "get": {
+                "description": "Streams the users by id as CSV, NDJSON or XLSX like GET /booking/export, without password hashes.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Export users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv, ndjson or xlsx, instead of Accept",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only users created at or after (RFC 3339, YYYY-MM-DD HH:MM:SS or YYYY-MM-DD in tz)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only users created before (RFC 3339, YYYY-MM-DD HH:MM:SS or YYYY-MM-DD in tz)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page (required limit)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "offset (required limit)",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "export deleted rows too (admin scope)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "the users",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/login": {
+            "post": {
+                "description": "Returns the user when the username and password match, 401 otherwise, like for an unknown username.\nA key restricted to a user can only check the password of that user, for others it gets 401 too. A key restricted to a resource gets 403.\nA password hash made with an outdated algorithm or parameters is replaced with one made with the current settings.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Check a user's password",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username, case and look-alike characters are ignored",
+                        "name": "username",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "password",
+                        "name": "password",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}": {
+            "get": {
+                "description": "404 if the user isn't found or is deleted ({} with api.legacy_not_found), admins find deleted users with include_deleted",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Return user data (json) by id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id to find user",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "find a deleted user too (admin scope)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "(option) update username(to unique), password, timezone",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Update user data by id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "username (3 \u003c= length \u003c= 20, exclude=\\",
+                        "name": "username",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "password (8 \u003c= length \u003c= 64 by default, not common or breached)",
+                        "name": "password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone for times without an offset",
+                        "name": "timezone",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete user and bookings (if exists)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Delete user data (user and bookings) by id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id to find user",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResMesOK"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}/calendar.ics": {
+            "get": {
+                "description": "RFC 5545 calendar to subscribe to in Outlook, Google Calendar or Apple Calendar. Every booking is an event with a stable UID, its SEQUENCE grows on every update.\nCalendar apps can't send API keys, so the feed also accepts the calendar token of the user in the token parameter, see POST /user/{id}/calendar/token.",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Bookings of a user as an iCalendar feed",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "calendar token of the user, instead of an API key",
+                        "name": "token",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone of the events (or Time-Zone header), default the user's time zone",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "text/calendar",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}/calendar/token": {
+            "post": {
+                "description": "Returns the token and the feed URL to subscribe to, they're shown only in this response. An existing token of the user stops working.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Create the calendar token of a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/route.NewCalendarToken"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Calendar apps subscribed with it can't fetch the feed anymore.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Revoke the calendar token of a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResMesOK"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}/restore": {
+            "post": {
+                "description": "Restores the user with the bookings deleted with it, until they're purged (purge.retention after the deletion).\n409 if the user isn't deleted or its username is taken by another user since.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Restore a deleted user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in (or Time-Zone header), default UTC",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/datavalidator.ResError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "datavalidator.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "booking_not_found"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "booking wasn't found"
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/v1/booking/1021"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Not Found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
+        },
+        "datavalidator.ResError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "username_banned_symbols"
+                },
+                "message": {
+                    "description": "exclude = \\\"\\\\\\/",
+                    "type": "string",
+                    "example": "banned symbols in username"
+                }
+            }
+        },
+        "datavalidator.ResMesOK": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "user_deleted"
+                },
+                "message": {
+                    "description": "exclude = \\\"\\\\\\/",
+                    "type": "string",
+                    "example": "... successfully ..."
+                }
+            }
+        },
+        "db.AuditVerification": {
+            "type": "object",
+            "properties": {
+                "broken_at": {
+                    "description": "the first entry whose hash or previous hash doesn't match, the chain from it on can't be trusted",
+                    "type": "integer",
+                    "example": 1200
+                },
+                "checked": {
+                    "description": "entries checked",
+                    "type": "integer",
+                    "example": 52311
+                },
+                "last_hash": {
+                    "description": "hash of the newest entry, keep it elsewhere to tell later if entries at the end were removed",
+                    "type": "string",
+                    "example": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4"
+                },
+                "valid": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "db.BookingsData": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Booking"
+                    }
+                }
+            }
+        },
+        "model.APIKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-09-24T20:13:42+03:00"
+                },
+                "expires_at": {
+                    "description": "RFC 3339, the key doesn't work after it",
+                    "type": "string",
+                    "example": "2024-09-24T00:00:00Z"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "nightly export"
+                },
+                "prefix": {
+                    "description": "first characters of the key, to tell keys apart",
+                    "type": "string",
+                    "example": "bk_Xq3k9Fz"
+                },
+                "resource_id": {
+                    "description": "when set, the key works only with this resource and its bookings",
+                    "type": "integer",
+                    "example": 4
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "rotated_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "permissions, e.g. bookings:read",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "bookings:read",
+                        "bookings:write"
+                    ]
+                },
+                "user_id": {
+                    "description": "when set, the key works only with this user and their bookings",
+                    "type": "integer",
+                    "example": 906
+                }
+            }
+        },
+        "model.AuditEntry": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "description": "admin, api_key, anonymous or system",
+                    "type": "string",
+                    "example": "api_key"
+                },
+                "api_key_id": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "created_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-10-01T15:02:11.482913Z"
+                },
+                "diff": {
+                    "description": "changed fields with their values before and after, secrets are \"[redacted]\"",
+                    "type": "object"
+                },
+                "entity": {
+                    "description": "user, booking, resource, api_key, webhook, webhook_delivery or calendar_token",
+                    "type": "string",
+                    "example": "booking"
+                },
+                "entity_id": {
+                    "type": "integer",
+                    "example": 1021
+                },
+                "hash": {
+                    "type": "string",
+                    "example": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 52311
+                },
+                "ip": {
+                    "type": "string",
+                    "example": "203.0.113.24"
+                },
+                "operation": {
+                    "description": "create, update, delete, restore, purge, rotate, revoke or redeliver",
+                    "type": "string",
+                    "example": "update"
+                },
+                "prev_hash": {
+                    "description": "hex SHA-256 of the previous entry, \"\" for the first one",
+                    "type": "string",
+                    "example": "9c1185a5c5e9fc54612808977ee8f548b2258d31"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "f3b1c2d4e5a6978812345678"
+                }
+            }
+        },
+        "model.Booking": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "description": "min_length = 5\nmin_length = 120\nexclude = \\\"\\\\\\/",
+                    "type": "string",
+                    "example": "I may be a little late"
+                },
+                "deleted_at": {
+                    "description": "RFC 3339, set while the booking is deleted and can be restored",
+                    "type": "string"
+                },
+                "end_time": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-10-01T17:30:00+03:00"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1021
+                },
+                "resource_id": {
+                    "description": "room or other resource the booking takes, it can't be booked twice at once",
+                    "type": "integer",
+                    "example": 4
+                },
+                "start_time": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-10-01T15:00:00+03:00"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 906
+                }
+            }
+        },
+        "model.Resource": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-09-24T20:13:42+03:00"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Meeting room 2"
+                },
+                "timezone": {
+                    "description": "IANA time zone, used for times of its bookings given without an offset",
+                    "type": "string",
+                    "example": "Europe/Moscow"
+                },
+                "updated_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-09-27T14:10:23+03:00"
+                }
+            }
+        },
+        "model.User": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-09-24T20:13:42+03:00"
+                },
+                "deleted_at": {
+                    "description": "RFC 3339, set while the user is deleted and can be restored",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 906
+                },
+                "timezone": {
+                    "description": "IANA time zone, used for times given without an offset",
+                    "type": "string",
+                    "example": "Europe/Moscow"
+                },
+                "updated_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-09-27T14:10:23+03:00"
+                },
+                "username": {
+                    "description": "min_length = 3\nmin_length = 20\nexclude = \\\"\\\\\\/",
+                    "type": "string",
+                    "example": "Andrew"
+                }
+            }
+        },
+        "model.Webhook": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "inactive webhooks get no new deliveries",
+                    "type": "boolean",
+                    "example": true
+                },
+                "created_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-09-24T20:13:42+03:00"
+                },
+                "events": {
+                    "description": "event types, e.g. booking.created",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "booking.created",
+                        "booking.deleted"
+                    ]
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "updated_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-09-27T14:10:23+03:00"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://billing.example.com/hooks/bookings"
+                }
+            }
+        },
+        "model.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2023-10-01T15:00:00Z"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string",
+                    "example": "booking.created"
+                },
+                "event_id": {
+                    "type": "string",
+                    "example": "evt_5f0c6a3e9b1d4c2a8e7f6b5a4c3d2e1f"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 5812
+                },
+                "last_error": {
+                    "type": "string",
+                    "example": "unexpected status 503"
+                },
+                "last_status_code": {
+                    "type": "integer",
+                    "example": 503
+                },
+                "next_attempt_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-10-01T15:02:00Z"
+                },
+                "payload": {
+                    "description": "the request body",
+                    "type": "object"
+                },
+                "status": {
+                    "description": "pending, delivered or dead",
+                    "type": "string",
+                    "example": "pending"
+                },
+                "webhook_id": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "route.BatchItem": {
+            "type": "object",
+            "properties": {
+                "booking": {
+                    "description": "the created, updated or deleted booking",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Booking"
+                        }
+                    ]
+                },
+                "code": {
+                    "type": "string",
+                    "example": "booking_conflict"
+                },
+                "http_status": {
+                    "description": "HTTP status the operation would get on its own",
+                    "type": "integer",
+                    "example": 200
+                },
+                "index": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "message": {
+                    "type": "string",
+                    "example": "the booking overlaps booking 1021"
+                },
+                "op": {
+                    "type": "string",
+                    "example": "create"
+                },
+                "status": {
+                    "description": "ok, failed or rolled_back",
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "route.BatchOperation": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "description": "optional, unchanged on update when it isn't set",
+                    "type": "string",
+                    "example": "I may be a little late"
+                },
+                "end_time": {
+                    "description": "RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user; optional on update",
+                    "type": "string",
+                    "example": "2023-10-01T17:30:00+03:00"
+                },
+                "id": {
+                    "description": "booking to update or delete",
+                    "type": "integer",
+                    "example": 1021
+                },
+                "op": {
+                    "description": "create, update or delete",
+                    "type": "string",
+                    "example": "create"
+                },
+                "resource_id": {
+                    "description": "resource of the booking to create, optional",
+                    "type": "integer",
+                    "example": 4
+                },
+                "start_time": {
+                    "description": "RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user; optional on update",
+                    "type": "string",
+                    "example": "2023-10-01T15:00:00+03:00"
+                },
+                "user_id": {
+                    "description": "user of the booking to create",
+                    "type": "integer",
+                    "example": 906
+                }
+            }
+        },
+        "route.BatchRequest": {
+            "type": "object",
+            "properties": {
+                "atomic": {
+                    "description": "all or nothing (default), or false for best effort",
+                    "type": "boolean",
+                    "example": true
+                },
+                "operations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/route.BatchOperation"
+                    }
+                }
+            }
+        },
+        "route.BatchResult": {
+            "type": "object",
+            "properties": {
+                "atomic": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "committed": {
+                    "description": "whether the successful operations were saved",
+                    "type": "boolean",
+                    "example": true
+                },
+                "failed": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/route.BatchItem"
+                    }
+                },
+                "succeeded": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "route.ImportItem": {
+            "type": "object",
+            "properties": {
+                "booking_id": {
+                    "type": "integer",
+                    "example": 1022
+                },
+                "code": {
+                    "type": "string",
+                    "example": "booking_conflict"
+                },
+                "comment": {
+                    "type": "string",
+                    "example": "Weekly sync"
+                },
+                "conflicts_with": {
+                    "description": "ids of the existing bookings it overlaps",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1021
+                    ]
+                },
+                "end_time": {
+                    "type": "string",
+                    "example": "2023-10-01T17:30:00+03:00"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "the booking overlaps booking 1021"
+                },
+                "start_time": {
+                    "type": "string",
+                    "example": "2023-10-01T15:00:00+03:00"
+                },
+                "status": {
+                    "description": "ok (dry run), created, invalid, conflict or skipped (cancelled events)",
+                    "type": "string",
+                    "example": "conflict"
+                },
+                "uid": {
+                    "description": "Uid is the UID of the event, a repeating event has an item per occurrence",
+                    "type": "string",
+                    "example": "040000008200E00074C5B7101A82E008"
+                }
+            }
+        },
+        "route.ImportResult": {
+            "type": "object",
+            "properties": {
+                "conflicts": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "created": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "dry_run": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "invalid": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/route.ImportItem"
+                    }
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 12
+                }
+            }
+        },
+        "route.NewAPIKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-09-24T20:13:42+03:00"
+                },
+                "expires_at": {
+                    "description": "RFC 3339, the key doesn't work after it",
+                    "type": "string",
+                    "example": "2024-09-24T00:00:00Z"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "key": {
+                    "type": "string",
+                    "example": "bk_Xq3k9FzB0f1v0rQ2o8mDkWl1nV7HcQeT3uYpJ6sAaLg"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "nightly export"
+                },
+                "prefix": {
+                    "description": "first characters of the key, to tell keys apart",
+                    "type": "string",
+                    "example": "bk_Xq3k9Fz"
+                },
+                "resource_id": {
+                    "description": "when set, the key works only with this resource and its bookings",
+                    "type": "integer",
+                    "example": 4
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "rotated_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "permissions, e.g. bookings:read",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "bookings:read",
+                        "bookings:write"
+                    ]
+                },
+                "user_id": {
+                    "description": "when set, the key works only with this user and their bookings",
+                    "type": "integer",
+                    "example": 906
+                }
+            }
+        },
+        "route.NewCalendarToken": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2023-10-01T15:00:00+03:00"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "cal_Xq3k9Fz"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "cal_Xq3k9FzB0f1v0rQ2o8mDkWl1nV7HcQeT3uYpJ6sAaLg"
+                },
+                "url": {
+                    "description": "URL to subscribe to in calendar apps",
+                    "type": "string",
+                    "example": "https://bookings.example.com/api/v1/user/906/calendar.ics?token=cal_Xq3k9FzB0f1v0rQ2o8mDkWl1nV7HcQeT3uYpJ6sAaLg"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 906
+                }
+            }
+        },
+        "route.NewWebhook": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "inactive webhooks get no new deliveries",
+                    "type": "boolean",
+                    "example": true
+                },
+                "created_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-09-24T20:13:42+03:00"
+                },
+                "events": {
+                    "description": "event types, e.g. booking.created",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "booking.created",
+                        "booking.deleted"
+                    ]
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "whsec_9b1d4c2a8e7f6b5a4c3d2e1f5f0c6a3e9b1d4c2a8e7f6b5a4c3d2e1f5f0c6a3e"
+                },
+                "updated_at": {
+                    "description": "RFC 3339",
+                    "type": "string",
+                    "example": "2023-09-27T14:10:23+03:00"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://billing.example.com/hooks/bookings"
+                }
+            }
+        },
+        "route.streamMessage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Data is the event as webhooks get it",
+                    "type": "object"
+                },
+                "event": {
+                    "type": "string",
+                    "example": "booking.updated"
+                },
+                "id": {
+                    "description": "ID is the event to resume after with last_event_id",
+                    "type": "integer",
+                    "example": 4711
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API key or admin token, \"Authorization: Bearer \u003ckey\u003e\" works too",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        }
+    }
+}`
+
+// SwaggerInfov1 holds exported Swagger Info so clients can modify it
+var SwaggerInfov1 = &swag.Spec{
+	Version:          "",
+	Host:             "",
+	BasePath:         "/api/v1",
+	Schemes:          []string{},
+	Title:            "CyberZoneDev test REST API project",
+	Description:      "This rest api is designed to work with the PostgreSQL database. There are two main entities: User and Booking. One user can have multiple Bookings",
+	InfoInstanceName: "v1",
+	SwaggerTemplate:  docTemplatev1,
+	LeftDelim:        "{{",
+	RightDelim:       "}}",
+}
+
+func init() {
+	swag.Register(SwaggerInfov1.InstanceName(), SwaggerInfov1)
+}
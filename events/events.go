@@ -0,0 +1,50 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// event types
+const (
+	BookingCreated = "booking.created"
+	BookingUpdated = "booking.updated"
+	// BookingDeleted is sent when a booking is cancelled, there's no other way to cancel one
+	BookingDeleted  = "booking.deleted"
+	BookingRestored = "booking.restored"
+	UserCreated     = "user.created"
+	UserUpdated     = "user.updated"
+	UserDeleted     = "user.deleted"
+	// UserRestored restores the bookings deleted with the user too, they get no events of their own
+	UserRestored = "user.restored"
+)
+
+// Types are all the event types.
+var Types = []string{BookingCreated, BookingUpdated, BookingDeleted, BookingRestored, UserCreated, UserUpdated, UserDeleted, UserRestored}
+
+// aggregate types, events of one aggregate are published in order
+const (
+	AggregateUser    = "user"
+	AggregateBooking = "booking"
+)
+
+// Event is what sinks publish, e.g. the body of a webhook delivery.
+type Event struct {
+	// ID is the same in every publication of the event, consumers dedupe by it
+	ID   string `json:"id" example:"evt_5f0c6a3e9b1d4c2a8e7f6b5a4c3d2e1f"`
+	Type string `json:"type" example:"booking.created"`
+	//RFC 3339
+	Created_at time.Time `json:"created_at" example:"2023-10-01T15:00:00Z"`
+	// Data is the user or booking the event is about, as the API returns it
+	Data any `json:"data"`
+}
+
+// New returns an event of type eventType about data with a new ID.
+func New(eventType string, data any) (Event, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return Event{}, err
+	}
+	return Event{ID: "evt_" + hex.EncodeToString(b), Type: eventType, Created_at: time.Now().UTC(), Data: data}, nil
+}
@@ -0,0 +1,166 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version is set at build time:
+//
+//	go build -ldflags "-X github.com/subliker/backendproj/health.Version=1.2.0"
+var Version = "dev"
+
+// DB is the database as seen by the probes, *db.DataBase in production.
+type DB interface {
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (applied, expected int, err error)
+	Stats() sql.DBStats
+}
+
+// Process reports the state of the application itself.
+type Process interface {
+	// Workers returns background workers by name and whether each one is running.
+	Workers() map[string]bool
+	ShuttingDown() bool
+}
+
+// Handler serves /healthz, /readyz and /status.
+type Handler struct {
+	DB        DB
+	Process   Process
+	StartedAt time.Time
+}
+
+func NewHandler(db DB, process Process) *Handler {
+	return &Handler{DB: db, Process: process, StartedAt: time.Now()}
+}
+
+const checkTimeout = 2 * time.Second
+
+// Healthz answers 200 while the process is able to serve requests at all.
+func (h *Handler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz answers 200 when the service can take traffic: the database is reachable,
+// migrations are applied, workers are running and the service isn't shutting down.
+// Otherwise it answers 503 with the failed checks.
+func (h *Handler) Readyz(c *gin.Context) {
+	checks := h.checks(c.Request.Context())
+	status := http.StatusOK
+	for _, result := range checks {
+		if result != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+	}
+	state := "ready"
+	if status != http.StatusOK {
+		state = "not ready"
+	}
+	c.JSON(status, gin.H{"status": state, "checks": checks})
+}
+
+func (h *Handler) checks(ctx context.Context) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	checks := map[string]string{
+		"database":   "ok",
+		"migrations": "ok",
+		"workers":    "ok",
+		"shutdown":   "ok",
+	}
+	if err := h.DB.Ping(ctx); err != nil {
+		checks["database"] = err.Error()
+	}
+	applied, expected, err := h.DB.SchemaVersion(ctx)
+	if err != nil {
+		checks["migrations"] = err.Error()
+	} else if applied < expected {
+		checks["migrations"] = "schema is behind this build"
+	}
+	for name, running := range h.Process.Workers() {
+		if !running {
+			checks["workers"] = name + " is stopped"
+		}
+	}
+	if h.Process.ShuttingDown() {
+		checks["shutdown"] = "shutting down"
+	}
+	return checks
+}
+
+type poolStatus struct {
+	MaxOpen      int           `json:"max_open"`
+	Open         int           `json:"open"`
+	InUse        int           `json:"in_use"`
+	Idle         int           `json:"idle"`
+	WaitCount    int64         `json:"wait_count"`
+	WaitDuration time.Duration `json:"wait_duration_ns"`
+	MaxIdleClose int64         `json:"max_idle_closed"`
+	MaxLifeClose int64         `json:"max_lifetime_closed"`
+}
+
+type buildStatus struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// Status reports readiness checks, database pool statistics, build info and uptime.
+func (h *Handler) Status(c *gin.Context) {
+	ctx := c.Request.Context()
+	applied, expected, _ := h.DB.SchemaVersion(ctx)
+	stats := h.DB.Stats()
+	uptime := time.Since(h.StartedAt)
+
+	c.JSON(http.StatusOK, gin.H{
+		"checks":     h.checks(ctx),
+		"build":      build(),
+		"started_at": h.StartedAt.UTC(),
+		"uptime":     uptime.Round(time.Second).String(),
+		"database": gin.H{
+			"schema_version":          applied,
+			"expected_schema_version": expected,
+			"pool": poolStatus{
+				MaxOpen:      stats.MaxOpenConnections,
+				Open:         stats.OpenConnections,
+				InUse:        stats.InUse,
+				Idle:         stats.Idle,
+				WaitCount:    stats.WaitCount,
+				WaitDuration: stats.WaitDuration,
+				MaxIdleClose: stats.MaxIdleClosed,
+				MaxLifeClose: stats.MaxLifetimeClosed,
+			},
+		},
+		"workers":    h.Process.Workers(),
+		"goroutines": runtime.NumGoroutine(),
+	})
+}
+
+func build() buildStatus {
+	status := buildStatus{Version: Version, GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return status
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			status.Revision = setting.Value
+		case "vcs.time":
+			status.Time = setting.Value
+		case "vcs.modified":
+			status.Modified = setting.Value == "true"
+		}
+	}
+	return status
+}
@@ -0,0 +1,82 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeDB struct {
+	pingErr           error
+	applied, expected int
+}
+
+func (d fakeDB) Ping(context.Context) error { return d.pingErr }
+
+func (d fakeDB) SchemaVersion(context.Context) (int, int, error) { return d.applied, d.expected, nil }
+
+func (d fakeDB) Stats() sql.DBStats { return sql.DBStats{} }
+
+type fakeProcess struct {
+	workers      map[string]bool
+	shuttingDown bool
+}
+
+func (p fakeProcess) Workers() map[string]bool { return p.workers }
+
+func (p fakeProcess) ShuttingDown() bool { return p.shuttingDown }
+
+func TestReadyz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ok := fakeDB{applied: 12, expected: 12}
+	running := fakeProcess{workers: map[string]bool{"outbox": true}}
+	tests := []struct {
+		name    string
+		db      fakeDB
+		process fakeProcess
+		failed  string
+		reason  string
+	}{
+		{"ready", ok, running, "", ""},
+		{"database down", fakeDB{pingErr: errors.New("connection refused"), applied: 12, expected: 12}, running, "database", "connection refused"},
+		{"schema behind", fakeDB{applied: 11, expected: 12}, running, "migrations", "schema is behind this build"},
+		{"worker stopped", ok, fakeProcess{workers: map[string]bool{"outbox": false}}, "workers", "outbox is stopped"},
+		{"shutting down", ok, fakeProcess{shuttingDown: true}, "shutdown", "shutting down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			NewHandler(tt.db, tt.process).Readyz(c)
+
+			var body struct {
+				Status string            `json:"status"`
+				Checks map[string]string `json:"checks"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if tt.failed == "" {
+				if w.Code != http.StatusOK || body.Status != "ready" {
+					t.Errorf("%d %q, want 200 ready: %v", w.Code, body.Status, body.Checks)
+				}
+				return
+			}
+			if w.Code != http.StatusServiceUnavailable || body.Status != "not ready" {
+				t.Errorf("%d %q, want 503 not ready", w.Code, body.Status)
+			}
+			for check, result := range body.Checks {
+				if want := map[bool]string{true: tt.reason, false: "ok"}[check == tt.failed]; result != want {
+					t.Errorf("check %s = %q, want %q", check, result, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// Catalog maps message codes to message templates.
+// Templates may contain {name} placeholders filled from Args.
+type Catalog map[string]string
+
+type Args map[string]any
+
+//go:embed locales/*.json
+var builtin embed.FS
+
+var DefaultLanguage = language.English
+
+var (
+	mu        sync.RWMutex
+	bundles   = map[language.Tag]Catalog{}
+	supported []language.Tag
+	matcher   language.Matcher
+)
+
+func init() {
+	if err := loadFS(builtin, "locales"); err != nil {
+		panic(err)
+	}
+}
+
+// LoadDir merges every <language>.json file from dir into the loaded bundles,
+// so translations can be added or overridden without recompiling.
+func LoadDir(dir string) error {
+	return loadFS(os.DirFS(dir), ".")
+}
+
+func loadFS(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".json")
+		tag, err := language.Parse(name)
+		if err != nil {
+			return fmt.Errorf("locale file %s: %w", file, err)
+		}
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		var catalog Catalog
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			return fmt.Errorf("locale file %s: %w", file, err)
+		}
+
+		if bundles[tag] == nil {
+			bundles[tag] = Catalog{}
+		}
+		for code, message := range catalog {
+			bundles[tag][code] = message
+		}
+	}
+	buildMatcher()
+	return nil
+}
+
+func buildMatcher() {
+	// the default language goes first so the matcher falls back to it
+	supported = []language.Tag{DefaultLanguage}
+	for tag := range bundles {
+		if tag != DefaultLanguage {
+			supported = append(supported, tag)
+		}
+	}
+	matcher = language.NewMatcher(supported)
+}
+
+// Negotiate picks the best supported language for an Accept-Language header value.
+func Negotiate(acceptLanguage string) language.Tag {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLanguage
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	_, index, _ := matcher.Match(tags...)
+	return supported[index]
+}
+
+// Translate renders the message for code in the given language.
+// Lookup falls back from tag to its parents (pt-BR -> pt) and then to DefaultLanguage.
+// If nothing is found the code itself is returned.
+func Translate(tag language.Tag, code string, args Args) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, t := range fallbackChain(tag) {
+		if message, ok := bundles[t][code]; ok {
+			return format(message, args)
+		}
+	}
+	return code
+}
+
+func fallbackChain(tag language.Tag) []language.Tag {
+	chain := make([]language.Tag, 0, 3)
+	for t := tag; !t.IsRoot(); t = t.Parent() {
+		chain = append(chain, t)
+	}
+	return append(chain, DefaultLanguage)
+}
+
+func format(message string, args Args) string {
+	if len(args) == 0 {
+		return message
+	}
+	pairs := make([]string, 0, len(args)*2)
+	for name, value := range args {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(message)
+}
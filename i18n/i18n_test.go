@@ -0,0 +1,128 @@
+package i18n
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           language.Tag
+	}{
+		{"", language.English},
+		{"ru", language.Russian},
+		{"ru-RU,ru;q=0.9,en;q=0.8", language.Russian},
+		{"de-DE,ru;q=0.8,en;q=0.5", language.Russian},
+		{"en;q=0.5,ru;q=0.9", language.Russian},
+		{"en-GB", language.English},
+		{"de", language.English},
+		{"*", language.English},
+		{"not a header;;", language.English},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptLanguage, func(t *testing.T) {
+			if got := Negotiate(tt.acceptLanguage); got != tt.want {
+				t.Errorf("Negotiate(%q) = %v, want %v", tt.acceptLanguage, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  language.Tag
+		code string
+		args Args
+		want string
+	}{
+		{"english", language.English, "user_not_found", nil, "user wasn't found"},
+		{"russian", language.Russian, "user_not_found", nil, "пользователь не найден"},
+		{"region falls back to the language", language.MustParse("ru-RU"), "user_not_found", nil, "пользователь не найден"},
+		{"unsupported language falls back to english", language.German, "user_not_found", nil, "user wasn't found"},
+		{"placeholders", language.English, "query_param_incorrect", Args{"name": "limit"}, "limit must be an integer"},
+		{"unknown code", language.Russian, "no_such_code", nil, "no_such_code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Translate(tt.tag, tt.code, tt.args); got != tt.want {
+				t.Errorf("Translate(%v, %q) = %q, want %q", tt.tag, tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCatalogsMatch checks that every built-in locale has the codes of the default language with the same placeholders.
+func TestCatalogsMatch(t *testing.T) {
+	catalogs := map[string]Catalog{}
+	files, _ := fs.Glob(builtin, "locales/*.json")
+	for _, file := range files {
+		data, err := fs.ReadFile(builtin, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var catalog Catalog
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		catalogs[strings.TrimSuffix(path.Base(file), ".json")] = catalog
+	}
+	if catalogs[DefaultLanguage.String()] == nil {
+		t.Fatalf("no catalog of %v", DefaultLanguage)
+	}
+	placeholder := regexp.MustCompile(`\{[a-z_]+\}`)
+	placeholders := func(message string) []string {
+		found := placeholder.FindAllString(message, -1)
+		slices.Sort(found)
+		return slices.Compact(found)
+	}
+	for tag, catalog := range catalogs {
+		for code, message := range catalogs[DefaultLanguage.String()] {
+			translated, ok := catalog[code]
+			if !ok {
+				t.Errorf("%s has no %s", tag, code)
+				continue
+			}
+			if got, want := placeholders(translated), placeholders(message); !slices.Equal(got, want) {
+				t.Errorf("%s %s has placeholders %v, want %v", tag, code, got, want)
+			}
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pt.json"), []byte(`{"user_not_found": "usuário não encontrado"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := Negotiate("pt-BR,en;q=0.5"); got != language.Portuguese {
+		t.Errorf("Negotiate() = %v, want pt", got)
+	}
+	if got := Translate(language.BrazilianPortuguese, "user_not_found", nil); got != "usuário não encontrado" {
+		t.Errorf("Translate() = %q", got)
+	}
+	// codes missing in the new locale fall back to the default language
+	if got := Translate(language.Portuguese, "booking_not_found", nil); got != "booking wasn't found" {
+		t.Errorf("Translate() = %q", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "xx-invalid-.json"), []byte(`{}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDir(dir); err == nil {
+		t.Error("loaded a file named after no language")
+	}
+}
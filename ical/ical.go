@@ -0,0 +1,201 @@
+// Package ical writes and reads RFC 5545 calendars.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ProdID identifies the service in the calendars it writes.
+const ProdID = "-//backendproj//bookings//EN"
+
+// Event is a VEVENT.
+type Event struct {
+	// UID must stay the same for the life of the event, calendar apps match updates by it
+	UID string
+	// Sequence is incremented on every change of the event
+	Sequence    int
+	Start, End  time.Time
+	Summary     string
+	Description string
+	// Stamp is when the calendar was made
+	Stamp time.Time
+}
+
+// Calendar is a VCALENDAR published to subscribers.
+type Calendar struct {
+	Name string
+	// Location is the zone the events are shown in, it gets a VTIMEZONE unless it's UTC
+	Location *time.Location
+	Events   []Event
+}
+
+// Write writes c as text/calendar.
+func (c Calendar) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeLine(bw, name+":"+value)
+	}
+
+	loc := c.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", ProdID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", escape(c.Name))
+	}
+	if loc != time.UTC {
+		line("X-WR-TIMEZONE", loc.String())
+		from, to := span(c.Events)
+		writeTimezone(bw, loc, from, to)
+	}
+	for _, e := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", e.UID)
+		line("DTSTAMP", utc(e.Stamp))
+		line("SEQUENCE", fmt.Sprint(e.Sequence))
+		writeLine(bw, "DTSTART"+dateTime(e.Start, loc))
+		writeLine(bw, "DTEND"+dateTime(e.End, loc))
+		if e.Summary != "" {
+			line("SUMMARY", escape(e.Summary))
+		}
+		if e.Description != "" {
+			line("DESCRIPTION", escape(e.Description))
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// span returns the first start and the last end of events.
+func span(events []Event) (from, to time.Time) {
+	for i, e := range events {
+		if i == 0 || e.Start.Before(from) {
+			from = e.Start
+		}
+		if i == 0 || e.End.After(to) {
+			to = e.End
+		}
+	}
+	if len(events) == 0 {
+		from = time.Now()
+		to = from
+	}
+	return from, to
+}
+
+// writeTimezone writes the VTIMEZONE of loc with every offset change between from and to,
+// and the offset in effect a year before from. Changes are listed one by one instead of
+// as RRULEs, Go doesn't expose the rules of a zone, only its offsets at any time.
+func writeTimezone(w *bufio.Writer, loc *time.Location, from, to time.Time) {
+	start := from.AddDate(-1, 0, 0)
+	end := to.AddDate(1, 0, 0)
+
+	writeLine(w, "BEGIN:VTIMEZONE")
+	writeLine(w, "TZID:"+loc.String())
+	name, offset := start.In(loc).Zone()
+	writeObservance(w, start.In(loc), start.In(loc).IsDST(), name, offset, offset)
+	for t := start; t.Before(end); {
+		next := t.Add(24 * time.Hour)
+		if _, o := next.In(loc).Zone(); o != offset {
+			change := transition(loc, t, next)
+			newName, newOffset := change.In(loc).Zone()
+			// DTSTART of an observance is in the local time before the change
+			writeObservance(w, change.In(time.FixedZone("", offset)), change.In(loc).IsDST(), newName, offset, newOffset)
+			offset = newOffset
+			t = change
+			continue
+		}
+		t = next
+	}
+	writeLine(w, "END:VTIMEZONE")
+}
+
+// transition returns the instant in (before, after] the offset of loc changes at.
+func transition(loc *time.Location, before, after time.Time) time.Time {
+	_, offset := before.In(loc).Zone()
+	for after.Sub(before) > time.Second {
+		mid := before.Add(after.Sub(before) / 2).Truncate(time.Second)
+		if _, o := mid.In(loc).Zone(); o == offset {
+			before = mid
+		} else {
+			after = mid
+		}
+	}
+	return after
+}
+
+func writeObservance(w *bufio.Writer, start time.Time, dst bool, name string, from, to int) {
+	kind := "STANDARD"
+	if dst {
+		kind = "DAYLIGHT"
+	}
+	writeLine(w, "BEGIN:"+kind)
+	writeLine(w, "DTSTART:"+start.Format("20060102T150405"))
+	writeLine(w, "TZOFFSETFROM:"+utcOffset(from))
+	writeLine(w, "TZOFFSETTO:"+utcOffset(to))
+	// zones without an abbreviation are named by their offset, e.g. +03
+	if name != "" && name[0] != '+' && name[0] != '-' {
+		writeLine(w, "TZNAME:"+name)
+	}
+	writeLine(w, "END:"+kind)
+}
+
+func utcOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	s := fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if seconds%60 != 0 {
+		s += fmt.Sprintf("%02d", seconds%60)
+	}
+	return s
+}
+
+func utc(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// dateTime returns t as the value of a DTSTART or DTEND property with its parameters.
+func dateTime(t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return ":" + utc(t)
+	}
+	return ";TZID=" + loc.String() + ":" + t.In(loc).Format("20060102T150405")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// writeLine writes a content line folded at 75 octets, without splitting UTF-8 characters.
+func writeLine(w *bufio.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines start with a space
+		limit = 74
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
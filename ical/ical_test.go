@@ -0,0 +1,145 @@
+package ical
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestCalendarWrite(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	summary := "Планёрка отдела разработки, обсуждение релиза; длинное название"
+	description := "line one\nline two, with \"quotes\"\r\nend"
+	tests := []struct {
+		name string
+		cal  Calendar
+		want []string
+	}{
+		{
+			name: "UTC",
+			cal:  Calendar{Events: []Event{{UID: "booking-7@backendproj", Start: time.Date(2023, 3, 25, 9, 0, 0, 0, berlin), End: time.Date(2023, 3, 25, 10, 0, 0, 0, berlin), Stamp: stamp}}},
+			want: []string{
+				"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//backendproj//bookings//EN", "CALSCALE:GREGORIAN", "METHOD:PUBLISH",
+				"BEGIN:VEVENT", "UID:booking-7@backendproj", "DTSTAMP:20230301T120000Z", "SEQUENCE:0",
+				"DTSTART:20230325T080000Z", "DTEND:20230325T090000Z",
+				"END:VEVENT", "END:VCALENDAR",
+			},
+		},
+		{
+			// the event spans the change to summer time, so do the VTIMEZONE observances a year around it
+			name: "zone with DST",
+			cal: Calendar{Name: `Room 1, 2; \ floor`, Location: berlin, Events: []Event{{
+				UID: "booking-7@backendproj", Sequence: 3, Summary: summary, Description: description, Stamp: stamp,
+				Start: time.Date(2023, 3, 25, 9, 0, 0, 0, time.UTC), End: time.Date(2023, 3, 27, 10, 0, 0, 0, time.UTC),
+			}}},
+			want: []string{
+				"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//backendproj//bookings//EN", "CALSCALE:GREGORIAN", "METHOD:PUBLISH",
+				`X-WR-CALNAME:Room 1\, 2\; \\ floor`, "X-WR-TIMEZONE:Europe/Berlin",
+				"BEGIN:VTIMEZONE", "TZID:Europe/Berlin",
+				"BEGIN:STANDARD", "DTSTART:20220325T100000", "TZOFFSETFROM:+0100", "TZOFFSETTO:+0100", "TZNAME:CET", "END:STANDARD",
+				"BEGIN:DAYLIGHT", "DTSTART:20220327T020000", "TZOFFSETFROM:+0100", "TZOFFSETTO:+0200", "TZNAME:CEST", "END:DAYLIGHT",
+				"BEGIN:STANDARD", "DTSTART:20221030T030000", "TZOFFSETFROM:+0200", "TZOFFSETTO:+0100", "TZNAME:CET", "END:STANDARD",
+				"BEGIN:DAYLIGHT", "DTSTART:20230326T020000", "TZOFFSETFROM:+0100", "TZOFFSETTO:+0200", "TZNAME:CEST", "END:DAYLIGHT",
+				"BEGIN:STANDARD", "DTSTART:20231029T030000", "TZOFFSETFROM:+0200", "TZOFFSETTO:+0100", "TZNAME:CET", "END:STANDARD",
+				"END:VTIMEZONE",
+				"BEGIN:VEVENT", "UID:booking-7@backendproj", "DTSTAMP:20230301T120000Z", "SEQUENCE:3",
+				"DTSTART;TZID=Europe/Berlin:20230325T100000", "DTEND;TZID=Europe/Berlin:20230327T120000",
+				`SUMMARY:Планёрка отдела разработки\, обсужде`,
+				` ние релиза\; длинное название`,
+				`DESCRIPTION:line one\nline two\, with "quotes"\nend`,
+				"END:VEVENT", "END:VCALENDAR",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := tt.cal.Write(&b); err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.Join(tt.want, "\r\n") + "\r\n"; b.String() != want {
+				t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+			}
+		})
+	}
+}
+
+func TestWriteLine(t *testing.T) {
+	tests := []struct {
+		name, line string
+	}{
+		{"short", "SUMMARY:Standup"},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"ASCII", "DESCRIPTION:" + strings.Repeat("0123456789", 20)},
+		{"two-byte letters", "SUMMARY:" + strings.Repeat("ж", 100)},
+		{"four-byte characters", "SUMMARY:" + strings.Repeat("a🙂", 50)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			w := bufio.NewWriter(&b)
+			writeLine(w, tt.line)
+			w.Flush()
+
+			out := strings.TrimSuffix(b.String(), "\r\n")
+			lines := strings.Split(out, "\r\n")
+			unfolded := lines[0]
+			for i, l := range lines {
+				if len(l) > 75 {
+					t.Errorf("line %d is %d octets, want at most 75", i, len(l))
+				}
+				if !utf8.ValidString(l) {
+					t.Errorf("line %d splits a character: %q", i, l)
+				}
+				if i > 0 {
+					if !strings.HasPrefix(l, " ") {
+						t.Fatalf("continuation line %d doesn't start with a space: %q", i, l)
+					}
+					unfolded += l[1:]
+				}
+			}
+			if unfolded != tt.line {
+				t.Errorf("unfolded %q, want %q", unfolded, tt.line)
+			}
+			if len(tt.line) <= 75 && len(lines) != 1 {
+				t.Errorf("%d lines, want 1", len(lines))
+			}
+		})
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Event{
+		UID: "booking-7@backendproj", Summary: "Планёрка; отдел, релиз \\ итоги", Description: "line one\nline two",
+		Start: time.Date(2023, 10, 28, 23, 30, 0, 0, time.UTC), End: time.Date(2023, 10, 29, 1, 30, 0, 0, time.UTC),
+	}
+	var b strings.Builder
+	if err := (Calendar{Location: berlin, Events: []Event{want}}).Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	cal, err := Parse(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := Events(cal, time.UTC)
+	if len(events) != 1 || events[0].Err != nil {
+		t.Fatalf("events %+v, want 1", events)
+	}
+	got := events[0]
+	if got.UID != want.UID || got.Summary != want.Summary || got.Description != want.Description {
+		t.Errorf("read %q %q %q, want %q %q %q", got.UID, got.Summary, got.Description, want.UID, want.Summary, want.Description)
+	}
+	if !got.Start.Equal(want.Start) || !got.End.Equal(want.End) {
+		t.Errorf("read %v to %v, want %v to %v", got.Start, got.End, want.Start, want.End)
+	}
+}
@@ -0,0 +1,382 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnknownTimezone is returned for an event with a TZID that isn't an IANA time zone
+	ErrUnknownTimezone = errors.New("unknown time zone")
+	// ErrUnsupportedRule is returned for an RRULE using parts that aren't supported
+	ErrUnsupportedRule = errors.New("unsupported recurrence rule")
+	// ErrInvalidEvent is returned for an event that doesn't follow RFC 5545
+	ErrInvalidEvent = errors.New("invalid event")
+)
+
+// Property is a content line.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Component is a BEGIN/END block with its properties and nested components.
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+}
+
+// Get returns the first property named name.
+func (c *Component) Get(name string) (Property, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// All returns the properties named name.
+func (c *Component) All(name string) []Property {
+	var props []Property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// Parse reads the first VCALENDAR of r.
+func Parse(r io.Reader) (*Component, error) {
+	var stack []*Component
+	var root *Component
+	err := unfold(r, func(line string) error {
+		prop, err := parseLine(line)
+		if err != nil {
+			return err
+		}
+		switch prop.Name {
+		case "BEGIN":
+			c := &Component{Name: strings.ToUpper(prop.Value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			} else if c.Name != "VCALENDAR" {
+				return fmt.Errorf("%w: %s outside of VCALENDAR", ErrInvalidEvent, c.Name)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return fmt.Errorf("%w: unexpected END:%s", ErrInvalidEvent, prop.Value)
+			}
+			if len(stack) == 1 {
+				root = stack[0]
+				return io.EOF
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return fmt.Errorf("%w: %s outside of VCALENDAR", ErrInvalidEvent, prop.Name)
+			}
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, prop)
+		}
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if root == nil {
+		return nil, fmt.Errorf("%w: no VCALENDAR", ErrInvalidEvent)
+	}
+	return root, nil
+}
+
+// maxLineLength limits an unfolded content line, so a broken file can't take all the memory.
+const maxLineLength = 1 << 20
+
+// unfold calls f for every unfolded content line of r until f returns an error.
+func unfold(r io.Reader, f func(line string) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxLineLength)
+	var line strings.Builder
+	flush := func() error {
+		if line.Len() == 0 {
+			return nil
+		}
+		s := line.String()
+		line.Reset()
+		return f(s)
+	}
+	for sc.Scan() {
+		text := strings.TrimSuffix(sc.Text(), "\r")
+		if strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t") {
+			if line.Len()+len(text) > maxLineLength {
+				return fmt.Errorf("%w: line too long", ErrInvalidEvent)
+			}
+			line.WriteString(text[1:])
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		line.WriteString(text)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// parseLine parses name *(";" param) ":" value, parameter values may be quoted.
+func parseLine(line string) (Property, error) {
+	prop := Property{Params: map[string]string{}}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return prop, fmt.Errorf("%w: malformed line %q", ErrInvalidEvent, truncate(line))
+	}
+	prop.Name = strings.ToUpper(line[:i])
+	for line[i] == ';' {
+		rest := line[i+1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return prop, fmt.Errorf("%w: malformed parameter in %q", ErrInvalidEvent, truncate(line))
+		}
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return prop, fmt.Errorf("%w: unterminated quote in %q", ErrInvalidEvent, truncate(line))
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return prop, fmt.Errorf("%w: malformed line %q", ErrInvalidEvent, truncate(line))
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		prop.Params[name] = value
+		i = len(line) - len(rest)
+		if i >= len(line) {
+			return prop, fmt.Errorf("%w: malformed line %q", ErrInvalidEvent, truncate(line))
+		}
+	}
+	if line[i] != ':' {
+		return prop, fmt.Errorf("%w: malformed line %q", ErrInvalidEvent, truncate(line))
+	}
+	prop.Value = line[i+1:]
+	return prop, nil
+}
+
+func truncate(s string) string {
+	if len(s) > 40 {
+		return s[:40] + "..."
+	}
+	return s
+}
+
+var unescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// unescape returns the value of a TEXT property.
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
+
+// VEvent is an event read from a calendar.
+type VEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	// Status is TENTATIVE, CONFIRMED, CANCELLED or empty
+	Status     string
+	Start, End time.Time
+	// AllDay is set when DTSTART is a date, Start and End are midnights then
+	AllDay bool
+	// Rule is the RRULE, nil when the event doesn't repeat
+	Rule    *Rule
+	RDates  []time.Time
+	ExDates []time.Time
+	// RecurrenceID is the start of the occurrence of UID this event replaces, zero for other events
+	RecurrenceID time.Time
+	// Err is why the event can't be read, the fields above may be incomplete then
+	Err error
+}
+
+// Events returns the VEVENTs of cal. Times without a zone (floating) and dates are taken in floating.
+// An event that can't be read is returned with Err, the others are still returned.
+func Events(cal *Component, floating *time.Location) []VEvent {
+	zones := map[string]*time.Location{}
+	for _, c := range cal.Components {
+		if c.Name != "VTIMEZONE" {
+			continue
+		}
+		tzid, _ := c.Get("TZID")
+		// written by Google Calendar and Thunderbird for zones with custom TZIDs
+		if name, ok := c.Get("X-LIC-LOCATION"); ok {
+			if loc, err := time.LoadLocation(name.Value); err == nil {
+				zones[tzid.Value] = loc
+			}
+		}
+	}
+	r := timeReader{floating: floating, zones: zones}
+
+	var events []VEvent
+	for _, c := range cal.Components {
+		if c.Name == "VEVENT" {
+			events = append(events, r.event(c))
+		}
+	}
+	return events
+}
+
+type timeReader struct {
+	floating *time.Location
+	zones    map[string]*time.Location
+}
+
+func (r timeReader) event(c *Component) VEvent {
+	var e VEvent
+	if p, ok := c.Get("UID"); ok {
+		e.UID = p.Value
+	}
+	if p, ok := c.Get("SUMMARY"); ok {
+		e.Summary = unescape(p.Value)
+	}
+	if p, ok := c.Get("DESCRIPTION"); ok {
+		e.Description = unescape(p.Value)
+	}
+	if p, ok := c.Get("STATUS"); ok {
+		e.Status = strings.ToUpper(p.Value)
+	}
+
+	start, ok := c.Get("DTSTART")
+	if !ok {
+		e.Err = fmt.Errorf("%w: no DTSTART", ErrInvalidEvent)
+		return e
+	}
+	if e.Start, e.AllDay, e.Err = r.time(start); e.Err != nil {
+		return e
+	}
+	if end, ok := c.Get("DTEND"); ok {
+		if e.End, _, e.Err = r.time(end); e.Err != nil {
+			return e
+		}
+	} else if d, ok := c.Get("DURATION"); ok {
+		var duration time.Duration
+		var days int
+		if days, duration, e.Err = parseDuration(d.Value); e.Err != nil {
+			return e
+		}
+		e.End = e.Start.AddDate(0, 0, days).Add(duration)
+	} else if e.AllDay {
+		e.End = e.Start.AddDate(0, 0, 1)
+	} else {
+		e.End = e.Start
+	}
+
+	if p, ok := c.Get("RECURRENCE-ID"); ok {
+		if e.RecurrenceID, _, e.Err = r.time(p); e.Err != nil {
+			return e
+		}
+	}
+	if p, ok := c.Get("RRULE"); ok {
+		if e.Rule, e.Err = parseRule(p.Value, r, e.Start.Location()); e.Err != nil {
+			return e
+		}
+	}
+	if e.ExDates, e.Err = r.times(c.All("EXDATE")); e.Err != nil {
+		return e
+	}
+	if e.RDates, e.Err = r.times(c.All("RDATE")); e.Err != nil {
+		return e
+	}
+	return e
+}
+
+// times returns the values of properties with comma separated lists of times, like EXDATE.
+func (r timeReader) times(props []Property) ([]time.Time, error) {
+	var times []time.Time
+	for _, p := range props {
+		if strings.EqualFold(p.Params["VALUE"], "PERIOD") {
+			return nil, fmt.Errorf("%w: %s periods", ErrUnsupportedRule, p.Name)
+		}
+		for _, v := range strings.Split(p.Value, ",") {
+			t, _, err := r.time(Property{Name: p.Name, Params: p.Params, Value: v})
+			if err != nil {
+				return nil, err
+			}
+			times = append(times, t)
+		}
+	}
+	return times, nil
+}
+
+// time parses a DATE or DATE-TIME property value and reports whether it's a date.
+func (r timeReader) time(p Property) (time.Time, bool, error) {
+	loc := r.floating
+	if tzid, ok := p.Params["TZID"]; ok {
+		var err error
+		if loc, err = r.location(tzid); err != nil {
+			return time.Time{}, false, err
+		}
+	}
+	if strings.EqualFold(p.Params["VALUE"], "DATE") || len(p.Value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", p.Value, r.floating)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: %s %q", ErrInvalidEvent, p.Name, p.Value)
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(p.Value, "Z") {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation("20060102T150405", strings.TrimSuffix(p.Value, "Z"), loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: %s %q", ErrInvalidEvent, p.Name, p.Value)
+	}
+	return t, false, nil
+}
+
+func (r timeReader) location(tzid string) (*time.Location, error) {
+	if loc, ok := r.zones[tzid]; ok {
+		return loc, nil
+	}
+	// some writers prefix the IANA name with a slash
+	loc, err := time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+	if err != nil || tzid == "" || tzid == "Local" {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTimezone, tzid)
+	}
+	return loc, nil
+}
+
+var durationRe = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration parses a DURATION value into days, which follow the calendar over DST changes, and the exact rest.
+func parseDuration(s string) (days int, d time.Duration, err error) {
+	m := durationRe.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, 0, fmt.Errorf("%w: DURATION %q", ErrInvalidEvent, s)
+	}
+	n := func(i int) int {
+		v, _ := strconv.Atoi(m[i])
+		return v
+	}
+	days = n(2)*7 + n(3)
+	d = time.Duration(n(4))*time.Hour + time.Duration(n(5))*time.Minute + time.Duration(n(6))*time.Second
+	if m[1] == "-" {
+		days, d = -days, -d
+	}
+	return days, d, nil
+}
@@ -0,0 +1,306 @@
+package ical
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule is an RRULE. BYSETPOS, BYWEEKNO, BYYEARDAY, BYHOUR, BYMINUTE, BYSECOND
+// and frequencies under a day aren't supported.
+type Rule struct {
+	Freq     string
+	Interval int
+	// Count is 0 when the rule isn't limited by count
+	Count int
+	// Until is zero when the rule isn't limited by time
+	Until      time.Time
+	ByMonth    []time.Month
+	ByMonthDay []int
+	ByDay      []WeekdayNum
+	WeekStart  time.Weekday
+}
+
+// WeekdayNum is a BYDAY value like MO or -1FR, N is 0 for every such weekday.
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+func parseRule(s string, r timeReader, loc *time.Location) (*Rule, error) {
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: RRULE %q", ErrInvalidEvent, s)
+		}
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(value)
+			if rule.Freq != "DAILY" && rule.Freq != "WEEKLY" && rule.Freq != "MONTHLY" && rule.Freq != "YEARLY" {
+				return nil, fmt.Errorf("%w: FREQ=%s", ErrUnsupportedRule, value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err == nil && rule.Interval < 1 {
+				err = fmt.Errorf("interval below 1")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+			if err == nil && rule.Count < 1 {
+				err = fmt.Errorf("count below 1")
+			}
+		case "UNTIL":
+			// UNTIL is in UTC or, when DTSTART is floating or a date, in its zone
+			r.floating = loc
+			rule.Until, _, err = r.time(Property{Name: "UNTIL", Value: value})
+			if err == nil && len(value) == len("20060102") {
+				// a date includes the whole day
+				rule.Until = rule.Until.AddDate(0, 0, 1).Add(-time.Second)
+			}
+		case "BYMONTH":
+			err = parseList(value, func(v string) error {
+				m, err := strconv.Atoi(v)
+				if err != nil || m < 1 || m > 12 {
+					return fmt.Errorf("BYMONTH=%s", v)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(m))
+				return nil
+			})
+		case "BYMONTHDAY":
+			err = parseList(value, func(v string) error {
+				d, err := strconv.Atoi(v)
+				if err != nil || d == 0 || d < -31 || d > 31 {
+					return fmt.Errorf("BYMONTHDAY=%s", v)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, d)
+				return nil
+			})
+		case "BYDAY":
+			err = parseList(value, func(v string) error {
+				if len(v) < 2 {
+					return fmt.Errorf("BYDAY=%s", v)
+				}
+				wd, ok := weekdays[strings.ToUpper(v[len(v)-2:])]
+				if !ok {
+					return fmt.Errorf("BYDAY=%s", v)
+				}
+				var n int
+				if num := v[:len(v)-2]; num != "" {
+					var err error
+					if n, err = strconv.Atoi(num); err != nil || n == 0 || n < -53 || n > 53 {
+						return fmt.Errorf("BYDAY=%s", v)
+					}
+				}
+				rule.ByDay = append(rule.ByDay, WeekdayNum{N: n, Weekday: wd})
+				return nil
+			})
+		case "WKST":
+			wd, ok := weekdays[strings.ToUpper(value)]
+			if !ok {
+				err = fmt.Errorf("WKST=%s", value)
+			}
+			rule.WeekStart = wd
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedRule, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: RRULE %s: %v", ErrInvalidEvent, name, err)
+		}
+	}
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: RRULE without FREQ", ErrInvalidEvent)
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("%w: RRULE with both COUNT and UNTIL", ErrInvalidEvent)
+	}
+	for _, d := range rule.ByDay {
+		if d.N != 0 && (rule.Freq == "DAILY" || rule.Freq == "WEEKLY" || (rule.Freq == "YEARLY" && len(rule.ByMonth) == 0)) {
+			return nil, fmt.Errorf("%w: BYDAY with a number in a %s rule", ErrUnsupportedRule, rule.Freq)
+		}
+	}
+	return rule, nil
+}
+
+func parseList(value string, f func(v string) error) error {
+	for _, v := range strings.Split(value, ",") {
+		if err := f(strings.TrimSpace(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Starts returns the starts of the occurrences of e in order, up to until and at most limit of them.
+// Occurrences repeat the wall clock time of Start in its zone, so they keep it across DST changes.
+// EXDATEs are left out, RDATEs are added, and e.Start is always the first occurrence unless it's excluded.
+func (e VEvent) Starts(until time.Time, limit int) []time.Time {
+	var starts []time.Time
+	if e.Rule == nil {
+		starts = []time.Time{e.Start}
+	} else {
+		starts = e.Rule.expand(e.Start, until, limit)
+	}
+	for _, rdate := range e.RDates {
+		if !rdate.After(until) && !slices.ContainsFunc(starts, rdate.Equal) {
+			starts = append(starts, rdate)
+		}
+	}
+	starts = slices.DeleteFunc(starts, func(t time.Time) bool {
+		return slices.ContainsFunc(e.ExDates, t.Equal)
+	})
+	slices.SortFunc(starts, func(a, b time.Time) int { return a.Compare(b) })
+	if len(starts) > limit {
+		starts = starts[:limit]
+	}
+	return starts
+}
+
+func (r *Rule) expand(start, until time.Time, limit int) []time.Time {
+	if !r.Until.IsZero() && r.Until.Before(until) {
+		until = r.Until
+	}
+	loc := start.Location()
+	hour, min, sec := start.Clock()
+	at := func(d time.Time) time.Time {
+		return time.Date(d.Year(), d.Month(), d.Day(), hour, min, sec, 0, loc)
+	}
+
+	starts := []time.Time{start}
+	count := 1
+	done := func() bool {
+		return (r.Count > 0 && count >= r.Count) || len(starts) >= limit
+	}
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	for period := 0; !done(); period++ {
+		days, periodStart := r.period(first, period)
+		if periodStart.After(until) {
+			break
+		}
+		for _, d := range days {
+			t := at(d)
+			if !t.After(start) {
+				continue
+			}
+			if t.After(until) || done() {
+				return starts
+			}
+			starts = append(starts, t)
+			count++
+		}
+	}
+	return starts
+}
+
+// period returns the days of the n-th period of the rule from first, in order, and the first day of the period.
+func (r *Rule) period(first time.Time, n int) ([]time.Time, time.Time) {
+	var days []time.Time
+	switch r.Freq {
+	case "DAILY":
+		d := first.AddDate(0, 0, n*r.Interval)
+		if r.matchMonth(d) && r.matchMonthDay(d) && r.matchWeekday(d) {
+			days = append(days, d)
+		}
+		return days, d
+	case "WEEKLY":
+		weekStart := first.AddDate(0, 0, -((int(first.Weekday())-int(r.WeekStart))+7)%7)
+		weekStart = weekStart.AddDate(0, 0, 7*n*r.Interval)
+		for i := 0; i < 7; i++ {
+			d := weekStart.AddDate(0, 0, i)
+			if !r.matchMonth(d) {
+				continue
+			}
+			if len(r.ByDay) > 0 && r.matchWeekday(d) || len(r.ByDay) == 0 && d.Weekday() == first.Weekday() {
+				days = append(days, d)
+			}
+		}
+		return days, weekStart
+	case "MONTHLY":
+		month := time.Date(first.Year(), first.Month()+time.Month(n*r.Interval), 1, 0, 0, 0, 0, first.Location())
+		if r.matchMonth(month) {
+			days = r.monthDays(month, first.Day())
+		}
+		return days, month
+	default: // YEARLY
+		year := time.Date(first.Year()+n*r.Interval, 1, 1, 0, 0, 0, 0, first.Location())
+		months := r.ByMonth
+		if len(months) == 0 {
+			if len(r.ByMonthDay) > 0 || len(r.ByDay) > 0 {
+				months = []time.Month{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+			} else {
+				months = []time.Month{first.Month()}
+			}
+		}
+		slices.Sort(months)
+		for _, m := range months {
+			days = append(days, r.monthDays(time.Date(year.Year(), m, 1, 0, 0, 0, 0, first.Location()), first.Day())...)
+		}
+		return days, year
+	}
+}
+
+// monthDays returns the days of month selected by BYMONTHDAY and BYDAY, or day when neither is set.
+func (r *Rule) monthDays(month time.Time, day int) []time.Time {
+	last := month.AddDate(0, 1, -1).Day()
+	var days []time.Time
+	for d := 1; d <= last; d++ {
+		t := month.AddDate(0, 0, d-1)
+		switch {
+		case len(r.ByMonthDay) == 0 && len(r.ByDay) == 0:
+			if d != day {
+				continue
+			}
+		case len(r.ByMonthDay) > 0 && !r.matchMonthDay(t):
+			continue
+		case len(r.ByDay) > 0 && !r.matchDayInMonth(t, last):
+			continue
+		}
+		days = append(days, t)
+	}
+	return days
+}
+
+func (r *Rule) matchMonth(t time.Time) bool {
+	return len(r.ByMonth) == 0 || slices.Contains(r.ByMonth, t.Month())
+}
+
+func (r *Rule) matchMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := t.AddDate(0, 1, -t.Day()).Day()
+	for _, d := range r.ByMonthDay {
+		if d == t.Day() || d < 0 && last+d+1 == t.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(r.ByDay, func(d WeekdayNum) bool { return d.Weekday == t.Weekday() })
+}
+
+// matchDayInMonth matches BYDAY in a month, where 2MO is the second Monday and -1FR the last Friday.
+func (r *Rule) matchDayInMonth(t time.Time, last int) bool {
+	for _, d := range r.ByDay {
+		if d.Weekday != t.Weekday() {
+			continue
+		}
+		if d.N == 0 || d.N > 0 && (t.Day()-1)/7+1 == d.N || d.N < 0 && (last-t.Day())/7+1 == -d.N {
+			return true
+		}
+	}
+	return false
+}
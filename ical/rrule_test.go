@@ -0,0 +1,110 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// event reads the VEVENT with the properties lines, floating times are in UTC.
+func event(t *testing.T, lines ...string) VEvent {
+	t.Helper()
+	text := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:e\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	cal, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := Events(cal, time.UTC)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	return events[0]
+}
+
+func TestStarts(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(s string) time.Time {
+		t, _ := time.Parse("2006-01-02 15:04", s)
+		return t
+	}
+	until := utc("2030-01-01 00:00")
+	tests := []struct {
+		name  string
+		lines []string
+		until time.Time
+		limit int
+		want  []time.Time
+	}{
+		{"single event", []string{"DTSTART:20231001T120000"}, until, 10,
+			[]time.Time{utc("2023-10-01 12:00")}},
+		{"daily by count", []string{"DTSTART:20231001T120000", "RRULE:FREQ=DAILY;COUNT=3"}, until, 10,
+			[]time.Time{utc("2023-10-01 12:00"), utc("2023-10-02 12:00"), utc("2023-10-03 12:00")}},
+		{"until a date includes the day", []string{"DTSTART:20231001T120000", "RRULE:FREQ=DAILY;UNTIL=20231003"}, until, 10,
+			[]time.Time{utc("2023-10-01 12:00"), utc("2023-10-02 12:00"), utc("2023-10-03 12:00")}},
+		{"weekly on two days", []string{"DTSTART:20231002T090000", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4"}, until, 10,
+			[]time.Time{utc("2023-10-02 09:00"), utc("2023-10-04 09:00"), utc("2023-10-09 09:00"), utc("2023-10-11 09:00")}},
+		{"every other week", []string{"DTSTART:20231002T090000", "RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3"}, until, 10,
+			[]time.Time{utc("2023-10-02 09:00"), utc("2023-10-16 09:00"), utc("2023-10-30 09:00")}},
+		{"last friday of the month", []string{"DTSTART:20231027T150000", "RRULE:FREQ=MONTHLY;BYDAY=-1FR;COUNT=3"}, until, 10,
+			[]time.Time{utc("2023-10-27 15:00"), utc("2023-11-24 15:00"), utc("2023-12-29 15:00")}},
+		{"31st skips shorter months", []string{"DTSTART:20240131T100000", "RRULE:FREQ=MONTHLY;COUNT=3"}, until, 10,
+			[]time.Time{utc("2024-01-31 10:00"), utc("2024-03-31 10:00"), utc("2024-05-31 10:00")}},
+		{"last day of the month", []string{"DTSTART:20240131T100000", "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3"}, until, 10,
+			[]time.Time{utc("2024-01-31 10:00"), utc("2024-02-29 10:00"), utc("2024-03-31 10:00")}},
+		{"29 february in leap years", []string{"DTSTART:20240229T100000", "RRULE:FREQ=YEARLY;COUNT=2"}, until, 10,
+			[]time.Time{utc("2024-02-29 10:00"), utc("2028-02-29 10:00")}},
+		{"keeps the wall clock over DST", []string{"DTSTART;TZID=Europe/Berlin:20231028T090000", "RRULE:FREQ=DAILY;COUNT=2"}, until, 10,
+			[]time.Time{time.Date(2023, 10, 28, 9, 0, 0, 0, berlin), time.Date(2023, 10, 29, 9, 0, 0, 0, berlin)}},
+		{"exdate and rdate", []string{"DTSTART:20231002T090000", "RRULE:FREQ=WEEKLY;COUNT=3", "EXDATE:20231009T090000", "RDATE:20231005T140000"}, until, 10,
+			[]time.Time{utc("2023-10-02 09:00"), utc("2023-10-05 14:00"), utc("2023-10-16 09:00")}},
+		{"endless up to until", []string{"DTSTART:20231001T120000", "RRULE:FREQ=WEEKLY"}, utc("2023-10-20 00:00"), 10,
+			[]time.Time{utc("2023-10-01 12:00"), utc("2023-10-08 12:00"), utc("2023-10-15 12:00")}},
+		{"endless up to the limit", []string{"DTSTART:20231001T120000", "RRULE:FREQ=YEARLY"}, until, 2,
+			[]time.Time{utc("2023-10-01 12:00"), utc("2024-10-01 12:00")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := event(t, tt.lines...)
+			if e.Err != nil {
+				t.Fatal(e.Err)
+			}
+			got := e.Starts(tt.until, tt.limit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Starts() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("start %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRuleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		want error
+	}{
+		{"hourly", "FREQ=HOURLY;COUNT=3", ErrUnsupportedRule},
+		{"by set position", "FREQ=MONTHLY;BYDAY=MO;BYSETPOS=1", ErrUnsupportedRule},
+		{"numbered weekday in a weekly rule", "FREQ=WEEKLY;BYDAY=2MO", ErrUnsupportedRule},
+		{"count and until", "FREQ=DAILY;COUNT=3;UNTIL=20231010", ErrInvalidEvent},
+		{"without freq", "COUNT=3", ErrInvalidEvent},
+		{"interval 0", "FREQ=DAILY;INTERVAL=0", ErrInvalidEvent},
+		{"month 13", "FREQ=YEARLY;BYMONTH=13", ErrInvalidEvent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := event(t, "DTSTART:20231001T120000", "RRULE:"+tt.rule)
+			if !errors.Is(e.Err, tt.want) {
+				t.Errorf("error %v, want %v", e.Err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/subliker/backendproj/config"
+)
+
+const redacted = "<redacted>"
+
+// secretKeys are attributes that are never logged.
+var secretKeys = map[string]bool{
+	"password":      true,
+	"new_password":  true,
+	"secret":        true,
+	"token":         true,
+	"authorization": true,
+	"api_key":       true,
+	"x-api-key":     true,
+	"cookie":        true,
+	"dsn":           true,
+}
+
+// piiKeys are attributes that are logged masked: only the first character stays.
+var piiKeys = map[string]bool{
+	"username": true,
+	"email":    true,
+	"comment":  true,
+	"phone":    true,
+}
+
+// New returns a logger writing to w with the level and format from cfg.
+// Secrets and personal data are redacted by attribute key, at any group depth.
+func New(w io.Writer, cfg config.Log) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case secretKeys[key]:
+		return slog.String(a.Key, redacted)
+	case piiKeys[key]:
+		return slog.String(a.Key, Mask(a.Value.String()))
+	}
+	return a
+}
+
+// Mask hides everything but the first character of s.
+func Mask(s string) string {
+	if s == "" {
+		return ""
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return string(r) + "***"
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger stored by WithLogger, or slog.Default.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/subliker/backendproj/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewRedacts(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, config.Log{Level: "info", Format: "json"})
+	logger.WithGroup("request").Info("login",
+		"Password", "correct horse",
+		slog.Group("user", "username", "Андрей", slog.Group("auth", "token", "abc", "email", "a@example.com")),
+		"status", 200,
+	)
+
+	var entry struct {
+		Request struct {
+			Password string `json:"Password"`
+			Status   int    `json:"status"`
+			User     struct {
+				Username string `json:"username"`
+				Auth     struct {
+					Token string `json:"token"`
+					Email string `json:"email"`
+				} `json:"auth"`
+			} `json:"user"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %s", err, &buf)
+	}
+	r := entry.Request
+	if r.Password != redacted || r.User.Auth.Token != redacted {
+		t.Errorf("password %q, token %q, want both %s", r.Password, r.User.Auth.Token, redacted)
+	}
+	if r.User.Username != "А***" || r.User.Auth.Email != "a***" {
+		t.Errorf("username %q, email %q, want them masked", r.User.Username, r.User.Auth.Email)
+	}
+	if r.Status != 200 {
+		t.Errorf("status %d, want 200 as is", r.Status)
+	}
+	if strings.Contains(buf.String(), "correct horse") || strings.Contains(buf.String(), "example.com") {
+		t.Errorf("secrets leaked: %s", &buf)
+	}
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name, header string
+		kept         bool
+	}{
+		{"sane", "req-42.a_b", true},
+		{"missing", "", false},
+		{"newline", "req\nforged=1", false},
+		{"space", "req 42", false},
+		{"non-ASCII", "запрос", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"longest", strings.Repeat("a", maxRequestIDLength), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			router := gin.New()
+			router.Use(Middleware(New(&buf, config.Log{Format: "json"})))
+			var fromContext string
+			router.GET("/", func(c *gin.Context) { fromContext = RequestID(c.Request.Context()) })
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, tt.header)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.kept && id != tt.header {
+				t.Errorf("request ID %q, want %q kept", id, tt.header)
+			}
+			if !tt.kept && (id == tt.header || len(id) != 32) {
+				t.Errorf("request ID %q, want a generated one", id)
+			}
+			if fromContext != id {
+				t.Errorf("request ID in context %q, want %q", fromContext, id)
+			}
+			var entry struct {
+				RequestID string `json:"request_id"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil || entry.RequestID != id {
+				t.Errorf("logged request_id %q (%v), want %q", entry.RequestID, err, id)
+			}
+		})
+	}
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
-	"github.com/subliker/backendproj/route"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
-	docs "github.com/subliker/backendproj/docs"
-
-	"github.com/gin-gonic/gin"
-
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/subliker/backendproj/app"
+	"github.com/subliker/backendproj/config"
+	"github.com/subliker/backendproj/logging"
 )
 
 // @BasePath /api/v1
@@ -16,30 +20,75 @@ import (
 // @title CyberZoneDev test REST API project
 // @description This rest api is designed to work with the PostgreSQL database. There are two main entities: User and Booking. One user can have multiple Bookings
 
-var DataBase = route.DataBase
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key or admin token, "Authorization: Bearer <key>" works too
 
-func SetupRouter() *gin.Engine {
-	router := gin.Default()
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
 
-	docs.SwaggerInfo.BasePath = "/api"
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logging.New(os.Stdout, cfg.Log))
 
-	router.GET("/api/user/:id", route.GetUserDataById)
-	router.POST("/api/user", route.AddNewUser)
-	router.DELETE("/api/user/:id", route.DeleteUserDataByID)
-	router.PUT("/api/user/:id", route.UpdateUserDataById)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	router.GET("/api/booking/:id", route.GetBookingDataById)
-	router.GET("/api/booking", route.GetBookings)
-	router.POST("/api/booking", route.AddNewBooking)
-	router.DELETE("/api/booking/:id", route.DeleteBookingByID)
-	router.PUT("/api/booking/:id", route.UpdateBookingDataById)
+	a, err := app.New(ctx, cfg)
+	if err != nil {
+		slog.Error("start", "err", err)
+		os.Exit(1)
+	}
+	slog.Info("listening", "addr", cfg.HTTP.Addr)
 
-	router.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Start()
+	}()
+	// failed is set when the server stopped on its own, e.g. the port is in use,
+	// so a supervisor restarting on failure sees a non-zero exit
+	failed := false
+	select {
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("serve", "err", err)
+			failed = true
+		}
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	}
 
-	return router
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := a.Shutdown(shutdownCtx); err != nil {
+		slog.Error("shutdown", "err", err)
+		os.Exit(1)
+	}
+	if failed {
+		os.Exit(1)
+	}
 }
 
-func main() {
-	router := SetupRouter()
-	router.Run(":8000")
+// configCommand runs "config print [flags]", it prints the effective configuration with secrets redacted.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: backendproj config print [flags]")
+		return 2
+	}
+	cfg, err := config.Load(args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := cfg.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
 }
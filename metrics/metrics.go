@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric of the service, it is served by Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route template, method and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route template and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Duration of db.DataBase methods.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method"})
+
+	// BookingsActive is the number of bookings that haven't ended yet.
+	BookingsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "bookings_active",
+		Help: "Bookings whose end_time is in the future.",
+	})
+
+	// BookingConflicts counts bookings rejected because they overlap another booking of their resource,
+	// or of the user when neither has a resource: created, updated or restored alone, in a batch or by an import.
+	// There's no metric of expired holds, a booking isn't held before it's made.
+	BookingConflicts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "booking_conflicts_rejected_total",
+		Help: "Bookings rejected because they overlap another booking of the resource, or of the user when neither has a resource.",
+	})
+
+	// WebhookDeliveries counts delivery attempts by result: delivered, failed or dead.
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_deliveries_total",
+		Help: "Webhook delivery attempts by result: delivered, failed (will be retried) or dead.",
+	}, []string{"result"})
+
+	// OutboxPublished counts outbox events published to every sink.
+	OutboxPublished = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "outbox_published_total",
+		Help: "Outbox events published to every sink.",
+	})
+
+	// OutboxFailures counts failed attempts to publish an outbox event by sink.
+	OutboxFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_publish_failures_total",
+		Help: "Failed attempts to publish an outbox event by sink, the event is retried.",
+	}, []string{"sink"})
+
+	// OutboxPending is the number of outbox events that aren't published yet.
+	OutboxPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_pending",
+		Help: "Outbox events that aren't published yet.",
+	})
+
+	// StreamSubscribers is the number of open booking streams (SSE and WebSocket).
+	StreamSubscribers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "stream_subscribers",
+		Help: "Open booking change streams over SSE and WebSocket.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, queryDuration, BookingsActive, BookingConflicts, WebhookDeliveries,
+		OutboxPublished, OutboxFailures, OutboxPending, StreamSubscribers,
+	)
+}
+
+// RegisterDB exposes connection pool statistics of db.
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry}))
+}
+
+// methods are the request methods labelled as they are, others are "other".
+var methods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodConnect: true, http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// Middleware counts requests and observes their latency.
+// Routes are labelled by template (/api/booking/:id), not by path, and methods
+// outside the standard ones as "other" to keep cardinality low.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		if !methods[method] {
+			method = "other"
+		}
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveQuery starts timing a db.DataBase method, call the returned func when it's done:
+//
+//	defer metrics.ObserveQuery("GetUserDataByID")()
+func ObserveQuery(method string) func() {
+	start := time.Now()
+	return func() {
+		queryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	}
+}
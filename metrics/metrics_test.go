@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareMethods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	for _, method := range []string{http.MethodGet, "PURGE", "X-RANDOM-1", "X-RANDOM-2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/metrics-test", nil))
+	}
+	for method, want := range map[string]float64{http.MethodGet: 1, "other": 3, "PURGE": 0} {
+		if got := testutil.ToFloat64(httpRequests.WithLabelValues(method, "unmatched", "404")); got != want {
+			t.Errorf("%s requests = %v, want %v", method, got, want)
+		}
+	}
+}
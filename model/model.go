@@ -1,5 +1,12 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
 // AddNewUser provides data to create a User.
 //
 // swagger:model
@@ -9,14 +16,29 @@ type User struct {
 	//min_length = 20
 	//exclude = \"\\\/
 	Username string `json:"username" db:"username" example:"Andrew"`
-	//min_length = 6
-	//min_length = 20
-	//exclude = \"\\\/
-	Password string `json:"password" db:"password" example:"$2a$14$kv/sGmTWIlNYocbZqd88GuRsrOtKrs9bBFMM7N7HRNZ.qPxF.b.GG"`
-	//YYYY-MM-DD HH:MM:SS
-	Created_at string `json:"created_at" db:"created_at" example:"2023-09-24T17:13:42Z"`
-	//YYYY-MM-DD HH:MM:SS
-	Updated_at string `json:"updated_at" db:"updated_at" example:"2023-09-27T11:10:23Z"`
+	//lowercased username with homoglyphs replaced, unique
+	Username_skeleton string `json:"-" db:"username_skeleton"`
+	//hash of the password, never returned; plain password rules are in datavalidator.PasswordPolicy
+	Password string `json:"-" db:"password"`
+	//IANA time zone, used for times given without an offset
+	Timezone string `json:"timezone" db:"timezone" example:"Europe/Moscow"`
+	//RFC 3339
+	Created_at time.Time `json:"created_at" db:"created_at" example:"2023-09-24T20:13:42+03:00"`
+	//RFC 3339
+	Updated_at time.Time `json:"updated_at" db:"updated_at" example:"2023-09-27T14:10:23+03:00"`
+	//RFC 3339, set while the user is deleted and can be restored
+	Deleted_at *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// In returns the user with times converted to loc.
+func (u User) In(loc *time.Location) User {
+	u.Created_at = u.Created_at.In(loc)
+	u.Updated_at = u.Updated_at.In(loc)
+	if u.Deleted_at != nil {
+		t := u.Deleted_at.In(loc)
+		u.Deleted_at = &t
+	}
+	return u
 }
 
 // AddNewBooking provides data to create a Booking.
@@ -25,12 +47,221 @@ type User struct {
 type Booking struct {
 	Id      int `json:"id" db:"id" example:"1021"`
 	User_id int `json:"user_id" db:"user_id" example:"906"`
-	//YYYY-MM-DD HH:MM:SS
-	Start_time string `json:"start_time" db:"start_time" example:"2023-10-01T12:00:00Z"`
-	//YYYY-MM-DD HH:MM:SS
-	End_time string `json:"end_time" db:"end_time" example:"2023-10-01T14:30:00Z"`
+	//room or other resource the booking takes, it can't be booked twice at once
+	Resource_id *int `json:"resource_id,omitempty" db:"resource_id" example:"4"`
+	//RFC 3339
+	Start_time time.Time `json:"start_time" db:"start_time" example:"2023-10-01T15:00:00+03:00"`
+	//RFC 3339
+	End_time time.Time `json:"end_time" db:"end_time" example:"2023-10-01T17:30:00+03:00"`
 	//min_length = 5
 	//min_length = 120
 	//exclude = \"\\\/
 	Comment string `json:"comment" db:"comment" example:"I may be a little late"`
+	//incremented on every update, it's the SEQUENCE in calendar feeds
+	Sequence int `json:"-" db:"sequence"`
+	//RFC 3339, set while the booking is deleted and can be restored
+	Deleted_at *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// In returns the booking with times converted to loc.
+func (b Booking) In(loc *time.Location) Booking {
+	b.Start_time = b.Start_time.In(loc)
+	b.End_time = b.End_time.In(loc)
+	if b.Deleted_at != nil {
+		t := b.Deleted_at.In(loc)
+		b.Deleted_at = &t
+	}
+	return b
+}
+
+// Resource is a room or anything else that is booked, a booking of it can't overlap another one.
+//
+// swagger:model
+type Resource struct {
+	Id   int    `json:"id" db:"id" example:"4"`
+	Name string `json:"name" db:"name" example:"Meeting room 2"`
+	//IANA time zone, used for times of its bookings given without an offset
+	Timezone string `json:"timezone" db:"timezone" example:"Europe/Moscow"`
+	//RFC 3339
+	Created_at time.Time `json:"created_at" db:"created_at" example:"2023-09-24T20:13:42+03:00"`
+	//RFC 3339
+	Updated_at time.Time `json:"updated_at" db:"updated_at" example:"2023-09-27T14:10:23+03:00"`
+}
+
+// In returns the resource with times converted to loc.
+func (r Resource) In(loc *time.Location) Resource {
+	r.Created_at = r.Created_at.In(loc)
+	r.Updated_at = r.Updated_at.In(loc)
+	return r
+}
+
+// CalendarToken lets calendar apps fetch the feed of a user without an API key.
+// Like API keys, only its SHA-256 hash is stored.
+type CalendarToken struct {
+	User_id    int       `json:"user_id" db:"user_id" example:"906"`
+	Prefix     string    `json:"prefix" db:"prefix" example:"cal_Xq3k9Fz"`
+	Token_hash string    `json:"-" db:"token_hash"`
+	Created_at time.Time `json:"created_at" db:"created_at" example:"2023-10-01T15:00:00+03:00"`
+}
+
+// In returns the token with times converted to loc.
+func (t CalendarToken) In(loc *time.Location) CalendarToken {
+	t.Created_at = t.Created_at.In(loc)
+	return t
+}
+
+// APIKey is a credential of a service client. The key itself is shown
+// only when it's created or rotated, only its SHA-256 hash is stored.
+//
+// swagger:model
+type APIKey struct {
+	Id   int    `json:"id" db:"id" example:"12"`
+	Name string `json:"name" db:"name" example:"nightly export"`
+	//first characters of the key, to tell keys apart
+	Prefix   string `json:"prefix" db:"prefix" example:"bk_Xq3k9Fz"`
+	Key_hash string `json:"-" db:"key_hash"`
+	//permissions, e.g. bookings:read
+	Scopes pq.StringArray `json:"scopes" db:"scopes" swaggertype:"array,string" example:"bookings:read,bookings:write"`
+	//when set, the key works only with this user and their bookings
+	User_id *int `json:"user_id,omitempty" db:"user_id" example:"906"`
+	//when set, the key works only with this resource and its bookings
+	Resource_id *int `json:"resource_id,omitempty" db:"resource_id" example:"4"`
+	//RFC 3339
+	Created_at time.Time `json:"created_at" db:"created_at" example:"2023-09-24T20:13:42+03:00"`
+	//RFC 3339, the key doesn't work after it
+	Expires_at   *time.Time `json:"expires_at,omitempty" db:"expires_at" example:"2024-09-24T00:00:00Z"`
+	Last_used_at *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	Rotated_at   *time.Time `json:"rotated_at,omitempty" db:"rotated_at"`
+	Revoked_at   *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// Active reports whether the key can be used at now.
+func (k APIKey) Active(now time.Time) bool {
+	return k.Revoked_at == nil && (k.Expires_at == nil || now.Before(*k.Expires_at))
+}
+
+// In returns the key with times converted to loc.
+func (k APIKey) In(loc *time.Location) APIKey {
+	k.Created_at = k.Created_at.In(loc)
+	for _, t := range []**time.Time{&k.Expires_at, &k.Last_used_at, &k.Rotated_at, &k.Revoked_at} {
+		if *t != nil {
+			in := (*t).In(loc)
+			*t = &in
+		}
+	}
+	return k
+}
+
+// Webhook is a subscription of a URL to events.
+//
+// swagger:model
+type Webhook struct {
+	Id  int    `json:"id" db:"id" example:"3"`
+	Url string `json:"url" db:"url" example:"https://billing.example.com/hooks/bookings"`
+	//HMAC-SHA256 key of the signatures, shown only when the webhook is created
+	Secret string `json:"-" db:"secret"`
+	//event types, e.g. booking.created
+	Events pq.StringArray `json:"events" db:"events" swaggertype:"array,string" example:"booking.created,booking.deleted"`
+	//inactive webhooks get no new deliveries
+	Active bool `json:"active" db:"active" example:"true"`
+	//RFC 3339
+	Created_at time.Time `json:"created_at" db:"created_at" example:"2023-09-24T20:13:42+03:00"`
+	//RFC 3339
+	Updated_at time.Time `json:"updated_at" db:"updated_at" example:"2023-09-27T14:10:23+03:00"`
+}
+
+// In returns the webhook with times converted to loc.
+func (w Webhook) In(loc *time.Location) Webhook {
+	w.Created_at = w.Created_at.In(loc)
+	w.Updated_at = w.Updated_at.In(loc)
+	return w
+}
+
+// delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	//attempts are exhausted, the delivery waits for a manual redelivery
+	DeliveryDead = "dead"
+)
+
+// WebhookDelivery is an event sent or to be sent to a webhook.
+//
+// swagger:model
+type WebhookDelivery struct {
+	Id         int64  `json:"id" db:"id" example:"5812"`
+	Webhook_id int    `json:"webhook_id" db:"webhook_id" example:"3"`
+	Event_id   string `json:"event_id" db:"event_id" example:"evt_5f0c6a3e9b1d4c2a8e7f6b5a4c3d2e1f"`
+	Event      string `json:"event" db:"event" example:"booking.created"`
+	//the request body
+	Payload json.RawMessage `json:"payload" db:"payload" swaggertype:"object"`
+	//pending, delivered or dead
+	Status   string `json:"status" db:"status" example:"pending"`
+	Attempts int    `json:"attempts" db:"attempts" example:"2"`
+	//RFC 3339
+	Next_attempt_at  time.Time  `json:"next_attempt_at" db:"next_attempt_at" example:"2023-10-01T15:02:00Z"`
+	Last_status_code *int       `json:"last_status_code,omitempty" db:"last_status_code" example:"503"`
+	Last_error       *string    `json:"last_error,omitempty" db:"last_error" example:"unexpected status 503"`
+	Created_at       time.Time  `json:"created_at" db:"created_at" example:"2023-10-01T15:00:00Z"`
+	Delivered_at     *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
+}
+
+// In returns the delivery with times converted to loc.
+func (d WebhookDelivery) In(loc *time.Location) WebhookDelivery {
+	d.Next_attempt_at = d.Next_attempt_at.In(loc)
+	d.Created_at = d.Created_at.In(loc)
+	if d.Delivered_at != nil {
+		t := d.Delivered_at.In(loc)
+		d.Delivered_at = &t
+	}
+	return d
+}
+
+// OutboxMessage is an event written in the transaction of the change it's about,
+// it's published to the sinks after the transaction commits.
+type OutboxMessage struct {
+	Id int64 `json:"id" db:"id"`
+	//user or booking
+	Aggregate_type string `json:"aggregate_type" db:"aggregate_type"`
+	Aggregate_id   string `json:"aggregate_id" db:"aggregate_id"`
+	Event_id       string `json:"event_id" db:"event_id"`
+	Event_type     string `json:"event_type" db:"event_type"`
+	//the event as JSON
+	Payload         json.RawMessage `json:"payload" db:"payload"`
+	Attempts        int             `json:"attempts" db:"attempts"`
+	Next_attempt_at time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	Last_error      *string         `json:"last_error,omitempty" db:"last_error"`
+	Created_at      time.Time       `json:"created_at" db:"created_at"`
+	Published_at    *time.Time      `json:"published_at,omitempty" db:"published_at"`
+}
+
+// AuditEntry records a change: who made it, when and what changed.
+// Every entry has the hash of the previous one, so a changed or removed entry breaks the chain.
+//
+// swagger:model
+type AuditEntry struct {
+	Id int64 `json:"id" db:"id" example:"52311"`
+	//RFC 3339
+	Created_at time.Time `json:"created_at" db:"created_at" example:"2023-10-01T15:02:11.482913Z"`
+	//admin, api_key, anonymous or system
+	Actor      string `json:"actor" db:"actor" example:"api_key"`
+	Api_key_id *int   `json:"api_key_id,omitempty" db:"api_key_id" example:"7"`
+	Request_id string `json:"request_id,omitempty" db:"request_id" example:"f3b1c2d4e5a6978812345678"`
+	Ip         string `json:"ip,omitempty" db:"ip" example:"203.0.113.24"`
+	//user, booking, resource, api_key, webhook, webhook_delivery or calendar_token
+	Entity    string `json:"entity" db:"entity" example:"booking"`
+	Entity_id int    `json:"entity_id" db:"entity_id" example:"1021"`
+	//create, update, delete, restore, purge, rotate, revoke or redeliver
+	Operation string `json:"operation" db:"operation" example:"update"`
+	//changed fields with their values before and after, secrets are "[redacted]"
+	Diff json.RawMessage `json:"diff" db:"diff" swaggertype:"object"`
+	//hex SHA-256 of the previous entry, "" for the first one
+	Prev_hash string `json:"prev_hash" db:"prev_hash" example:"9c1185a5c5e9fc54612808977ee8f548b2258d31"`
+	Hash      string `json:"hash" db:"hash" example:"e3b0c44298fc1c149afbf4c8996fb92427ae41e4"`
+}
+
+// In returns the entry with times converted to loc.
+func (e AuditEntry) In(loc *time.Location) AuditEntry {
+	e.Created_at = e.Created_at.In(loc)
+	return e
 }
@@ -0,0 +1,151 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/subliker/backendproj/config"
+	"github.com/subliker/backendproj/metrics"
+	"github.com/subliker/backendproj/model"
+	"github.com/subliker/backendproj/retry"
+)
+
+// Sink publishes outbox messages somewhere, e.g. to webhooks or a message broker.
+// Publish may get a message again after it succeeded, sinks or their consumers dedupe by Event_id.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, msg model.OutboxMessage) error
+}
+
+// Store is the storage of the outbox, *db.DataBase in production.
+type Store interface {
+	ClaimOutbox(ctx context.Context, limit int, leaseUntil time.Time) ([]model.OutboxMessage, error)
+	MarkOutboxPublished(ctx context.Context, id int64) error
+	MarkOutboxFailed(ctx context.Context, id int64, reason string, next time.Time) error
+	PurgeOutbox(ctx context.Context, before time.Time) (int64, error)
+	CountUnpublished(ctx context.Context) (int, error)
+}
+
+// Relay publishes the messages written to the outbox to every sink.
+//
+// A message is published at least once: it's marked published only after every sink took it,
+// a failed one is retried with all the sinks. Messages of one aggregate (a user or a booking)
+// are published in the order they were written, a failing message holds back the later ones.
+type Relay struct {
+	Store  Store
+	Sinks  []Sink
+	Config config.Outbox
+}
+
+func NewRelay(store Store, sinks []Sink, cfg config.Outbox) *Relay {
+	return &Relay{Store: store, Sinks: sinks, Config: cfg}
+}
+
+// purgeInterval is how often published messages older than the retention are deleted.
+const purgeInterval = time.Hour
+
+// Run publishes due messages every PollInterval until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Config.PollInterval)
+	defer ticker.Stop()
+	var purged time.Time
+	for {
+		// publishing a message may make the next one of its aggregate due
+		for {
+			n, err := r.PublishDue(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "claim outbox messages", "err", err)
+			}
+			if err != nil || n == 0 {
+				break
+			}
+		}
+		if pending, err := r.Store.CountUnpublished(ctx); err == nil {
+			metrics.OutboxPending.Set(float64(pending))
+		}
+		if r.Config.Retention > 0 && time.Since(purged) >= purgeInterval {
+			purged = time.Now()
+			if n, err := r.Store.PurgeOutbox(ctx, purged.Add(-r.Config.Retention)); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "purge outbox", "err", err)
+			} else if n > 0 {
+				slog.InfoContext(ctx, "outbox purged", "messages", n)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishDue publishes one batch of due messages at once and returns its size.
+// The batch has at most one message of each aggregate, so they don't have to be in order.
+func (r *Relay) PublishDue(ctx context.Context) (int, error) {
+	// a message that isn't marked by then, e.g. the instance died, is claimed again
+	leaseUntil := time.Now().Add(time.Duration(len(r.Sinks)+1)*r.Config.Timeout + time.Minute)
+	messages, err := r.Store.ClaimOutbox(ctx, r.Config.BatchSize, leaseUntil)
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, msg := range messages {
+		wg.Add(1)
+		go func(msg model.OutboxMessage) {
+			defer wg.Done()
+			r.publish(ctx, msg)
+		}(msg)
+	}
+	wg.Wait()
+	return len(messages), nil
+}
+
+// publish gives msg to every sink and records the result.
+func (r *Relay) publish(ctx context.Context, msg model.OutboxMessage) {
+	log := slog.Default().With("outbox_id", msg.Id, "event_id", msg.Event_id, "event", msg.Event_type,
+		"aggregate", msg.Aggregate_type+":"+msg.Aggregate_id)
+	var errs []error
+	for _, sink := range r.Sinks {
+		sinkCtx, cancel := context.WithTimeout(ctx, r.Config.Timeout)
+		err := sink.Publish(sinkCtx, msg)
+		cancel()
+		if err != nil {
+			metrics.OutboxFailures.WithLabelValues(sink.Name()).Inc()
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	if len(errs) == 0 {
+		metrics.OutboxPublished.Inc()
+		if err := r.Store.MarkOutboxPublished(ctx, msg.Id); err != nil {
+			log.WarnContext(ctx, "mark outbox message published", "err", err)
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		// shutting down, the lease runs out and the message is published again
+		return
+	}
+
+	err := errors.Join(errs...)
+	next := time.Now().Add(retry.Backoff(r.Config.RetryInitial, r.Config.RetryMax, msg.Attempts+1))
+	log.WarnContext(ctx, "publish outbox message failed, retrying", "attempts", msg.Attempts+1, "next_attempt_at", next, "err", err)
+	if err := r.Store.MarkOutboxFailed(ctx, msg.Id, retry.Truncate(err.Error()), next); err != nil {
+		log.WarnContext(ctx, "mark outbox message failed", "err", err)
+	}
+}
+
+// Close closes the sinks that hold connections, call it after Run returned.
+func (r *Relay) Close() error {
+	var errs []error
+	for _, sink := range r.Sinks {
+		if c, ok := sink.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,185 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/subliker/backendproj/config"
+	"github.com/subliker/backendproj/model"
+)
+
+// fakeStore claims like db.ClaimOutbox: the oldest unpublished due message of each aggregate.
+type fakeStore struct {
+	mu       sync.Mutex
+	messages []model.OutboxMessage
+	now      time.Time
+}
+
+func (s *fakeStore) ClaimOutbox(_ context.Context, limit int, leaseUntil time.Time) ([]model.OutboxMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var claimed []model.OutboxMessage
+	blocked := map[string]bool{}
+	for i := range s.messages {
+		msg := &s.messages[i]
+		if msg.Published_at != nil {
+			continue
+		}
+		if !blocked[aggregate(*msg)] && !msg.Next_attempt_at.After(s.now) && len(claimed) < limit {
+			msg.Next_attempt_at = leaseUntil
+			claimed = append(claimed, *msg)
+		}
+		blocked[aggregate(*msg)] = true
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) message(id int64) *model.OutboxMessage {
+	for i := range s.messages {
+		if s.messages[i].Id == id {
+			return &s.messages[i]
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) MarkOutboxPublished(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := s.message(id)
+	now := s.now
+	msg.Published_at, msg.Last_error = &now, nil
+	msg.Attempts++
+	return nil
+}
+
+func (s *fakeStore) MarkOutboxFailed(_ context.Context, id int64, reason string, next time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := s.message(id)
+	msg.Attempts++
+	msg.Last_error, msg.Next_attempt_at = &reason, next
+	return nil
+}
+
+func (s *fakeStore) PurgeOutbox(context.Context, time.Time) (int64, error) { return 0, nil }
+func (s *fakeStore) CountUnpublished(context.Context) (int, error)         { return 0, nil }
+
+// recordingSink records what it published and fails the messages in fail once each.
+type recordingSink struct {
+	mu        sync.Mutex
+	name      string
+	fail      map[int64]bool
+	published []model.OutboxMessage
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Publish(_ context.Context, msg model.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail[msg.Id] {
+		delete(s.fail, msg.Id)
+		return errors.New("broker unavailable")
+	}
+	s.published = append(s.published, msg)
+	return nil
+}
+
+// order returns the ids published for each aggregate.
+func (s *recordingSink) order() map[string][]int64 {
+	order := map[string][]int64{}
+	for _, msg := range s.published {
+		order[aggregate(msg)] = append(order[aggregate(msg)], msg.Id)
+	}
+	return order
+}
+
+func TestRelayPublishesEachAggregateInOrder(t *testing.T) {
+	cfg := config.Outbox{Timeout: time.Second, BatchSize: 10, RetryInitial: time.Minute, RetryMax: time.Hour}
+	tests := []struct {
+		name string
+		// fail are the messages the second sink fails once
+		fail map[int64]bool
+		// rounds are the ids the second sink published in each round, a retry is due a round later
+		rounds [][]int64
+	}{
+		{"nothing fails", nil, [][]int64{{1, 2}, {3, 4}, {5}, nil}},
+		{"a failure holds back its aggregate only", map[int64]bool{1: true}, [][]int64{{2}, {1, 4}, {3}, {5}, nil}},
+		{"a failure of the last message", map[int64]bool{5: true}, [][]int64{{1, 2}, {3, 4}, nil, {5}, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// booking 1 has messages 1, 3 and 5, booking 2 has 2 and 4
+			start := time.Now()
+			store := &fakeStore{now: start}
+			for id := int64(1); id <= 5; id++ {
+				booking := strconv.FormatInt(2-id%2, 10)
+				store.messages = append(store.messages, model.OutboxMessage{Id: id, Aggregate_type: "booking", Aggregate_id: booking, Next_attempt_at: start})
+			}
+			first := &recordingSink{name: "first"}
+			second := &recordingSink{name: "second", fail: tt.fail}
+			relay := NewRelay(store, []Sink{first, second}, cfg)
+
+			for round, want := range tt.rounds {
+				before := len(second.published)
+				if _, err := relay.PublishDue(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				var got []int64
+				for _, msg := range second.published[before:] {
+					got = append(got, msg.Id)
+				}
+				slices.Sort(got)
+				if !slices.Equal(got, want) {
+					t.Fatalf("round %d published %v, want %v", round, got, want)
+				}
+				// a failed message is due again within 1.5 times RetryInitial
+				store.now = store.now.Add(2 * cfg.RetryInitial)
+			}
+
+			want := map[string][]int64{"booking:1": {1, 3, 5}, "booking:2": {2, 4}}
+			for _, sink := range []*recordingSink{first, second} {
+				order := sink.order()
+				for agg, ids := range want {
+					// the first sink gets a failed message again, it's retried with every sink
+					got := slices.Compact(order[agg])
+					if !slices.Equal(got, ids) {
+						t.Errorf("%s got %s in order %v, want %v", sink.name, agg, order[agg], ids)
+					}
+				}
+			}
+			for _, msg := range store.messages {
+				if msg.Published_at == nil {
+					t.Errorf("message %d isn't published", msg.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestRelayRecordsFailures(t *testing.T) {
+	start := time.Now()
+	store := &fakeStore{now: start, messages: []model.OutboxMessage{{Id: 1, Aggregate_type: "user", Aggregate_id: "7", Attempts: 2, Next_attempt_at: start}}}
+	sink := &recordingSink{name: "kafka", fail: map[int64]bool{1: true}}
+	cfg := config.Outbox{Timeout: time.Second, BatchSize: 10, RetryInitial: time.Minute, RetryMax: time.Hour}
+	if _, err := NewRelay(store, []Sink{sink}, cfg).PublishDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	msg := store.messages[0]
+	if msg.Published_at != nil || msg.Attempts != 3 {
+		t.Fatalf("published %v after %d attempts, want unpublished after 3", msg.Published_at, msg.Attempts)
+	}
+	if msg.Last_error == nil || *msg.Last_error != "kafka: broker unavailable" {
+		t.Errorf("last error %v, want the sink and its error", msg.Last_error)
+	}
+	// the third failed attempt waits RetryInitial doubled twice, with up to 50% jitter
+	if delay := msg.Next_attempt_at.Sub(start); delay < 4*time.Minute || delay > 6*time.Minute+time.Second {
+		t.Errorf("next attempt in %v, want 4m to 6m", delay)
+	}
+}
@@ -0,0 +1,164 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/subliker/backendproj/config"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// NewSinks builds the sinks listed in cfg.Sinks. webhooks is the webhook sink,
+// stdout is where the stdout sink writes.
+func NewSinks(cfg config.Outbox, webhooks Sink, stdout io.Writer) ([]Sink, error) {
+	var sinks []Sink
+	for _, name := range strings.Split(cfg.Sinks, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "webhook":
+			sinks = append(sinks, webhooks)
+		case "stdout":
+			sinks = append(sinks, NewWriterSink(stdout))
+		case "nats":
+			sink, err := NewNATSSink(cfg.NATS)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "kafka":
+			sinks = append(sinks, NewKafkaSink(cfg.Kafka))
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+	return sinks, nil
+}
+
+// headers of the messages published to brokers
+const (
+	headerEventID   = "Event-Id"
+	headerEventType = "Event-Type"
+	headerAggregate = "Aggregate"
+)
+
+func aggregate(msg model.OutboxMessage) string {
+	return msg.Aggregate_type + ":" + msg.Aggregate_id
+}
+
+// WriterSink writes every message as a JSON line, it's meant for development and debugging.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Name() string {
+	return "stdout"
+}
+
+func (s *WriterSink) Publish(ctx context.Context, msg model.OutboxMessage) error {
+	line, err := json.Marshal(struct {
+		Aggregate string          `json:"aggregate"`
+		Event     json.RawMessage `json:"event"`
+	}{aggregate(msg), msg.Payload})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// NATSSink publishes every message to <subject>.<event type>.
+// The Nats-Msg-Id header is the event ID, so a JetStream stream on the subjects drops republished messages.
+type NATSSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATSSink connects to cfg.URL. When the server isn't available,
+// the connection is retried in the background and publishing fails until it's up.
+func NewNATSSink(cfg config.OutboxNATS) (*NATSSink, error) {
+	conn, err := nats.Connect(cfg.URL, nats.Name("backendproj-outbox"), nats.RetryOnFailedConnect(true), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	return &NATSSink{conn: conn, subject: cfg.Subject}, nil
+}
+
+func (s *NATSSink) Name() string {
+	return "nats"
+}
+
+// Publish waits until the server has the message. Plain NATS doesn't store it,
+// subscribers that are offline miss it unless a JetStream stream keeps the subjects.
+func (s *NATSSink) Publish(ctx context.Context, msg model.OutboxMessage) error {
+	m := nats.NewMsg(s.subject + "." + msg.Event_type)
+	m.Data = msg.Payload
+	m.Header.Set(nats.MsgIdHdr, msg.Event_id)
+	m.Header.Set(headerEventType, msg.Event_type)
+	m.Header.Set(headerAggregate, aggregate(msg))
+	if err := s.conn.PublishMsg(m); err != nil {
+		return err
+	}
+	return s.conn.FlushWithContext(ctx)
+}
+
+// Close closes the connection, nothing is buffered as Publish flushes every message.
+func (s *NATSSink) Close() error {
+	s.conn.Close()
+	return nil
+}
+
+// KafkaSink publishes every message to a topic keyed by aggregate,
+// so the events of one user or booking go to one partition and are consumed in order.
+type KafkaSink struct {
+	w *kafka.Writer
+}
+
+func NewKafkaSink(cfg config.OutboxKafka) *KafkaSink {
+	var brokers []string
+	for _, broker := range strings.Split(cfg.Brokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	return &KafkaSink{w: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+	}}
+}
+
+func (s *KafkaSink) Name() string {
+	return "kafka"
+}
+
+func (s *KafkaSink) Publish(ctx context.Context, msg model.OutboxMessage) error {
+	return s.w.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(aggregate(msg)),
+		Value: msg.Payload,
+		Headers: []kafka.Header{
+			{Key: headerEventID, Value: []byte(msg.Event_id)},
+			{Key: headerEventType, Value: []byte(msg.Event_type)},
+		},
+	})
+}
+
+func (s *KafkaSink) Close() error {
+	return s.w.Close()
+}
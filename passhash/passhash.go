@@ -0,0 +1,54 @@
+package passhash
+
+import "errors"
+
+var ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+
+// Hasher hashes passwords with one algorithm.
+// The algorithm and its parameters are encoded in the hash itself.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded and whether
+	// encoded was made with parameters other than the hasher's.
+	Verify(password, encoded string) (ok, outdated bool, err error)
+	// Owns reports whether encoded was produced by this algorithm.
+	Owns(encoded string) bool
+}
+
+// Manager hashes new passwords with the preferred hasher and verifies
+// hashes of every known algorithm.
+type Manager struct {
+	preferred Hasher
+	hashers   []Hasher
+}
+
+func NewManager(preferred Hasher, others ...Hasher) *Manager {
+	return &Manager{
+		preferred: preferred,
+		hashers:   append([]Hasher{preferred}, others...),
+	}
+}
+
+// Default is used by the service to hash and verify passwords.
+var Default = NewManager(Bcrypt{Cost: DefaultBcryptCost}, DefaultArgon2id())
+
+func (m *Manager) Hash(password string) (string, error) {
+	return m.preferred.Hash(password)
+}
+
+// Verify checks password against encoded. needsRehash is true when the password
+// matches but encoded uses another algorithm or outdated parameters,
+// then the caller should store a fresh Hash of the password.
+func (m *Manager) Verify(password, encoded string) (ok, needsRehash bool, err error) {
+	for _, h := range m.hashers {
+		if !h.Owns(encoded) {
+			continue
+		}
+		ok, outdated, err := h.Verify(password, encoded)
+		if err != nil || !ok {
+			return false, false, err
+		}
+		return true, outdated || h != m.preferred, nil
+	}
+	return false, false, ErrUnknownAlgorithm
+}
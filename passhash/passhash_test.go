@@ -0,0 +1,101 @@
+package passhash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters keep the tests fast, the defaults take a quarter second a hash
+var (
+	testBcrypt   = Bcrypt{Cost: bcrypt.MinCost + 1}
+	testArgon2id = Argon2id{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, h := range []Hasher{testBcrypt, testArgon2id} {
+		for _, password := range []string{"correct horse", "пароль с пробелами", "x"} {
+			encoded, err := h.Hash(password)
+			if err != nil {
+				t.Fatalf("%T.Hash(%q): %v", h, password, err)
+			}
+			if !h.Owns(encoded) {
+				t.Errorf("%T doesn't own its hash %q", h, encoded)
+			}
+			if ok, outdated, err := h.Verify(password, encoded); !ok || outdated || err != nil {
+				t.Errorf("%T.Verify(%q) = %v, %v, %v, want true, false, nil", h, password, ok, outdated, err)
+			}
+			if ok, _, err := h.Verify(password+"!", encoded); ok || err != nil {
+				t.Errorf("%T.Verify(wrong password) = %v, %v, want false, nil", h, ok, err)
+			}
+		}
+	}
+}
+
+func TestBcryptTooLong(t *testing.T) {
+	if _, err := testBcrypt.Hash(strings.Repeat("ж", BcryptMaxBytes/2)); err != nil {
+		t.Errorf("Hash(%d bytes): %v", BcryptMaxBytes, err)
+	}
+	if _, err := testBcrypt.Hash(strings.Repeat("ж", BcryptMaxBytes/2+1)); err == nil {
+		t.Errorf("Hash(%d bytes) succeeded, want an error", BcryptMaxBytes+2)
+	}
+}
+
+func TestManagerRehash(t *testing.T) {
+	const password = "correct horse battery"
+	oldBcrypt := Bcrypt{Cost: testBcrypt.Cost - 1}
+	oldArgon2id := testArgon2id
+	oldArgon2id.Time++
+
+	tests := []struct {
+		name    string
+		manager *Manager
+		// hashed makes the stored hash
+		hashed      Hasher
+		needsRehash bool
+		// prefix is what a fresh hash of the manager starts with
+		prefix string
+	}{
+		{"bcrypt with the current cost", NewManager(testBcrypt, testArgon2id), testBcrypt, false, "$2a$05$"},
+		{"bcrypt with an old cost", NewManager(testBcrypt, testArgon2id), oldBcrypt, true, "$2a$05$"},
+		{"bcrypt after switching to argon2id", NewManager(testArgon2id, testBcrypt), testBcrypt, true, "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{"argon2id with old parameters", NewManager(testArgon2id, testBcrypt), oldArgon2id, true, "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{"argon2id after switching to bcrypt", NewManager(testBcrypt, testArgon2id), testArgon2id, true, "$2a$05$"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.hashed.Hash(password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok, _, err := tt.manager.Verify("wrong", encoded); ok || err != nil {
+				t.Fatalf("Verify(wrong password) = %v, %v, want false, nil", ok, err)
+			}
+			ok, needsRehash, err := tt.manager.Verify(password, encoded)
+			if !ok || err != nil || needsRehash != tt.needsRehash {
+				t.Fatalf("Verify() = %v, %v, %v, want true, %v, nil", ok, needsRehash, err, tt.needsRehash)
+			}
+
+			// what db.VerifyUserPassword stores instead
+			fresh, err := tt.manager.Hash(password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(fresh, tt.prefix) {
+				t.Errorf("fresh hash %q, want it to start with %q", fresh, tt.prefix)
+			}
+			if ok, needsRehash, err := tt.manager.Verify(password, fresh); !ok || needsRehash || err != nil {
+				t.Errorf("Verify(fresh hash) = %v, %v, %v, want true, false, nil", ok, needsRehash, err)
+			}
+		})
+	}
+}
+
+func TestManagerUnknownAlgorithm(t *testing.T) {
+	_, _, err := NewManager(testBcrypt).Verify("password", "5f4dcc3b5aa765d61d8327deb882cf99")
+	if !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("Verify(md5) error %v, want ErrUnknownAlgorithm", err)
+	}
+}
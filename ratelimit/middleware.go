@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const clientKey = "ratelimit.client"
+
+// SetClient identifies the client of the request, e.g. "key:42" for an API key
+// or "user:7" for a user. Authentication middlewares call it before the limits,
+// policies that aren't KeyByIP then count requests per client instead of per IP.
+func SetClient(c *gin.Context, client string) {
+	c.Set(clientKey, client)
+}
+
+// Limiter applies policies to requests, counting them in Store.
+type Limiter struct {
+	Store Store
+	// Disabled lets every request through without headers
+	Disabled bool
+}
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{Store: store}
+}
+
+// Limit returns a middleware that limits requests by p.
+// Middlewares of different policies can be stacked, each one counts separately.
+//
+// Every response gets RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers,
+// a rejected request gets 429 with Retry-After. When Store fails, the request is let through.
+func (l *Limiter) Limit(p Policy) gin.HandlerFunc {
+	policyHeader := fmt.Sprintf("%d;w=%d", p.Requests, int(math.Ceil(p.Period.Seconds())))
+	if p.Burst > 0 {
+		policyHeader += ";burst=" + strconv.Itoa(p.Burst)
+	}
+	return func(c *gin.Context) {
+		if l.Disabled {
+			return
+		}
+		ctx := c.Request.Context()
+
+		key := p.Name + ":" + subject(c, p)
+		res, err := l.Store.Take(ctx, key, p, time.Now())
+		if err != nil {
+			logging.FromContext(ctx).Warn("rate limit store failed", "policy", p.Name, "err", err)
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(int(p.capacity())))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		c.Header("RateLimit-Policy", policyHeader)
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			dv.ResMessageCode(c, http.StatusTooManyRequests, "rate_limited")
+			c.Abort()
+		}
+	}
+}
+
+// LimitFailures returns a middleware that counts only the requests answered with status by p,
+// and rejects a client whose bucket is empty with 429 before the handlers after it run.
+// Put before authentication with 401, it stops a client trying invalid keys before they're looked up;
+// the client isn't known then, so it's counted by IP.
+func (l *Limiter) LimitFailures(p Policy, status int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l.Disabled {
+			return
+		}
+		ctx := c.Request.Context()
+
+		key := p.Name + ":ip:" + c.ClientIP()
+		res, err := l.Store.Peek(ctx, key, p, time.Now())
+		if err != nil {
+			logging.FromContext(ctx).Warn("rate limit store failed", "policy", p.Name, "err", err)
+			return
+		}
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			dv.ResMessageCode(c, http.StatusTooManyRequests, "rate_limited")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+		if c.Writer.Status() != status {
+			return
+		}
+		if _, err := l.Store.Take(ctx, key, p, time.Now()); err != nil {
+			logging.FromContext(ctx).Warn("rate limit store failed", "policy", p.Name, "err", err)
+		}
+	}
+}
+
+func subject(c *gin.Context, p Policy) string {
+	if client := c.GetString(clientKey); client != "" && !p.KeyByIP {
+		return client
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Policy is a token bucket: Burst requests at once, refilled at Requests per Period.
+type Policy struct {
+	Name     string
+	Requests int
+	Period   time.Duration
+	// 0 means Requests
+	Burst int
+	// KeyByIP limits every IP address separately even for authenticated clients
+	KeyByIP bool
+}
+
+func (p Policy) capacity() float64 {
+	if p.Burst > 0 {
+		return float64(p.Burst)
+	}
+	return float64(p.Requests)
+}
+
+// rate is tokens per second.
+func (p Policy) rate() float64 {
+	return float64(p.Requests) / p.Period.Seconds()
+}
+
+// Result is the state of a bucket after a request.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until the next request is allowed, 0 when it's allowed now
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps the buckets. MemoryStore keeps them in the process;
+// to share limits between instances implement Store over a shared database.
+type Store interface {
+	// Take takes a token from the bucket of key under policy p.
+	Take(ctx context.Context, key string, p Policy, now time.Time) (Result, error)
+	// Peek returns the bucket of key under policy p as Take would, without taking a token.
+	Peek(ctx context.Context, key string, p Policy, now time.Time) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket is full again, a full bucket is the same as no bucket
+	full time.Time
+}
+
+// take refills b up to now and takes n tokens from it if there is one, n is 0 to only look.
+func (b *bucket) take(p Policy, now time.Time, n float64) Result {
+	capacity, rate := p.capacity(), p.rate()
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	var res Result
+	if b.tokens >= 1 {
+		b.tokens -= n
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((capacity - b.tokens) / rate)
+	b.full = now.Add(res.Reset)
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// MemoryStore keeps buckets in memory, it's the default Store.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}}
+}
+
+// sweepInterval is how often buckets are checked for removal.
+const sweepInterval = time.Minute
+
+func (s *MemoryStore) Take(ctx context.Context, key string, p Policy, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.swept) > sweepInterval {
+		s.sweep(now)
+	}
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: p.capacity(), updated: now}
+		s.buckets[key] = b
+	}
+	return b.take(p, now, 1), nil
+}
+
+func (s *MemoryStore) Peek(ctx context.Context, key string, p Policy, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		// a missing bucket is full, it isn't stored until a token is taken
+		b = &bucket{tokens: p.capacity(), updated: now}
+	}
+	return b.take(p, now, 0), nil
+}
+
+// sweep removes buckets that are full again.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	s.swept = now
+}
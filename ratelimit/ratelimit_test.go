@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	// 2 at once, then one every 30 seconds
+	p := Policy{Name: "test", Requests: 2, Period: time.Minute}
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		after      time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}{
+		{0, true, 1, 0, 30 * time.Second},
+		{0, true, 0, 0, time.Minute},
+		{0, false, 0, 30 * time.Second, time.Minute},
+		{10 * time.Second, false, 0, 20 * time.Second, 50 * time.Second},
+		{30 * time.Second, true, 0, 0, time.Minute},
+		{2 * time.Minute, true, 1, 0, 30 * time.Second},
+	}
+	store := NewMemoryStore()
+	for i, tt := range tests {
+		res, err := store.Take(context.Background(), "ip:1", p, start.Add(tt.after))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != tt.allowed || res.Remaining != tt.remaining || !near(res.RetryAfter, tt.retryAfter) || !near(res.Reset, tt.reset) {
+			t.Errorf("request %d at +%v = %+v, want allowed %v, %d remaining, retry after %v, reset %v",
+				i, tt.after, res, tt.allowed, tt.remaining, tt.retryAfter, tt.reset)
+		}
+	}
+}
+
+// near reports whether d is want, give or take the rounding of float seconds.
+func near(d, want time.Duration) bool {
+	return d-want < time.Microsecond && want-d < time.Microsecond
+}
+
+func TestMemoryStoreBurst(t *testing.T) {
+	p := Policy{Name: "test", Requests: 1, Period: time.Second, Burst: 5}
+	now := time.Now()
+	store := NewMemoryStore()
+	for i := 0; i < 5; i++ {
+		if res, _ := store.Take(context.Background(), "k", p, now); !res.Allowed || res.Remaining != 4-i {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i, res, 4-i)
+		}
+	}
+	if res, _ := store.Take(context.Background(), "k", p, now); res.Allowed {
+		t.Fatal("request over the burst is allowed")
+	}
+	// other keys have their own buckets
+	if res, _ := store.Take(context.Background(), "other", p, now); !res.Allowed {
+		t.Fatal("another key is limited")
+	}
+}
+
+func TestMemoryStorePeekTakesNothing(t *testing.T) {
+	p := Policy{Name: "test", Requests: 1, Period: time.Minute}
+	now := time.Now()
+	store := NewMemoryStore()
+	for i := 0; i < 3; i++ {
+		if res, _ := store.Peek(context.Background(), "k", p, now); !res.Allowed {
+			t.Fatalf("peek %d = %+v, want allowed", i, res)
+		}
+	}
+	store.Take(context.Background(), "k", p, now)
+	if res, _ := store.Peek(context.Background(), "k", p, now); res.Allowed || !near(res.RetryAfter, time.Minute) {
+		t.Fatalf("peek at an empty bucket = %+v, want rejected for a minute", res)
+	}
+}
+
+func TestLimitFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p := Policy{Name: "auth_failures", Requests: 2, Period: time.Minute, KeyByIP: true}
+	limiter := NewLimiter(NewMemoryStore())
+	looked := 0
+	r := gin.New()
+	r.GET("/", limiter.LimitFailures(p, http.StatusUnauthorized), func(c *gin.Context) {
+		// stands for the authentication, it looks the key up
+		looked++
+		if c.GetHeader("X-API-Key") != "valid" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	request := func(ip, key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	tests := []struct {
+		ip, key string
+		status  int
+		looked  int
+	}{
+		// successes aren't counted
+		{"10.0.0.1", "valid", http.StatusOK, 1},
+		{"10.0.0.1", "valid", http.StatusOK, 2},
+		{"10.0.0.1", "valid", http.StatusOK, 3},
+		{"10.0.0.1", "bogus", http.StatusUnauthorized, 4},
+		{"10.0.0.1", "bogus", http.StatusUnauthorized, 5},
+		// the bucket is empty, the key isn't looked up anymore, not even a valid one
+		{"10.0.0.1", "bogus", http.StatusTooManyRequests, 5},
+		{"10.0.0.1", "valid", http.StatusTooManyRequests, 5},
+		// other IPs aren't affected
+		{"10.0.0.2", "bogus", http.StatusUnauthorized, 6},
+		{"10.0.0.2", "valid", http.StatusOK, 7},
+	}
+	for i, tt := range tests {
+		if status := request(tt.ip, tt.key); status != tt.status || looked != tt.looked {
+			t.Errorf("request %d from %s with %s = %d after %d lookups, want %d after %d", i, tt.ip, tt.key, status, looked, tt.status, tt.looked)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Package retry has what the background workers that retry failed attempts share.
+package retry
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// MaxErrorLength is how much of an error or a response body is kept in last_error.
+const MaxErrorLength = 512
+
+// Backoff returns the delay after the attempts-th failed attempt:
+// initial doubled every attempt up to max, with up to 50% jitter.
+func Backoff(initial, max time.Duration, attempts int) time.Duration {
+	delay := initial
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	delay = min(delay, max)
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// Truncate cuts s to MaxErrorLength bytes, dropping invalid UTF-8 that PostgreSQL doesn't store.
+func Truncate(s string) string {
+	if len(s) > MaxErrorLength {
+		s = s[:MaxErrorLength]
+	}
+	return strings.ToValidUTF8(s, "")
+}
@@ -0,0 +1,55 @@
+package retry
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestBackoff(t *testing.T) {
+	initial, max := 30*time.Second, 6*time.Hour
+	tests := []struct {
+		attempts int
+		// least is the delay without jitter, the jitter adds up to half of it
+		least time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, 64 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			got := Backoff(initial, max, tt.attempts)
+			if got < tt.least || got > tt.least*3/2 {
+				t.Fatalf("Backoff(%d) = %v, want %v to %v", tt.attempts, got, tt.least, tt.least*3/2)
+			}
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{"short", "connection refused", len("connection refused")},
+		{"long", strings.Repeat("a", 2000), MaxErrorLength},
+		// "ж" is 2 bytes, the cut lands in the middle of one
+		{"cut rune", "x" + strings.Repeat("ж", MaxErrorLength), MaxErrorLength - 1},
+		{"invalid UTF-8", "bad \xff body", len("bad  body")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Truncate(tt.s)
+			if len(got) != tt.want || !utf8.ValidString(got) {
+				t.Errorf("Truncate() is %d bytes, valid %v, want %d valid", len(got), utf8.ValidString(got), tt.want)
+			}
+		})
+	}
+}
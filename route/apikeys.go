@@ -0,0 +1,229 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/subliker/backendproj/auth"
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewAPIKey is an API key with the key itself, which is shown only once.
+type NewAPIKey struct {
+	model.APIKey
+	Key string `json:"key" example:"bk_Xq3k9FzB0f1v0rQ2o8mDkWl1nV7HcQeT3uYpJ6sAaLg"`
+}
+
+// CreateAPIKey godoc
+//
+//	@Summary		Create an API key
+//	@Description	The key is returned only in this response, store it. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Param   name   formData   string     true        "name (1 <= length <= 64)"
+//	@Param   scopes   formData   string     true        "comma separated scopes: users:read, users:write, bookings:read, bookings:write, admin"
+//	@Param   user_id   formData   int     false        "restrict the key to this user and their bookings"
+//	@Param   resource_id   formData   int     false        "restrict the key to this resource and its bookings, with user_id to the user's bookings of it"
+//	@Param   expires_at   formData   string     false        "RFC 3339 time the key stops working at"
+//	@Success		200				{object}	NewAPIKey
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/keys [post]
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var key model.APIKey
+
+	key.Name = dv.Normalize(c.PostForm("name"))
+	if length := utf8.RuneCountInString(key.Name); length < 1 || length > 64 {
+		dv.ResErr(c, http.StatusBadRequest, dv.NewError("api_key_name_length", i18n.Args{"min": 1, "max": 64}))
+		return
+	}
+
+	for _, scope := range strings.Split(c.PostForm("scopes"), ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if !slices.Contains(auth.Scopes, scope) {
+			dv.ResErr(c, http.StatusBadRequest, dv.NewError("scope_incorrect", i18n.Args{"scope": scope}))
+			return
+		}
+		key.Scopes = append(key.Scopes, scope)
+	}
+
+	if user_id := c.PostForm("user_id"); user_id != "" {
+		user_idI, err := strconv.Atoi(user_id)
+		if err != nil {
+			dv.ResMessageCode(c, http.StatusBadRequest, "user_id_incorrect")
+			return
+		}
+		_, httpCode, err := h.Store.GetUserDataByID(c.Request.Context(), user_idI)
+		if errors.Is(err, db.ErrNotFound) {
+			dv.ResMessageCode(c, http.StatusBadRequest, "user_not_exists")
+			return
+		}
+		if err != nil {
+			dv.ResErr(c, int(httpCode), err)
+			return
+		}
+		key.User_id = &user_idI
+	}
+
+	if resource_id := c.PostForm("resource_id"); resource_id != "" {
+		resource_idI, err := strconv.Atoi(resource_id)
+		if err != nil {
+			dv.ResMessageCode(c, http.StatusBadRequest, "resource_id_incorrect")
+			return
+		}
+		_, httpCode, err := h.Store.GetResourceByID(c.Request.Context(), resource_idI)
+		if errors.Is(err, db.ErrNotFound) {
+			dv.ResMessageCode(c, http.StatusBadRequest, "booking_resource_not_exists")
+			return
+		}
+		if err != nil {
+			dv.ResErr(c, int(httpCode), err)
+			return
+		}
+		key.Resource_id = &resource_idI
+	}
+
+	if expires_at := c.PostForm("expires_at"); expires_at != "" {
+		t, err := time.Parse(time.RFC3339, expires_at)
+		if err != nil || !t.After(time.Now()) {
+			dv.ResMessageCode(c, http.StatusBadRequest, "expires_at_incorrect")
+			return
+		}
+		key.Expires_at = &t
+	}
+
+	plain, prefix, hash, err := auth.GenerateKey()
+	if err != nil {
+		dv.ResErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	key.Prefix, key.Key_hash = prefix, hash
+
+	key, httpCode, err := h.Store.AddAPIKey(c.Request.Context(), key)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	keyData, e := json.Marshal(NewAPIKey{APIKey: key.In(time.UTC), Key: plain})
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", keyData)
+}
+
+// GetAPIKeys godoc
+//
+//	@Summary		List API keys
+//	@Description	Revoked and expired keys are listed too. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200				{array}		model.APIKey
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/keys [get]
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	keys, httpCode, err := h.Store.GetAPIKeys(c.Request.Context())
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	for i := range keys {
+		keys[i] = keys[i].In(time.UTC)
+	}
+	keysData, e := json.Marshal(keys)
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", keysData)
+}
+
+// RotateAPIKey godoc
+//
+//	@Summary		Rotate an API key
+//	@Description	Replaces the key keeping its name, scopes, user and expiry. The old key stops working at once. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Param id path int required "API key id"
+//	@Success		200				{object}	NewAPIKey
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/keys/{id}/rotate [post]
+func (h *Handler) RotateAPIKey(c *gin.Context) {
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+
+	plain, prefix, hash, err := auth.GenerateKey()
+	if err != nil {
+		dv.ResErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	key, httpCode, err := h.Store.RotateAPIKey(c.Request.Context(), idI, prefix, hash)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	keyData, e := json.Marshal(NewAPIKey{APIKey: key.In(time.UTC), Key: plain})
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", keyData)
+}
+
+// RevokeAPIKey godoc
+//
+//	@Summary		Revoke an API key
+//	@Description	The key stops working at once and can't be rotated anymore. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Param id path int required "API key id"
+//	@Success		200				{object}	dv.ResMesOK
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+
+	httpCode, err := h.Store.RevokeAPIKey(c.Request.Context(), idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	dv.ResMessageCode(c, http.StatusOK, "api_key_revoked")
+}
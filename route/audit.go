@@ -0,0 +1,203 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/subliker/backendproj/audit"
+	"github.com/subliker/backendproj/auth"
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	auditEntities   = []string{db.AuditUser, db.AuditBooking, db.AuditAPIKey, db.AuditWebhook, db.AuditWebhookDelivery, db.AuditCalendarToken, db.AuditResource}
+	auditOperations = []string{db.AuditCreate, db.AuditUpdate, db.AuditDelete, db.AuditRestore, db.AuditPurge, db.AuditRotate, db.AuditRevoke, db.AuditRedeliver}
+	auditActors     = []string{audit.ActorAdmin, audit.ActorAPIKey, audit.ActorAnonymous, audit.ActorSystem}
+)
+
+// GetAuditLog godoc
+//
+//	@Summary		List the audit log
+//	@Description	Every change of users, bookings, resources, API keys, webhooks and calendar tokens with who made it (actor, api_key_id, request_id, ip), when, and the changed fields before and after. Newest first, pass the id of the last entry as before_id for the next page. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Param        entity    query     string  false  "user, booking, resource, api_key, webhook, webhook_delivery or calendar_token"
+//	@Param        entity_id    query     int  false  "id of the entity, the user id for calendar_token"
+//	@Param        operation    query     string  false  "create, update, delete, restore, purge, rotate, revoke or redeliver"
+//	@Param        actor    query     string  false  "admin, api_key, anonymous or system"
+//	@Param        api_key_id    query     int  false  "changes made with this API key"
+//	@Param        request_id    query     string  false  "changes made by this request (X-Request-ID)"
+//	@Param        from    query     string  false  "entries at or after, RFC 3339 or YYYY-MM-DD"
+//	@Param        to    query     string  false  "entries before, RFC 3339 or YYYY-MM-DD"
+//	@Param        before_id    query     int  false  "entries older than this id"
+//	@Param        limit    query     int  false  "limit (default 50, at most 500)"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{array}		model.AuditEntry
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/audit [get]
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	filter, ok := auditFilter(c, loc)
+	if !ok {
+		return
+	}
+	entries, httpCode, err := h.Store.GetAuditLog(c.Request.Context(), filter)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	for i := range entries {
+		entries[i] = entries[i].In(loc)
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// auditFilter reads the filter of the audit log from the query, it answers 400 when it's incorrect.
+func auditFilter(c *gin.Context, loc *time.Location) (db.AuditFilter, bool) {
+	filter := db.AuditFilter{
+		Entity:    c.Query("entity"),
+		Operation: c.Query("operation"),
+		Actor:     c.Query("actor"),
+		RequestID: c.Query("request_id"),
+		Limit:     50,
+	}
+	incorrect := func(code string, args i18n.Args) (db.AuditFilter, bool) {
+		dv.ResErr(c, http.StatusBadRequest, dv.NewError(code, args))
+		return db.AuditFilter{}, false
+	}
+	if filter.Entity != "" && !slices.Contains(auditEntities, filter.Entity) {
+		return incorrect("audit_entity_incorrect", i18n.Args{"entity": filter.Entity})
+	}
+	if filter.Operation != "" && !slices.Contains(auditOperations, filter.Operation) {
+		return incorrect("audit_operation_incorrect", i18n.Args{"operation": filter.Operation})
+	}
+	if filter.Actor != "" && !slices.Contains(auditActors, filter.Actor) {
+		return incorrect("audit_actor_incorrect", nil)
+	}
+
+	for name, n := range map[string]*int{"entity_id": &filter.EntityID, "api_key_id": &filter.APIKeyID, "limit": &filter.Limit} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		var err error
+		if *n, err = strconv.Atoi(value); err != nil || *n < 1 || (name == "limit" && *n > 500) {
+			return incorrect("query_param_incorrect", i18n.Args{"name": name})
+		}
+	}
+	if value := c.Query("before_id"); value != "" {
+		var err error
+		if filter.BeforeID, err = strconv.ParseInt(value, 10, 64); err != nil || filter.BeforeID < 1 {
+			return incorrect("query_param_incorrect", i18n.Args{"name": "before_id"})
+		}
+	}
+	for name, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		var err error
+		if *t, err = time.ParseInLocation("2006-01-02", value, loc); err != nil {
+			if *t, err = dv.ParseTime(value, loc); err != nil {
+				return incorrect("time_param_incorrect", i18n.Args{"name": name})
+			}
+		}
+	}
+	return filter, true
+}
+
+// VerifyAuditLog godoc
+//
+//	@Summary		Verify the hash chain of the audit log
+//	@Description	Recomputes the hash of every entry in order. valid is false and broken_at is the first entry that doesn't match when an entry was changed, removed or inserted.
+//	@Description	Entries removed from the end can't be told from the chain alone: keep last_hash elsewhere and check that the chain still has it. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200				{object}	db.AuditVerification
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/audit/verify [get]
+func (h *Handler) VerifyAuditLog(c *gin.Context) {
+	result, httpCode, err := h.Store.VerifyAuditLog(c.Request.Context())
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetBookingHistory godoc
+//
+//	@Summary		Return the history of a booking
+//	@Description	The audit log entries of the booking, oldest first: who created, moved, deleted or restored it and what changed.
+//	@Description	The history of a deleted booking is kept, the history of a purged one only clients not restricted to a user can see.
+//	@Description	ip, request_id and api_key_id are only returned to clients with the admin scope.
+//	@Tags			booking
+//	@Produce		json
+//	@Param id path int required "booking id"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{array}		model.AuditEntry
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/booking/{id}/history [get]
+func (h *Handler) GetBookingHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	// the owner of a purged booking isn't known anymore
+	booking, httpCode, err := h.Store.GetBookingDataByIDWithDeleted(ctx, idI)
+	purged := errors.Is(err, db.ErrNotFound)
+	if err != nil && !(purged && auth.CanAccessAllUsers(ctx)) {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	if !purged && deniedBooking(c, booking) {
+		return
+	}
+
+	entries, httpCode, err := h.Store.GetEntityHistory(ctx, db.AuditBooking, idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	if purged && len(entries) == 0 {
+		dv.ResMessageCode(c, http.StatusNotFound, "booking_not_found")
+		return
+	}
+	// who else changed the booking from where is only for admins, like the audit log
+	admin := auth.IsAdmin(ctx)
+	for i := range entries {
+		entries[i] = entries[i].In(loc)
+		if !admin {
+			entries[i].Ip, entries[i].Request_id, entries[i].Api_key_id = "", "", nil
+		}
+	}
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,53 @@
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/subliker/backendproj/auth"
+	"github.com/subliker/backendproj/model"
+)
+
+func TestGetBookingHistoryRedactsWhoForNonAdmins(t *testing.T) {
+	keyID := 3
+	store := &fakeStore{
+		bookings: map[int]model.Booking{1: {Id: 1, User_id: 5}},
+		history: []model.AuditEntry{{
+			Id: 10, Actor: "api_key", Api_key_id: &keyID, Request_id: "req-1", Ip: "203.0.113.24",
+			Entity: "booking", Entity_id: 1, Operation: "create", Diff: json.RawMessage(`{}`),
+		}},
+	}
+	h := NewHandler(store)
+	tests := []struct {
+		name string
+		p    *auth.Principal
+		who  bool
+	}{
+		{"anonymous", nil, false},
+		{"bookings reader", &auth.Principal{KeyID: 4, Scopes: []string{auth.ScopeBookingsRead}}, false},
+		{"admin", &auth.Principal{Scopes: []string{auth.ScopeAdmin}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/booking/1/history", nil)
+			w := serve(t, "/booking/:id/history", h.GetBookingHistory, req, tt.p)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var entries []model.AuditEntry
+			if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil || len(entries) != 1 {
+				t.Fatalf("entries %s: %v", w.Body, err)
+			}
+			e := entries[0]
+			shown := e.Ip != "" || e.Request_id != "" || e.Api_key_id != nil
+			if shown != tt.who {
+				t.Errorf("ip %q, request_id %q, api_key_id %v shown = %v, want %v", e.Ip, e.Request_id, e.Api_key_id, shown, tt.who)
+			}
+			if e.Operation != "create" {
+				t.Errorf("operation %q, want create", e.Operation)
+			}
+		})
+	}
+}
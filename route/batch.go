@@ -0,0 +1,302 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/subliker/backendproj/auth"
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/metrics"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statuses of the operations of a batch
+const (
+	BatchOK     = "ok"
+	BatchFailed = "failed"
+	//the operation succeeded, but another one failed an atomic batch
+	BatchRolledBack = "rolled_back"
+)
+
+// BatchRequest is a list of booking operations run in order.
+type BatchRequest struct {
+	//all or nothing (default), or false for best effort
+	Atomic     *bool            `json:"atomic" example:"true"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation creates, updates or deletes a booking.
+type BatchOperation struct {
+	//create, update or delete
+	Op string `json:"op" example:"create"`
+	//booking to update or delete
+	Id int `json:"id,omitempty" example:"1021"`
+	//user of the booking to create
+	User_id int `json:"user_id,omitempty" example:"906"`
+	//resource of the booking to create, optional
+	Resource_id *int `json:"resource_id,omitempty" example:"4"`
+	//RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user; optional on update
+	Start_time string `json:"start_time,omitempty" example:"2023-10-01T15:00:00+03:00"`
+	//RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user; optional on update
+	End_time string `json:"end_time,omitempty" example:"2023-10-01T17:30:00+03:00"`
+	//optional, unchanged on update when it isn't set
+	Comment *string `json:"comment,omitempty" example:"I may be a little late"`
+}
+
+// BatchItem is the result of an operation of a batch.
+type BatchItem struct {
+	Index int    `json:"index" example:"0"`
+	Op    string `json:"op" example:"create"`
+	//ok, failed or rolled_back
+	Status string `json:"status" example:"ok"`
+	//the created, updated or deleted booking
+	Booking *model.Booking `json:"booking,omitempty"`
+	//HTTP status the operation would get on its own
+	Http_status int    `json:"http_status,omitempty" example:"200"`
+	Code        string `json:"code,omitempty" example:"booking_conflict"`
+	Message     string `json:"message,omitempty" example:"the booking overlaps booking 1021"`
+}
+
+// BatchResult reports every operation of a batch.
+type BatchResult struct {
+	Atomic bool `json:"atomic" example:"true"`
+	//whether the successful operations were saved
+	Committed bool        `json:"committed" example:"true"`
+	Succeeded int         `json:"succeeded" example:"2"`
+	Failed    int         `json:"failed" example:"0"`
+	Items     []BatchItem `json:"items"`
+}
+
+// BatchBookings godoc
+//
+//	@Summary		Create, update and delete bookings in one request
+//	@Description	Runs the operations in order in one transaction. Conflicts are checked against the bookings as the earlier operations leave them, so bookings of the batch can't overlap each other.
+//	@Description	An atomic batch (the default) saves every operation or none: when one fails, 422 reports every operation and nothing is saved. With atomic false the failed operations are skipped and the others are saved.
+//	@Description	Fields of update are optional, the booking keeps the ones that aren't set. A booking can be updated or deleted once per batch.
+//	@Tags			booking
+//	@Accept			json
+//	@Produce		json
+//	@Param   batch   body   BatchRequest     true        "operations"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{object}	BatchResult
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		422				{object}	BatchResult
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/booking/batch [post]
+func (h *Handler) BatchBookings(c *gin.Context) {
+	ctx := c.Request.Context()
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		dv.ResErr(c, http.StatusBadRequest, dv.WrapError(err, "batch_incorrect", i18n.Args{"reason": err.Error()}))
+		return
+	}
+	if len(req.Operations) == 0 {
+		dv.ResMessageCode(c, http.StatusBadRequest, "batch_empty")
+		return
+	}
+	if len(req.Operations) > h.BatchMaxOperations {
+		dv.ResErr(c, http.StatusBadRequest, dv.NewError("batch_too_large", i18n.Args{"max": h.BatchMaxOperations}))
+		return
+	}
+	atomic := req.Atomic == nil || *req.Atomic
+
+	// the bookings, users and resources of every operation are read at once
+	var bookingIDs, userIDs, resourceIDs []int
+	for _, op := range req.Operations {
+		switch op.Op {
+		case db.OpCreate:
+			userIDs = append(userIDs, op.User_id)
+			if op.Resource_id != nil {
+				resourceIDs = append(resourceIDs, *op.Resource_id)
+			}
+		case db.OpUpdate, db.OpDelete:
+			bookingIDs = append(bookingIDs, op.Id)
+		}
+	}
+	bookings, httpCode, err := h.Store.GetBookingsByIDs(ctx, bookingIDs)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	for _, booking := range bookings {
+		userIDs = append(userIDs, booking.User_id)
+		if booking.Resource_id != nil {
+			resourceIDs = append(resourceIDs, *booking.Resource_id)
+		}
+	}
+	users, httpCode, err := h.Store.GetUsersByIDs(ctx, userIDs)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	resources, httpCode, err := h.Store.GetResourcesByIDs(ctx, resourceIDs)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+
+	tag := i18n.Negotiate(c.GetHeader("Accept-Language"))
+	items := make([]BatchItem, len(req.Operations))
+	fail := func(item *BatchItem, httpStatus int, err *dv.Error) {
+		item.Status, item.Http_status = BatchFailed, httpStatus
+		item.Code, item.Message = err.Code, i18n.Translate(tag, err.Code, err.Args)
+	}
+	var ops []db.BookingOp
+	var opItems []int
+	touched := map[int]bool{}
+	for i, op := range req.Operations {
+		item := &items[i]
+		item.Index, item.Op = i, op.Op
+		booking, changes, httpStatus, err := batchBooking(c, op, bookings, users, resources, touched)
+		if err != nil {
+			fail(item, httpStatus, err)
+			continue
+		}
+		if op.Op != db.OpCreate {
+			// the database has the rest, the user and resource are needed to lock them
+			booking = model.Booking{Id: booking.Id, User_id: booking.User_id, Resource_id: booking.Resource_id}
+		}
+		ops = append(ops, db.BookingOp{Op: op.Op, Booking: booking, Changes: changes})
+		opItems = append(opItems, i)
+	}
+
+	result := BatchResult{Atomic: atomic, Items: items}
+	invalid := len(ops) < len(items)
+	if len(ops) > 0 && !(atomic && invalid) {
+		results, committed, httpCode, err := h.Store.BatchBookings(ctx, ops, atomic)
+		if err != nil {
+			dv.ResErr(c, int(httpCode), err)
+			return
+		}
+		result.Committed = committed
+		for j, r := range results {
+			item := &items[opItems[j]]
+			var coded *dv.Error
+			if errors.As(r.Err, &coded) {
+				fail(item, int(r.HttpCode), coded)
+				continue
+			}
+			booking := r.Booking.In(loc)
+			item.Status, item.Http_status, item.Booking = BatchOK, http.StatusOK, &booking
+		}
+	}
+	for i := range items {
+		switch {
+		case items[i].Status == BatchFailed:
+			result.Failed++
+			if items[i].Code == "booking_conflict" {
+				metrics.BookingConflicts.Inc()
+			}
+		case result.Committed:
+			result.Succeeded++
+		default:
+			// valid operations of an atomic batch that wasn't run or was rolled back
+			items[i].Status, items[i].Booking = BatchRolledBack, nil
+		}
+	}
+	if atomic && result.Failed > 0 {
+		c.JSON(http.StatusUnprocessableEntity, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// batchBooking returns the booking op makes with the fields an update changes, or the error of op
+// with its HTTP status. An update is checked against bookings, the database merges its changes
+// onto the booking as it is then. touched has the bookings updated or deleted by earlier operations,
+// op's booking is added to it.
+func batchBooking(c *gin.Context, op BatchOperation, bookings map[int]model.Booking, users map[int]model.User, resources map[int]model.Resource, touched map[int]bool) (model.Booking, db.BookingChanges, int, *dv.Error) {
+	var booking model.Booking
+	var changes db.BookingChanges
+	switch op.Op {
+	case db.OpCreate:
+		if op.User_id == 0 {
+			return booking, changes, http.StatusBadRequest, dv.NewError("user_id_not_set", nil)
+		}
+		if op.Start_time == "" || op.End_time == "" {
+			return booking, changes, http.StatusBadRequest, dv.NewError("batch_times_not_set", nil)
+		}
+		booking.User_id = op.User_id
+		booking.Resource_id = op.Resource_id
+	case db.OpUpdate, db.OpDelete:
+		if op.Id == 0 {
+			return booking, changes, http.StatusBadRequest, dv.NewError("id_not_set", nil)
+		}
+		existing, ok := bookings[op.Id]
+		if !ok {
+			return booking, changes, http.StatusNotFound, dv.NewError("booking_not_found", nil)
+		}
+		if touched[op.Id] {
+			return booking, changes, http.StatusBadRequest, dv.NewError("batch_booking_repeated", i18n.Args{"id": op.Id})
+		}
+		booking = existing
+	default:
+		return booking, changes, http.StatusBadRequest, dv.NewError("batch_op_incorrect", i18n.Args{"op": op.Op})
+	}
+	if !auth.CanAccessBooking(c.Request.Context(), booking.User_id, booking.Resource_id) {
+		return booking, changes, http.StatusForbidden, dv.NewError("access_denied", nil)
+	}
+	user, ok := users[booking.User_id]
+	if !ok {
+		return booking, changes, http.StatusBadRequest, dv.NewError("booking_user_not_exists", nil)
+	}
+	if op.Op == db.OpDelete {
+		touched[op.Id] = true
+		return booking, changes, http.StatusOK, nil
+	}
+	var resource *model.Resource
+	if booking.Resource_id != nil {
+		r, ok := resources[*booking.Resource_id]
+		if !ok {
+			return booking, changes, http.StatusBadRequest, dv.NewError("booking_resource_not_exists", nil)
+		}
+		resource = &r
+	}
+
+	userLoc, err := bookingLocation(user, resource)
+	if err != nil {
+		return booking, changes, http.StatusInternalServerError, dv.WrapError(err, "internal_error", nil)
+	}
+	for _, t := range []struct {
+		value, code string
+		time        *time.Time
+	}{{op.Start_time, "start_time_incorrect", &booking.Start_time}, {op.End_time, "end_time_incorrect", &booking.End_time}} {
+		if t.value == "" {
+			continue
+		}
+		if *t.time, err = dv.ParseTime(t.value, userLoc); err != nil {
+			return booking, changes, http.StatusBadRequest, dv.NewError(t.code, nil)
+		}
+	}
+	if op.Start_time != "" {
+		changes.Start_time = &booking.Start_time
+	}
+	if op.End_time != "" {
+		changes.End_time = &booking.End_time
+	}
+	var verr *dv.Error
+	if errors.As(dv.CheckCorrectTimeDuration(booking.Start_time, booking.End_time), &verr) {
+		return booking, changes, http.StatusBadRequest, verr
+	}
+	if op.Comment != nil {
+		booking.Comment = dv.Normalize(*op.Comment)
+		changes.Comment = &booking.Comment
+	}
+	if errors.As(dv.ValidateComment(booking.Comment), &verr) {
+		return booking, changes, http.StatusBadRequest, verr
+	}
+	if op.Op == db.OpUpdate {
+		touched[op.Id] = true
+	}
+	return booking, changes, http.StatusOK, nil
+}
@@ -0,0 +1,258 @@
+package route
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/subliker/backendproj/auth"
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/ical"
+	"github.com/subliker/backendproj/logging"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// calendarUIDDomain ends the UIDs of the events in calendar feeds, UIDs must be globally unique.
+const calendarUIDDomain = "backendproj"
+
+// NewCalendarToken is a calendar token with the token itself and the feed URL, which are shown only once.
+type NewCalendarToken struct {
+	model.CalendarToken
+	Token string `json:"token" example:"cal_Xq3k9FzB0f1v0rQ2o8mDkWl1nV7HcQeT3uYpJ6sAaLg"`
+	// URL to subscribe to in calendar apps
+	Url string `json:"url" example:"https://bookings.example.com/api/v1/user/906/calendar.ics?token=cal_Xq3k9FzB0f1v0rQ2o8mDkWl1nV7HcQeT3uYpJ6sAaLg"`
+}
+
+// GetUserCalendar godoc
+//
+//	@Summary		Bookings of a user as an iCalendar feed
+//	@Description	RFC 5545 calendar to subscribe to in Outlook, Google Calendar or Apple Calendar. Every booking is an event with a stable UID, its SEQUENCE grows on every update.
+//	@Description	Calendar apps can't send API keys, so the feed also accepts the calendar token of the user in the token parameter, see POST /user/{id}/calendar/token.
+//	@Tags			user
+//	@Produce		text/calendar
+//	@Param id path int required "user id"
+//	@Param   token   query   string     false        "calendar token of the user, instead of an API key"
+//	@Param   tz   query   string     false        "IANA time zone of the events (or Time-Zone header), default the user's time zone"
+//	@Success		200				{string}	string	"text/calendar"
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/user/{id}/calendar.ics [get]
+func (h *Handler) GetUserCalendar(c *gin.Context) {
+	ctx := c.Request.Context()
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if token := c.Query("token"); token != "" {
+		stored, httpCode, err := h.Store.GetCalendarToken(ctx, idI)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			dv.ResErr(c, int(httpCode), err)
+			return
+		}
+		if err != nil || subtle.ConstantTimeCompare([]byte(auth.HashKey(token)), []byte(stored.Token_hash)) != 1 {
+			dv.ResMessageCode(c, http.StatusUnauthorized, "calendar_token_invalid")
+			return
+		}
+	} else if denied(c, idI) {
+		return
+	}
+
+	user, httpCode, err := h.Store.GetUserDataByID(ctx, idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	loc, err := calendarLocation(c, user.Timezone)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	bookings, httpCode, err := h.Store.GetUserBookings(ctx, idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	writeCalendar(c, ical.Calendar{Name: "Bookings of " + user.Username, Location: loc}, bookings)
+}
+
+// GetResourceCalendar godoc
+//
+//	@Summary		Bookings of a resource as an iCalendar feed
+//	@Description	RFC 5545 calendar of the bookings taking the resource, whoever made them, like the feed of a user.
+//	@Description	Calendar tokens belong to a user, so this feed needs an API key that isn't restricted to a user.
+//	@Tags			resource
+//	@Produce		text/calendar
+//	@Param id path int required "resource id"
+//	@Param   tz   query   string     false        "IANA time zone of the events (or Time-Zone header), default the resource's time zone"
+//	@Success		200				{string}	string	"text/calendar"
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/resource/{id}/calendar.ics [get]
+func (h *Handler) GetResourceCalendar(c *gin.Context) {
+	ctx := c.Request.Context()
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if deniedResource(c, idI) {
+		return
+	}
+	// the feed has the bookings of every user
+	if p := auth.FromContext(ctx); p != nil && p.UserID != nil {
+		dv.ResMessageCode(c, http.StatusForbidden, "access_denied")
+		return
+	}
+
+	resource, httpCode, err := h.Store.GetResourceByID(ctx, idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	loc, err := calendarLocation(c, resource.Timezone)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	bookings, httpCode, err := h.Store.GetResourceBookings(ctx, idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	writeCalendar(c, ical.Calendar{Name: "Bookings of " + resource.Name, Location: loc}, bookings)
+}
+
+// calendarLocation returns the zone of a feed: tz, the Time-Zone header or else zone.
+func calendarLocation(c *gin.Context, zone string) (*time.Location, error) {
+	if tz := c.Query("tz"); tz != "" {
+		zone = tz
+	} else if tz := c.GetHeader("Time-Zone"); tz != "" {
+		zone = tz
+	}
+	return dv.LoadTimezone(zone)
+}
+
+// writeCalendar answers with cal, every booking is an event of it.
+func writeCalendar(c *gin.Context, cal ical.Calendar, bookings []model.Booking) {
+	now := time.Now()
+	for _, booking := range bookings {
+		cal.Events = append(cal.Events, ical.Event{
+			UID:         fmt.Sprintf("booking-%d@%s", booking.Id, calendarUIDDomain),
+			Sequence:    booking.Sequence,
+			Start:       booking.Start_time,
+			End:         booking.End_time,
+			Summary:     fmt.Sprintf("Booking #%d", booking.Id),
+			Description: booking.Comment,
+			Stamp:       now,
+		})
+	}
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", `inline; filename="calendar.ics"`)
+	c.Header("Cache-Control", "private, no-cache")
+	c.Status(http.StatusOK)
+	if err := cal.Write(c.Writer); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("write calendar", "err", err)
+	}
+}
+
+// CreateCalendarToken godoc
+//
+//	@Summary		Create the calendar token of a user
+//	@Description	Returns the token and the feed URL to subscribe to, they're shown only in this response. An existing token of the user stops working.
+//	@Tags			user
+//	@Produce		json
+//	@Param id path int required "user id"
+//	@Success		200				{object}	NewCalendarToken
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/user/{id}/calendar/token [post]
+func (h *Handler) CreateCalendarToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if denied(c, idI) {
+		return
+	}
+	if _, httpCode, err := h.Store.GetUserDataByID(ctx, idI); err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+
+	token, prefix, hash, err := auth.GenerateCalendarToken()
+	if err != nil {
+		dv.ResErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	stored, httpCode, err := h.Store.SetCalendarToken(ctx, idI, prefix, hash)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	c.JSON(http.StatusOK, NewCalendarToken{CalendarToken: stored, Token: token, Url: feedURL(c, token)})
+}
+
+// DeleteCalendarToken godoc
+//
+//	@Summary		Revoke the calendar token of a user
+//	@Description	Calendar apps subscribed with it can't fetch the feed anymore.
+//	@Tags			user
+//	@Produce		json
+//	@Param id path int required "user id"
+//	@Success		200				{object}	dv.ResMesOK
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/user/{id}/calendar/token [delete]
+func (h *Handler) DeleteCalendarToken(c *gin.Context) {
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if denied(c, idI) {
+		return
+	}
+	httpCode, err := h.Store.DeleteCalendarToken(c.Request.Context(), idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	dv.ResMessageCode(c, http.StatusOK, "calendar_token_revoked")
+}
+
+// feedURL returns the URL of the feed of the token request, under the same API version.
+func feedURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	u := url.URL{
+		Scheme:   scheme,
+		Host:     c.Request.Host,
+		Path:     strings.TrimSuffix(c.Request.URL.Path, "/token") + ".ics",
+		RawQuery: url.Values{"token": {token}}.Encode(),
+	}
+	return u.String()
+}
@@ -0,0 +1,349 @@
+package route
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/subliker/backendproj/auth"
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/logging"
+	"github.com/subliker/backendproj/model"
+	"github.com/subliker/backendproj/xlsx"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFormats are the content types of the export formats by their name in the format parameter.
+var exportFormats = map[string]string{
+	"csv":    "text/csv",
+	"ndjson": "application/x-ndjson",
+	"xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// acceptFormats are the export formats by the media types of Accept.
+var acceptFormats = map[string]string{
+	"text/csv":             "csv",
+	"application/x-ndjson": "ndjson",
+	"application/ndjson":   "ndjson",
+	exportFormats["xlsx"]:  "xlsx",
+	"text/*":               "csv",
+	"*/*":                  "csv",
+}
+
+// ExportBookings godoc
+//
+//	@Summary		Export bookings
+//	@Description	Streams the bookings by id as CSV, NDJSON or XLSX, chosen by format or else by Accept (CSV by default). Rows are sent as they're read, so exports of any size take little memory.
+//	@Description	CSV and XLSX start with a header row, NDJSON has a booking per line. Texts that spreadsheets would take for formulas are prefixed with ' in CSV.
+//	@Description	If the export fails midway the connection is closed without ending the response, so a cut off export can't be taken for a whole one.
+//	@Tags			booking
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param   format   query   string     false        "csv, ndjson or xlsx, instead of Accept"
+//	@Param   user_id   query   int     false        "only the bookings of this user, required for keys restricted to a user"
+//	@Param   from   query   string     false        "only bookings starting at or after (RFC 3339, YYYY-MM-DD HH:MM:SS or YYYY-MM-DD in tz)"
+//	@Param   to   query   string     false        "only bookings starting before (RFC 3339, YYYY-MM-DD HH:MM:SS or YYYY-MM-DD in tz)"
+//	@Param        limit    query     int  false  "limit"
+//	@Param        page    query     int  false  "page (required limit)"
+//	@Param        offset    query     int  false  "offset (required limit)"
+//	@Param   include_deleted   query   bool     false        "export deleted rows too (admin scope)"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{string}	string	"the bookings"
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		406				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/booking/export [get]
+func (h *Handler) ExportBookings(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	filter, ok := exportFilter(c, loc)
+	if !ok {
+		return
+	}
+	if filter.IncludeDeleted, ok = includeDeleted(c); !ok {
+		return
+	}
+	if user_id := c.Query("user_id"); user_id != "" {
+		if filter.UserID, err = strconv.Atoi(user_id); err != nil {
+			dv.ResMessageCode(c, http.StatusBadRequest, "user_id_incorrect")
+			return
+		}
+		if denied(c, filter.UserID) {
+			return
+		}
+	} else if !auth.CanAccessAllUsers(c.Request.Context()) {
+		dv.ResMessageCode(c, http.StatusForbidden, "access_denied")
+		return
+	}
+
+	columns := []string{"id", "user_id", "resource_id", "start_time", "end_time", "comment", "deleted_at"}
+	export(c, format, "bookings", columns, func(row func(v any, values []any) error) (db.HttpCode, error) {
+		return h.Store.ExportBookings(c.Request.Context(), filter, func(b model.Booking) error {
+			b = b.In(loc)
+			return row(b, []any{b.Id, b.User_id, exportID(b.Resource_id), b.Start_time.Format(time.RFC3339), b.End_time.Format(time.RFC3339), b.Comment, exportTime(b.Deleted_at)})
+		})
+	})
+}
+
+// ExportUsers godoc
+//
+//	@Summary		Export users
+//	@Description	Streams the users by id as CSV, NDJSON or XLSX like GET /booking/export, without password hashes.
+//	@Tags			user
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param   format   query   string     false        "csv, ndjson or xlsx, instead of Accept"
+//	@Param   from   query   string     false        "only users created at or after (RFC 3339, YYYY-MM-DD HH:MM:SS or YYYY-MM-DD in tz)"
+//	@Param   to   query   string     false        "only users created before (RFC 3339, YYYY-MM-DD HH:MM:SS or YYYY-MM-DD in tz)"
+//	@Param        limit    query     int  false  "limit"
+//	@Param        page    query     int  false  "page (required limit)"
+//	@Param        offset    query     int  false  "offset (required limit)"
+//	@Param   include_deleted   query   bool     false        "export deleted rows too (admin scope)"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{string}	string	"the users"
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		406				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/user/export [get]
+func (h *Handler) ExportUsers(c *gin.Context) {
+	if !auth.CanAccessAllUsers(c.Request.Context()) {
+		dv.ResMessageCode(c, http.StatusForbidden, "access_denied")
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	filter, ok := exportFilter(c, loc)
+	if !ok {
+		return
+	}
+	if filter.IncludeDeleted, ok = includeDeleted(c); !ok {
+		return
+	}
+
+	columns := []string{"id", "username", "timezone", "created_at", "updated_at", "deleted_at"}
+	export(c, format, "users", columns, func(row func(v any, values []any) error) (db.HttpCode, error) {
+		return h.Store.ExportUsers(c.Request.Context(), filter, func(u model.User) error {
+			u = u.In(loc)
+			return row(u, []any{u.Id, u.Username, u.Timezone, u.Created_at.Format(time.RFC3339), u.Updated_at.Format(time.RFC3339), exportTime(u.Deleted_at)})
+		})
+	})
+}
+
+// export streams the rows run reads in format. The response starts with the first row,
+// so an error before it is answered as usual.
+func export(c *gin.Context, format, name string, columns []string, run func(row func(v any, values []any) error) (db.HttpCode, error)) {
+	var w exportWriter
+	begin := func() {
+		// exports outlive http.write_timeout
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+		c.Header("Content-Type", exportFormats[format])
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Status(http.StatusOK)
+		w = newExportWriter(format, c.Writer, name, columns)
+	}
+	httpCode, err := run(func(v any, values []any) error {
+		if w == nil {
+			begin()
+		}
+		return w.write(v, values)
+	})
+	if err != nil && w == nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	if w == nil {
+		begin()
+	}
+	if err == nil {
+		err = w.close()
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("export", "format", format, "err", err)
+		// the client must not take the cut off export for a whole one
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// exportTime returns t in RFC 3339, or "" when it isn't set.
+func exportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// exportID returns id as a number, an empty cell when it's nil.
+func exportID(id *int) any {
+	if id == nil {
+		return ""
+	}
+	return *id
+}
+
+// exportFormat returns the format of the request, it answers 400 or 406 and reports false when there's none.
+func exportFormat(c *gin.Context) (string, bool) {
+	if format := c.Query("format"); format != "" {
+		if _, ok := exportFormats[format]; !ok {
+			dv.ResMessageCode(c, http.StatusBadRequest, "format_incorrect")
+			return "", false
+		}
+		return format, true
+	}
+	accept := c.GetHeader("Accept")
+	if accept == "" {
+		return "csv", true
+	}
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+		// a type is preferred to a wildcard of the same quality
+		if strings.HasSuffix(mediaType, "/*") {
+			q -= 0.0001
+		}
+		if format, ok := acceptFormats[mediaType]; ok && q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	if best == "" {
+		dv.ResMessageCode(c, http.StatusNotAcceptable, "export_format_unsupported")
+		return "", false
+	}
+	return best, true
+}
+
+// exportFilter reads the filter parameters of an export, it answers 400 and reports false when one is incorrect.
+// from and to without an offset are taken in loc.
+func exportFilter(c *gin.Context, loc *time.Location) (db.ExportFilter, bool) {
+	var filter db.ExportFilter
+	incorrect := func(code, name string) (db.ExportFilter, bool) {
+		dv.ResErr(c, http.StatusBadRequest, dv.NewError(code, i18n.Args{"name": name}))
+		return db.ExportFilter{}, false
+	}
+	number := func(name string, min int) (int, bool) {
+		n, err := strconv.Atoi(c.Query(name))
+		return n, err == nil && n >= min
+	}
+
+	if c.Query("limit") != "" {
+		var ok bool
+		if filter.Limit, ok = number("limit", 1); !ok {
+			return incorrect("query_param_incorrect", "limit")
+		}
+		if c.Query("offset") != "" {
+			if filter.Offset, ok = number("offset", 0); !ok {
+				return incorrect("query_param_incorrect", "offset")
+			}
+		} else if c.Query("page") != "" {
+			page, ok := number("page", 1)
+			if !ok {
+				return incorrect("query_param_incorrect", "page")
+			}
+			filter.Offset = filter.Limit * (page - 1)
+		}
+	}
+	for name, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		var err error
+		if *t, err = time.ParseInLocation("2006-01-02", value, loc); err != nil {
+			if *t, err = dv.ParseTime(value, loc); err != nil {
+				return incorrect("time_param_incorrect", name)
+			}
+		}
+	}
+	return filter, true
+}
+
+// exportWriter writes the rows of an export in a format.
+type exportWriter interface {
+	// write writes a row, v is the row as JSON and values are its columns
+	write(v any, values []any) error
+	close() error
+}
+
+func newExportWriter(format string, w io.Writer, name string, columns []string) exportWriter {
+	header := make([]any, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	switch format {
+	case "ndjson":
+		return ndjsonExport{json.NewEncoder(w)}
+	case "xlsx":
+		x := xlsxExport{xlsx.NewWriter(w, name)}
+		x.WriteRow(header)
+		return x
+	default:
+		e := csvExport{csv.NewWriter(w)}
+		e.write(nil, header)
+		return e
+	}
+}
+
+type csvExport struct{ w *csv.Writer }
+
+func (e csvExport) write(_ any, values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		s := fmt.Sprint(v)
+		// spreadsheets run texts starting with these as formulas
+		if _, ok := v.(string); ok && s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+			s = "'" + s
+		}
+		record[i] = s
+	}
+	return e.w.Write(record)
+}
+
+func (e csvExport) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExport struct{ enc *json.Encoder }
+
+func (e ndjsonExport) write(v any, _ []any) error { return e.enc.Encode(v) }
+
+func (e ndjsonExport) close() error { return nil }
+
+type xlsxExport struct{ *xlsx.Writer }
+
+func (e xlsxExport) write(_ any, values []any) error { return e.WriteRow(values) }
+
+func (e xlsxExport) close() error { return e.Close() }
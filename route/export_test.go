@@ -0,0 +1,118 @@
+package route
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subliker/backendproj/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestExportFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name, query, accept string
+		// format is "" when the request is rejected with status
+		format string
+		status int
+	}{
+		{"default", "", "", "csv", 0},
+		{"parameter wins over Accept", "format=xlsx", "text/csv", "xlsx", 0},
+		{"unknown parameter", "format=pdf", "", "", http.StatusBadRequest},
+		{"exact type", "", "application/x-ndjson", "ndjson", 0},
+		{"highest q", "", "text/csv;q=0.5, application/x-ndjson;q=0.9", "ndjson", 0},
+		{"q with spaces and parameters", "", "text/csv; charset=utf-8; q=0.8, application/ndjson; q=0.7", "csv", 0},
+		{"type beats wildcard of the same q", "", "*/*, application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx", 0},
+		{"wildcard of higher q", "", "application/x-ndjson;q=0.5, */*", "csv", 0},
+		{"q=0 refuses", "", "text/csv;q=0, application/x-ndjson;q=0.1", "ndjson", 0},
+		{"unsupported types are skipped", "", "application/pdf, text/*;q=0.2", "csv", 0},
+		{"nothing acceptable", "", "application/pdf, image/png", "", http.StatusNotAcceptable},
+		{"only refused", "", "text/csv;q=0", "", http.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/booking/export?"+tt.query, nil)
+			if tt.accept != "" {
+				c.Request.Header.Set("Accept", tt.accept)
+			}
+			format, ok := exportFormat(c)
+			if ok != (tt.format != "") || format != tt.format {
+				t.Fatalf("exportFormat() = %q, %v, want %q", format, ok, tt.format)
+			}
+			if !ok && w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestExportBookings(t *testing.T) {
+	start := time.Date(2023, 10, 1, 9, 0, 0, 0, time.UTC)
+	room := 3
+	bookings := map[int]model.Booking{
+		1: {Id: 1, User_id: 5, Resource_id: &room, Start_time: start, End_time: start.Add(time.Hour), Comment: "=HYPERLINK(\"http://evil\")"},
+		2: {Id: 2, User_id: 5, Start_time: start, End_time: start.Add(time.Hour), Comment: "-1+2"},
+		3: {Id: 3, User_id: 6, Start_time: start, End_time: start.Add(time.Hour), Comment: "planning, @team"},
+	}
+	get := func(store *fakeStore, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/booking/export", nil)
+		req.Header.Set("Accept", accept)
+		return serve(t, "/booking/export", NewHandler(store).ExportBookings, req, nil)
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		w := get(&fakeStore{bookings: bookings}, "text/csv")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" {
+			t.Fatalf("status %d, %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := [][]string{
+			{"id", "user_id", "resource_id", "start_time", "end_time", "comment", "deleted_at"},
+			{"1", "5", "3", "2023-10-01T09:00:00Z", "2023-10-01T10:00:00Z", `'=HYPERLINK("http://evil")`, ""},
+			{"2", "5", "", "2023-10-01T09:00:00Z", "2023-10-01T10:00:00Z", "'-1+2", ""},
+			{"3", "6", "", "2023-10-01T09:00:00Z", "2023-10-01T10:00:00Z", "planning, @team", ""},
+		}
+		if len(records) != len(want) {
+			t.Fatalf("%d records, want %d: %q", len(records), len(want), records)
+		}
+		for i := range want {
+			if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+				t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+			}
+		}
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		if w := get(&fakeStore{bookings: bookings}, "application/pdf"); w.Code != http.StatusNotAcceptable {
+			t.Errorf("status %d, want 406", w.Code)
+		}
+	})
+
+	t.Run("error before the first row", func(t *testing.T) {
+		w := get(&fakeStore{exportErr: errors.New("database is down")}, "text/csv")
+		if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Disposition") != "" {
+			t.Errorf("status %d with Content-Disposition %q, want a plain 500", w.Code, w.Header().Get("Content-Disposition"))
+		}
+	})
+
+	t.Run("error midstream", func(t *testing.T) {
+		defer func() {
+			if err := recover(); err != http.ErrAbortHandler {
+				t.Errorf("recovered %v, want the export aborted with http.ErrAbortHandler", err)
+			}
+		}()
+		get(&fakeStore{bookings: bookings, exportErr: errors.New("connection reset")}, "application/x-ndjson")
+		t.Error("the cut off export ended normally")
+	})
+}
@@ -0,0 +1,327 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/ical"
+	"github.com/subliker/backendproj/metrics"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importMaxSize limits the uploaded .ics file.
+const importMaxSize = 10 << 20
+
+// statuses of the bookings of an import
+const (
+	ImportOK       = "ok"
+	ImportCreated  = "created"
+	ImportInvalid  = "invalid"
+	ImportConflict = "conflict"
+	ImportSkipped  = "skipped"
+)
+
+// ImportItem is a booking an event of the imported calendar maps to, or the event when it can't be read.
+type ImportItem struct {
+	// Uid is the UID of the event, a repeating event has an item per occurrence
+	Uid        string     `json:"uid" example:"040000008200E00074C5B7101A82E008"`
+	Start_time *time.Time `json:"start_time,omitempty" example:"2023-10-01T15:00:00+03:00"`
+	End_time   *time.Time `json:"end_time,omitempty" example:"2023-10-01T17:30:00+03:00"`
+	Comment    string     `json:"comment,omitempty" example:"Weekly sync"`
+	//ok (dry run), created, invalid, conflict or skipped (cancelled events)
+	Status  string `json:"status" example:"conflict"`
+	Code    string `json:"code,omitempty" example:"booking_conflict"`
+	Message string `json:"message,omitempty" example:"the booking overlaps booking 1021"`
+	//ids of the existing bookings it overlaps
+	Conflicts_with []int `json:"conflicts_with,omitempty" example:"1021"`
+	Booking_id     int   `json:"booking_id,omitempty" example:"1022"`
+}
+
+// ImportResult reports what an import did, or would do in a dry run, with every booking.
+type ImportResult struct {
+	Dry_run   bool         `json:"dry_run" example:"true"`
+	Total     int          `json:"total" example:"12"`
+	Created   int          `json:"created" example:"0"`
+	Invalid   int          `json:"invalid" example:"1"`
+	Conflicts int          `json:"conflicts" example:"1"`
+	Items     []ImportItem `json:"items"`
+}
+
+// ImportBookings godoc
+//
+//	@Summary		Import bookings from an iCalendar file
+//	@Description	Every VEVENT of the .ics file becomes a booking of the user (taking the resource with resource_id), repeating events (RRULE, RDATE, EXDATE and changed occurrences) a booking per occurrence. Endless ones are imported up to booking.import_horizon (a year) from now.
+//	@Description	Times without a zone are taken in the resource's time zone, or else the user's. SUMMARY becomes the comment, cancelled events are skipped.
+//	@Description	A booking conflicts when it overlaps an existing booking of the resource (or of the user without a resource when resource_id isn't set), or an earlier booking of the file.
+//	@Description	dry_run reports every booking without creating any. Otherwise the import is all or nothing: with an invalid or conflicting booking nothing is created and 422 reports them, unless skip_invalid creates the others.
+//	@Tags			booking
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param   file   formData   file     true        ".ics file"
+//	@Param   user_id   formData   int     true        "user the bookings are for"
+//	@Param   resource_id   formData   int     false        "resource the bookings take"
+//	@Param   dry_run   formData   bool     false        "only report what would be imported"
+//	@Param   skip_invalid   formData   bool     false        "import the valid bookings when some are invalid or conflict"
+//	@Success		200				{object}	ImportResult
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		409				{object}	dv.ResError
+//	@Failure		413				{object}	dv.ResError
+//	@Failure		422				{object}	ImportResult
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/booking/import [post]
+func (h *Handler) ImportBookings(c *gin.Context) {
+	ctx := c.Request.Context()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, importMaxSize)
+	fileHeader, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		dv.ResErr(c, http.StatusRequestEntityTooLarge, dv.NewError("import_file_too_large", i18n.Args{"max": "10 MB"}))
+		return
+	}
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "import_file_not_set")
+		return
+	}
+
+	user_id := c.PostForm("user_id")
+	if user_id == "" {
+		dv.ResMessageCode(c, http.StatusBadRequest, "user_id_not_set")
+		return
+	}
+	user_idI, err := strconv.Atoi(user_id)
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "user_id_incorrect")
+		return
+	}
+	dryRun, skipInvalid := false, false
+	for name, v := range map[string]*bool{"dry_run": &dryRun, "skip_invalid": &skipInvalid} {
+		if value := c.PostForm(name); value != "" {
+			if *v, err = strconv.ParseBool(value); err != nil {
+				dv.ResErr(c, http.StatusBadRequest, dv.NewError("flag_incorrect", i18n.Args{"name": name}))
+				return
+			}
+		}
+	}
+
+	resource, ok := h.bookingResource(c, c.PostForm("resource_id"))
+	if !ok {
+		return
+	}
+	var resource_id *int
+	if resource != nil {
+		resource_id = &resource.Id
+	}
+	if deniedBooking(c, model.Booking{User_id: user_idI, Resource_id: resource_id}) {
+		return
+	}
+	user, httpCode, err := h.Store.GetUserDataByID(ctx, user_idI)
+	if errors.Is(err, db.ErrNotFound) {
+		dv.ResMessageCode(c, http.StatusBadRequest, "booking_user_not_exists")
+		return
+	}
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	userLoc, err := bookingLocation(user, resource)
+	if err != nil {
+		dv.ResErr(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		dv.ResErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+	cal, err := ical.Parse(file)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, dv.WrapError(err, "ical_incorrect", i18n.Args{"reason": err.Error()}))
+		return
+	}
+
+	tag := i18n.Negotiate(c.GetHeader("Accept-Language"))
+	fail := func(item *ImportItem, status string, err *dv.Error) {
+		item.Status, item.Code, item.Message = status, err.Code, i18n.Translate(tag, err.Code, err.Args)
+	}
+
+	items, ok := h.importItems(ical.Events(cal, userLoc), fail)
+	if !ok {
+		dv.ResErr(c, http.StatusBadRequest, dv.NewError("import_too_many_bookings", i18n.Args{"max": h.ImportMaxBookings}))
+		return
+	}
+
+	// conflicts with the bookings of the resource, or of the user without one, and with earlier bookings of the file
+	var bookings []model.Booking
+	var from, to time.Time
+	for _, item := range items {
+		if item.Status == ImportOK {
+			if from.IsZero() || item.Start_time.Before(from) {
+				from = *item.Start_time
+			}
+			if to.IsZero() || item.End_time.After(to) {
+				to = *item.End_time
+			}
+		}
+	}
+	var existing []model.Booking
+	if !from.IsZero() {
+		if resource_id != nil {
+			existing, httpCode, err = h.Store.GetResourceBookingsBetween(ctx, *resource_id, from, to)
+		} else {
+			existing, httpCode, err = h.Store.GetUserBookingsBetween(ctx, user_idI, from, to)
+		}
+		if err != nil {
+			dv.ResErr(c, int(httpCode), err)
+			return
+		}
+	}
+	for i := range items {
+		item := &items[i]
+		if item.Status != ImportOK {
+			continue
+		}
+		booking := model.Booking{User_id: user_idI, Resource_id: resource_id, Start_time: *item.Start_time, End_time: *item.End_time, Comment: item.Comment}
+		for _, b := range existing {
+			if db.Conflicts(booking, b) {
+				item.Conflicts_with = append(item.Conflicts_with, b.Id)
+			}
+		}
+		if len(item.Conflicts_with) > 0 {
+			fail(item, ImportConflict, dv.NewError("booking_conflict", i18n.Args{"id": item.Conflicts_with[0]}))
+			continue
+		}
+		for _, earlier := range items[:i] {
+			if earlier.Status == ImportOK && earlier.Start_time.Before(*item.End_time) && earlier.End_time.After(*item.Start_time) {
+				fail(item, ImportConflict, dv.NewError("import_overlap", i18n.Args{"uid": earlier.Uid}))
+				break
+			}
+		}
+		if item.Status == ImportOK {
+			bookings = append(bookings, booking)
+		}
+	}
+
+	result := ImportResult{Dry_run: dryRun, Total: len(items), Items: items}
+	for _, item := range items {
+		switch item.Status {
+		case ImportInvalid:
+			result.Invalid++
+		case ImportConflict:
+			result.Conflicts++
+		}
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	metrics.BookingConflicts.Add(float64(result.Conflicts))
+	if (result.Invalid > 0 || result.Conflicts > 0) && !skipInvalid {
+		c.JSON(http.StatusUnprocessableEntity, result)
+		return
+	}
+
+	added := make([]model.Booking, 0)
+	if len(bookings) > 0 {
+		added, httpCode, err = h.Store.AddNewBookings(ctx, bookings)
+		if err != nil {
+			dv.ResErr(c, int(httpCode), err)
+			return
+		}
+	}
+	for i := range items {
+		if items[i].Status == ImportOK {
+			items[i].Status, items[i].Booking_id = ImportCreated, added[0].Id
+			added = added[1:]
+			result.Created++
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// importItems maps events to bookings, it reports false when there are more than h.ImportMaxBookings.
+// Bookings that can be imported have the ok status.
+func (h *Handler) importItems(events []ical.VEvent, fail func(*ImportItem, string, *dv.Error)) ([]ImportItem, bool) {
+	// changed occurrences of a repeating event replace the ones the rule makes
+	changed := map[string][]time.Time{}
+	for _, e := range events {
+		if e.Err == nil && !e.RecurrenceID.IsZero() {
+			changed[e.UID] = append(changed[e.UID], e.RecurrenceID)
+		}
+	}
+
+	until := time.Now().Add(h.ImportHorizon)
+	items := make([]ImportItem, 0)
+	for _, e := range events {
+		if e.Err != nil {
+			item := ImportItem{Uid: e.UID}
+			fail(&item, ImportInvalid, icalError(e.Err))
+			items = append(items, item)
+			continue
+		}
+		comment := dv.Normalize(e.Summary)
+		end := func(start time.Time) time.Time { return start.Add(e.End.Sub(e.Start)) }
+		if e.AllDay {
+			// a day is 23 or 25 hours long on DST changes, all-day events last whole days
+			days := int(date(e.End).Sub(date(e.Start)).Hours() / 24)
+			end = func(start time.Time) time.Time { return start.AddDate(0, 0, days) }
+		}
+		for _, start := range e.Starts(until, h.ImportMaxBookings+1-len(items)) {
+			if e.RecurrenceID.IsZero() && containsTime(changed[e.UID], start) {
+				continue
+			}
+			start, end := start, end(start)
+			item := ImportItem{Uid: e.UID, Start_time: &start, End_time: &end, Comment: comment, Status: ImportOK}
+			var verr *dv.Error
+			switch {
+			case e.Status == "CANCELLED":
+				item.Status = ImportSkipped
+			case errors.As(dv.CheckCorrectTimeDuration(start, end), &verr), errors.As(dv.ValidateComment(comment), &verr):
+				fail(&item, ImportInvalid, verr)
+			}
+			items = append(items, item)
+		}
+		if len(items) > h.ImportMaxBookings {
+			return nil, false
+		}
+	}
+	return items, true
+}
+
+// date returns the date of t as midnight UTC.
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func containsTime(times []time.Time, t time.Time) bool {
+	for _, u := range times {
+		if u.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// icalError returns the coded error of an event that can't be read.
+func icalError(err error) *dv.Error {
+	code, cause := "ical_event_incorrect", ical.ErrInvalidEvent
+	switch {
+	case errors.Is(err, ical.ErrUnknownTimezone):
+		code, cause = "ical_timezone_unknown", ical.ErrUnknownTimezone
+	case errors.Is(err, ical.ErrUnsupportedRule):
+		code, cause = "ical_rule_unsupported", ical.ErrUnsupportedRule
+	}
+	// the message of the code says what cause says
+	reason := strings.TrimPrefix(err.Error(), cause.Error()+": ")
+	return dv.WrapError(err, code, i18n.Args{"reason": reason})
+}
@@ -0,0 +1,86 @@
+package route
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/subliker/backendproj/model"
+)
+
+const importCalendar = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:sync\r\nDTSTART:20231001T120000\r\nDTEND:20231001T130000\r\nSUMMARY:Weekly sync\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func TestImportBookingsIntoResource(t *testing.T) {
+	room, desk, andrew, maria := 3, 4, 5, 6
+	tokyoNoon, moscowNoon := time.Date(2023, 10, 1, 3, 0, 0, 0, time.UTC), time.Date(2023, 10, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		resource_id string
+		existing    []model.Booking
+		status      int
+		// want is the start of the imported booking in UTC
+		want time.Time
+	}{
+		{"without a resource in the user's zone", "", nil, http.StatusOK, moscowNoon},
+		{"in the resource's zone", "3", nil, http.StatusOK, tokyoNoon},
+		{"overlapping a booking of the resource by another user", "3",
+			[]model.Booking{{Id: 1, User_id: maria, Resource_id: &room, Start_time: tokyoNoon, End_time: tokyoNoon.Add(time.Hour)}},
+			http.StatusUnprocessableEntity, time.Time{}},
+		{"a booking of another user without the resource", "3",
+			[]model.Booking{{Id: 1, User_id: maria, Start_time: tokyoNoon, End_time: tokyoNoon.Add(time.Hour)}},
+			http.StatusOK, tokyoNoon},
+		{"a booking of the user on another resource", "3",
+			[]model.Booking{{Id: 1, User_id: andrew, Resource_id: &desk, Start_time: tokyoNoon, End_time: tokyoNoon.Add(time.Hour)}},
+			http.StatusOK, tokyoNoon},
+		{"a booking of the user without a resource", "",
+			[]model.Booking{{Id: 1, User_id: andrew, Start_time: moscowNoon, End_time: moscowNoon.Add(time.Hour)}},
+			http.StatusUnprocessableEntity, time.Time{}},
+		{"unknown resource", "4", nil, http.StatusBadRequest, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{
+				users:     map[string]model.User{"andrew": {Id: andrew, Username: "andrew", Timezone: "Europe/Moscow"}},
+				resources: map[int]model.Resource{room: {Id: room, Name: "Room 2", Timezone: "Asia/Tokyo"}},
+				bookings:  map[int]model.Booking{},
+			}
+			for _, b := range tt.existing {
+				store.bookings[b.Id] = b
+			}
+			var body bytes.Buffer
+			form := multipart.NewWriter(&body)
+			form.WriteField("user_id", "5")
+			form.WriteField("resource_id", tt.resource_id)
+			file, _ := form.CreateFormFile("file", "calendar.ics")
+			file.Write([]byte(importCalendar))
+			form.Close()
+			req := httptest.NewRequest(http.MethodPost, "/booking/import", &body)
+			req.Header.Set("Content-Type", form.FormDataContentType())
+			w := serve(t, "/booking/import", NewHandler(store).ImportBookings, req, nil)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				if len(store.bookings) != len(tt.existing) {
+					t.Errorf("imported %v", store.bookings)
+				}
+				return
+			}
+			var result ImportResult
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			booking := store.bookings[result.Items[0].Booking_id]
+			if !booking.Start_time.Equal(tt.want) {
+				t.Errorf("start %v, want %v", booking.Start_time.UTC(), tt.want)
+			}
+			if (booking.Resource_id != nil) != (tt.resource_id != "") {
+				t.Errorf("resource_id %v, want %q", booking.Resource_id, tt.resource_id)
+			}
+		})
+	}
+}
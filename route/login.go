@@ -0,0 +1,73 @@
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/subliker/backendproj/auth"
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginUser godoc
+//
+//	@Summary		Check a user's password
+//	@Description	Returns the user when the username and password match, 401 otherwise, like for an unknown username.
+//	@Description	A key restricted to a user can only check the password of that user, for others it gets 401 too. A key restricted to a resource gets 403.
+//	@Description	A password hash made with an outdated algorithm or parameters is replaced with one made with the current settings.
+//	@Tags			user
+//	@Produce		json
+//	@Param   username   formData   string     true        "username, case and look-alike characters are ignored"
+//	@Param   password   formData   string     true        "password"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{object}	model.User
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/user/login [post]
+func (h *Handler) LoginUser(c *gin.Context) {
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	username, password := dv.Normalize(c.PostForm("username")), c.PostForm("password")
+	if username == "" || password == "" {
+		dv.ResMessageCode(c, http.StatusBadRequest, "credentials_not_set")
+		return
+	}
+
+	// a key restricted to a user can't tell the passwords of others by the status,
+	// one restricted to a resource has no access to users at all
+	var only *int
+	if p := auth.FromContext(c.Request.Context()); p != nil {
+		if p.ResourceID != nil {
+			dv.ResMessageCode(c, http.StatusForbidden, "access_denied")
+			return
+		}
+		only = p.UserID
+	}
+	ctx, span := tracer.Start(c.Request.Context(), "passhash.Verify")
+	user, ok, httpCode, err := h.Store.VerifyUserPassword(ctx, username, password, only)
+	span.End()
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	if !ok {
+		logging.FromContext(c.Request.Context()).Info("login failed", "username", logging.Mask(username))
+		dv.ResMessageCode(c, http.StatusUnauthorized, "invalid_credentials")
+		return
+	}
+
+	userData, e := json.Marshal(user.In(loc))
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", userData)
+}
@@ -0,0 +1,54 @@
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/subliker/backendproj/auth"
+	"github.com/subliker/backendproj/model"
+)
+
+func TestLoginUser(t *testing.T) {
+	store := &fakeStore{users: map[string]model.User{"andrew": {Id: 5, Username: "andrew", Password: "correct horse", Timezone: "UTC"}}}
+	h := NewHandler(store)
+	other, same := 6, 5
+	tests := []struct {
+		name               string
+		username, password string
+		p                  *auth.Principal
+		status             int
+	}{
+		{"right password", "andrew", "correct horse", nil, http.StatusOK},
+		{"wrong password", "andrew", "correct horse!", nil, http.StatusUnauthorized},
+		{"unknown username", "maria", "correct horse", nil, http.StatusUnauthorized},
+		{"no password", "andrew", "", nil, http.StatusBadRequest},
+		{"key of another user", "andrew", "correct horse", &auth.Principal{KeyID: 1, Scopes: []string{auth.ScopeUsersRead}, UserID: &other}, http.StatusUnauthorized},
+		{"key of another user, wrong password", "andrew", "wrong", &auth.Principal{KeyID: 1, Scopes: []string{auth.ScopeUsersRead}, UserID: &other}, http.StatusUnauthorized},
+		{"key of the user", "andrew", "correct horse", &auth.Principal{KeyID: 1, Scopes: []string{auth.ScopeUsersRead}, UserID: &same}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"username": {tt.username}, "password": {tt.password}}
+			req := httptest.NewRequest(http.MethodPost, "/user/login", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := serve(t, "/user/login", h.LoginUser, req, tt.p)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var user map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := user["password"]; user["id"] != 5.0 || ok {
+				t.Errorf("got %v, want user 5 without the password", user)
+			}
+		})
+	}
+}
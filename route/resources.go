@@ -0,0 +1,296 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/subliker/backendproj/auth"
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resourceNameMaxLength is the longest name of a resource in characters.
+const resourceNameMaxLength = 80
+
+// CreateResource godoc
+//
+//	@Summary		Add a resource
+//	@Description	A resource is a room or anything else that is booked, its bookings can't overlap. Requires the admin scope.
+//	@Tags			resource
+//	@Produce		json
+//	@Param   name   formData   string     true        "name (1 <= length <= 80)"
+//	@Param   timezone   formData   string     false        "IANA time zone for times of its bookings without an offset (default UTC)"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{object}	model.Resource
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/resource [post]
+func (h *Handler) CreateResource(c *gin.Context) {
+	if !auth.CanAccessAllResources(c.Request.Context()) {
+		dv.ResMessageCode(c, http.StatusForbidden, "access_denied")
+		return
+	}
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	var resource model.Resource
+	if resource.Name, err = resourceName(c.PostForm("name")); err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	timezone, err := dv.LoadTimezone(c.PostForm("timezone"))
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	resource.Timezone = timezone.String()
+
+	resource, httpCode, err := h.Store.AddResource(c.Request.Context(), resource)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+
+	resourceData, e := json.Marshal(resource.In(loc))
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", resourceData)
+}
+
+// GetResources godoc
+//
+//	@Summary		List resources
+//	@Description	A key restricted to a resource gets only it.
+//	@Tags			resource
+//	@Produce		json
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{array}		model.Resource
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/resource [get]
+func (h *Handler) GetResources(c *gin.Context) {
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	resources, httpCode, err := h.Store.GetResources(c.Request.Context())
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	// a key restricted to a resource sees only it
+	visible := resources[:0]
+	for _, resource := range resources {
+		if auth.CanAccessResource(c.Request.Context(), resource.Id) {
+			visible = append(visible, resource.In(loc))
+		}
+	}
+
+	resourcesData, e := json.Marshal(visible)
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", resourcesData)
+}
+
+// GetResource godoc
+//
+//	@Summary		Return a resource
+//	@Tags			resource
+//	@Produce		json
+//	@Param id path int required "resource id"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{object}	model.Resource
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/resource/{id} [get]
+func (h *Handler) GetResource(c *gin.Context) {
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if deniedResource(c, idI) {
+		return
+	}
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	resource, httpCode, err := h.Store.GetResourceByID(c.Request.Context(), idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+
+	resourceData, e := json.Marshal(resource.In(loc))
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", resourceData)
+}
+
+// UpdateResource godoc
+//
+//	@Summary		Update a resource
+//	@Description	(option) update name, timezone. Times of its bookings are kept, a new zone applies to times given later. Requires the admin scope.
+//	@Tags			resource
+//	@Produce		json
+//	@Param id path int required "resource id"
+//	@Param   name   formData   string     false        "name (1 <= length <= 80)"
+//	@Param   timezone   formData   string     false        "IANA time zone for times of its bookings without an offset"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{object}	model.Resource
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/resource/{id} [put]
+func (h *Handler) UpdateResource(c *gin.Context) {
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if deniedResource(c, idI) {
+		return
+	}
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	resource, httpCode, err := h.Store.GetResourceByID(c.Request.Context(), idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+
+	if name := c.PostForm("name"); name != "" {
+		if resource.Name, err = resourceName(name); err != nil {
+			dv.ResErr(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if c.PostForm("timezone") != "" {
+		timezone, err := dv.LoadTimezone(c.PostForm("timezone"))
+		if err != nil {
+			dv.ResErr(c, http.StatusBadRequest, err)
+			return
+		}
+		resource.Timezone = timezone.String()
+	}
+
+	resource, httpCode, err = h.Store.UpdateResource(c.Request.Context(), resource)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+
+	resourceData, e := json.Marshal(resource.In(loc))
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", resourceData)
+}
+
+// DeleteResource godoc
+//
+//	@Summary		Delete a resource
+//	@Description	409 resource_in_use while a booking takes it, delete its bookings first. Deleted bookings of it lose their resource_id. Requires the admin scope.
+//	@Tags			resource
+//	@Produce		json
+//	@Param id path int required "resource id"
+//	@Success		200				{object}	dv.ResMesOK
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		409				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/resource/{id} [delete]
+func (h *Handler) DeleteResource(c *gin.Context) {
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if deniedResource(c, idI) {
+		return
+	}
+	httpCode, err := h.Store.DeleteResource(c.Request.Context(), idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	dv.ResMessageCode(c, http.StatusOK, "resource_deleted")
+}
+
+// resourceName normalizes and checks the name of a resource.
+func resourceName(name string) (string, error) {
+	name = dv.Normalize(name)
+	if length := utf8.RuneCountInString(name); length < 1 || length > resourceNameMaxLength {
+		return "", dv.NewError("resource_name_length", i18n.Args{"min": 1, "max": resourceNameMaxLength})
+	}
+	return name, nil
+}
+
+// bookingResource returns the resource with the id in value for a booking, nil when value is empty.
+// It answers 400 and returns false when the id isn't a number or there's no such resource.
+func (h *Handler) bookingResource(c *gin.Context, value string) (*model.Resource, bool) {
+	if value == "" {
+		return nil, true
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "resource_id_incorrect")
+		return nil, false
+	}
+	if deniedResource(c, id) {
+		return nil, false
+	}
+	resource, httpCode, err := h.Store.GetResourceByID(c.Request.Context(), id)
+	if errors.Is(err, db.ErrNotFound) {
+		dv.ResMessageCode(c, http.StatusBadRequest, "booking_resource_not_exists")
+		return nil, false
+	}
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return nil, false
+	}
+	return &resource, true
+}
+
+// bookingLocation returns the zone that times of a booking without an offset are taken in:
+// the zone of its resource, or else of its user.
+func bookingLocation(user model.User, resource *model.Resource) (*time.Location, error) {
+	if resource != nil {
+		return dv.LoadTimezone(resource.Timezone)
+	}
+	return dv.LoadTimezone(user.Timezone)
+}
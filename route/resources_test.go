@@ -0,0 +1,207 @@
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subliker/backendproj/auth"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAddNewBookingTakesResourceZone(t *testing.T) {
+	user := model.User{Id: 5, Username: "andrew", Timezone: "Europe/Moscow"}
+	tests := []struct {
+		name        string
+		resource_id string
+		start       string
+		status      int
+		// want is the start time saved, in UTC
+		want time.Time
+	}{
+		{"without a resource in the user's zone", "", "2023-10-01 12:00:00", http.StatusOK, time.Date(2023, 10, 1, 9, 0, 0, 0, time.UTC)},
+		{"in the resource's zone", "3", "2023-10-01 12:00:00", http.StatusOK, time.Date(2023, 10, 1, 3, 0, 0, 0, time.UTC)},
+		{"an offset wins over the resource's zone", "3", "2023-10-01T12:00:00+02:00", http.StatusOK, time.Date(2023, 10, 1, 10, 0, 0, 0, time.UTC)},
+		{"unknown resource", "4", "2023-10-01 12:00:00", http.StatusBadRequest, time.Time{}},
+		{"resource id isn't a number", "room", "2023-10-01 12:00:00", http.StatusBadRequest, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{
+				users:     map[string]model.User{user.Username: user},
+				resources: map[int]model.Resource{3: {Id: 3, Name: "Room 2", Timezone: "Asia/Tokyo"}},
+			}
+			h := NewHandler(store)
+			end, _ := time.Parse(time.RFC3339, "2023-10-01T23:00:00Z")
+			form := url.Values{"user_id": {"5"}, "resource_id": {tt.resource_id}, "start_time": {tt.start}, "end_time": {end.Format(time.RFC3339)}}
+			req := httptest.NewRequest(http.MethodPost, "/booking", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := serve(t, "/booking", h.AddNewBooking, req, nil)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				if len(store.bookings) != 0 {
+					t.Errorf("added %v", store.bookings)
+				}
+				return
+			}
+			booking := store.bookings[1]
+			if !booking.Start_time.Equal(tt.want) {
+				t.Errorf("start %v, want %v", booking.Start_time.UTC(), tt.want)
+			}
+			if (booking.Resource_id != nil) != (tt.resource_id != "") {
+				t.Errorf("resource_id %v, want %q", booking.Resource_id, tt.resource_id)
+			}
+		})
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		ok    bool
+	}{
+		{"plain", "Meeting room 2", "Meeting room 2", true},
+		{"trimmed", "  Room 2 ", "Room 2", true},
+		{"empty", "", "", false},
+		{"only spaces", "   ", "", false},
+		{"80 characters", strings.Repeat("я", 80), strings.Repeat("я", 80), true},
+		{"81 characters", strings.Repeat("я", 81), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resourceName(tt.value)
+			if (err == nil) != tt.ok || got != tt.want {
+				t.Errorf("resourceName(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetResourceCalendar(t *testing.T) {
+	room, other, user := 3, 4, 5
+	start := time.Date(2023, 10, 1, 9, 0, 0, 0, time.UTC)
+	store := &fakeStore{
+		resources: map[int]model.Resource{room: {Id: room, Name: "Room 2", Timezone: "Asia/Tokyo"}},
+		bookings: map[int]model.Booking{
+			1: {Id: 1, User_id: 5, Resource_id: &room, Start_time: start, End_time: start.Add(time.Hour)},
+			2: {Id: 2, User_id: 6, Resource_id: &room, Start_time: start.Add(2 * time.Hour), End_time: start.Add(3 * time.Hour)},
+			3: {Id: 3, User_id: 5, Resource_id: &other, Start_time: start, End_time: start.Add(time.Hour)},
+		},
+	}
+	h := NewHandler(store)
+	tests := []struct {
+		name   string
+		id     string
+		p      *auth.Principal
+		status int
+	}{
+		{"bookings reader", "3", &auth.Principal{KeyID: 1, Scopes: []string{auth.ScopeBookingsRead}}, http.StatusOK},
+		{"key restricted to a user", "3", &auth.Principal{KeyID: 2, Scopes: []string{auth.ScopeBookingsRead}, UserID: &user}, http.StatusForbidden},
+		{"key restricted to the resource", "3", &auth.Principal{KeyID: 3, Scopes: []string{auth.ScopeBookingsRead}, ResourceID: &room}, http.StatusOK},
+		{"key restricted to another resource", "3", &auth.Principal{KeyID: 4, Scopes: []string{auth.ScopeBookingsRead}, ResourceID: &other}, http.StatusForbidden},
+		{"unknown resource", "7", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/resource/"+tt.id+"/calendar.ics", nil)
+			w := serve(t, "/resource/:id/calendar.ics", h.GetResourceCalendar, req, tt.p)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			body := w.Body.String()
+			for _, want := range []string{"TZID:Asia/Tokyo", "UID:booking-1@backendproj", "UID:booking-2@backendproj", "Bookings of Room 2"} {
+				if !strings.Contains(body, want) {
+					t.Errorf("feed hasn't %q:\n%s", want, body)
+				}
+			}
+			if strings.Contains(body, "UID:booking-3@") {
+				t.Errorf("feed has a booking of another resource:\n%s", body)
+			}
+		})
+	}
+}
+
+func TestResourceScopedKey(t *testing.T) {
+	room, desk := 3, 4
+	start := time.Date(2023, 10, 1, 9, 0, 0, 0, time.UTC)
+	newStore := func() *fakeStore {
+		return &fakeStore{
+			users: map[string]model.User{"andrew": {Id: 5, Username: "andrew", Timezone: "UTC"}},
+			resources: map[int]model.Resource{
+				room: {Id: room, Name: "Room 2", Timezone: "UTC"},
+				desk: {Id: desk, Name: "Desk 7", Timezone: "UTC"},
+			},
+			bookings: map[int]model.Booking{
+				1: {Id: 1, User_id: 5, Resource_id: &room, Start_time: start, End_time: start.Add(time.Hour)},
+				2: {Id: 2, User_id: 5, Resource_id: &desk, Start_time: start, End_time: start.Add(time.Hour)},
+				3: {Id: 3, User_id: 5, Start_time: start, End_time: start.Add(time.Hour)},
+			},
+		}
+	}
+	p := &auth.Principal{KeyID: 1, Scopes: []string{auth.ScopeBookingsWrite, auth.ScopeUsersRead}, ResourceID: &room}
+	form := func(path string, values url.Values) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+	booking := url.Values{"user_id": {"5"}, "start_time": {"2023-10-02T09:00:00Z"}, "end_time": {"2023-10-02T10:00:00Z"}}
+	with := func(resource string) url.Values {
+		values := url.Values{"resource_id": {resource}}
+		for k, v := range booking {
+			values[k] = v
+		}
+		return values
+	}
+	tests := []struct {
+		name    string
+		pattern string
+		handler func(h *Handler) gin.HandlerFunc
+		req     *http.Request
+		status  int
+	}{
+		{"its resource", "/resource/:id", func(h *Handler) gin.HandlerFunc { return h.GetResource }, httptest.NewRequest(http.MethodGet, "/resource/3", nil), http.StatusOK},
+		{"another resource", "/resource/:id", func(h *Handler) gin.HandlerFunc { return h.GetResource }, httptest.NewRequest(http.MethodGet, "/resource/4", nil), http.StatusForbidden},
+		{"update another resource", "/resource/:id", func(h *Handler) gin.HandlerFunc { return h.UpdateResource }, httptest.NewRequest(http.MethodPut, "/resource/4", nil), http.StatusForbidden},
+		{"delete another resource", "/resource/:id", func(h *Handler) gin.HandlerFunc { return h.DeleteResource }, httptest.NewRequest(http.MethodDelete, "/resource/4", nil), http.StatusForbidden},
+		{"create a resource", "/resource", func(h *Handler) gin.HandlerFunc { return h.CreateResource }, form("/resource", url.Values{"name": {"Room 3"}}), http.StatusForbidden},
+		{"booking of its resource", "/booking/:id", func(h *Handler) gin.HandlerFunc { return h.GetBookingDataById }, httptest.NewRequest(http.MethodGet, "/booking/1", nil), http.StatusOK},
+		{"booking of another resource", "/booking/:id", func(h *Handler) gin.HandlerFunc { return h.GetBookingDataById }, httptest.NewRequest(http.MethodGet, "/booking/2", nil), http.StatusForbidden},
+		{"booking without a resource", "/booking/:id", func(h *Handler) gin.HandlerFunc { return h.GetBookingDataById }, httptest.NewRequest(http.MethodGet, "/booking/3", nil), http.StatusForbidden},
+		{"book its resource", "/booking", func(h *Handler) gin.HandlerFunc { return h.AddNewBooking }, form("/booking", with("3")), http.StatusOK},
+		{"book another resource", "/booking", func(h *Handler) gin.HandlerFunc { return h.AddNewBooking }, form("/booking", with("4")), http.StatusForbidden},
+		{"book without a resource", "/booking", func(h *Handler) gin.HandlerFunc { return h.AddNewBooking }, form("/booking", booking), http.StatusForbidden},
+		{"users", "/user/:id", func(h *Handler) gin.HandlerFunc { return h.GetUserDataById }, httptest.NewRequest(http.MethodGet, "/user/5", nil), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, tt.pattern, tt.handler(NewHandler(newStore())), tt.req, p)
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+
+	t.Run("list", func(t *testing.T) {
+		w := serve(t, "/resource", NewHandler(newStore()).GetResources, httptest.NewRequest(http.MethodGet, "/resource", nil), p)
+		var resources []model.Resource
+		if err := json.Unmarshal(w.Body.Bytes(), &resources); err != nil {
+			t.Fatal(err)
+		}
+		if len(resources) != 1 || resources[0].Id != room {
+			t.Errorf("listed %v, want only resource %d", resources, room)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package route
+
+import (
+	"net/http"
+	"strconv"
+
+	dv "github.com/subliker/backendproj/datavalidator"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RestoreUser godoc
+//
+//	@Summary		Restore a deleted user
+//	@Description	Restores the user with the bookings deleted with it, until they're purged (purge.retention after the deletion).
+//	@Description	409 if the user isn't deleted or its username is taken by another user since.
+//	@Tags			user
+//	@Produce		json
+//	@Param id path int required "user id"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{object}	model.User
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		409				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/user/{id}/restore [post]
+func (h *Handler) RestoreUser(c *gin.Context) {
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if denied(c, idI) {
+		return
+	}
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user, httpCode, err := h.Store.RestoreUser(c.Request.Context(), idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	c.JSON(http.StatusOK, user.In(loc))
+}
+
+// RestoreBooking godoc
+//
+//	@Summary		Restore a deleted booking
+//	@Description	Restores the booking until it's purged (purge.retention after the deletion).
+//	@Description	409 if the booking isn't deleted, its user is deleted (restore the user instead) or it overlaps a booking made since.
+//	@Tags			booking
+//	@Produce		json
+//	@Param id path int required "booking id"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
+//	@Success		200				{object}	model.Booking
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		409				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/booking/{id}/restore [post]
+func (h *Handler) RestoreBooking(c *gin.Context) {
+	ctx := c.Request.Context()
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	// the owner is checked before anything is restored
+	booking, httpCode, err := h.Store.GetBookingDataByIDWithDeleted(ctx, idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	if deniedBooking(c, booking) {
+		return
+	}
+
+	booking, httpCode, err = h.Store.RestoreBooking(ctx, idI)
+	if err != nil {
+		countConflict(err)
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	c.JSON(http.StatusOK, booking.In(loc))
+}
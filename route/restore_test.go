@@ -0,0 +1,41 @@
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/metrics"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRestoreBookingCountsConflicts(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		status    int
+		conflicts float64
+	}{
+		{"restored", nil, http.StatusOK, 0},
+		{"overlaps a booking made since", dv.NewError("booking_conflict", i18n.Args{"id": 2}), http.StatusConflict, 1},
+		{"not deleted", dv.NewError("booking_not_deleted", nil), http.StatusConflict, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{bookings: map[int]model.Booking{1: {Id: 1, User_id: 5}}, restoreErr: tt.err}
+			before := testutil.ToFloat64(metrics.BookingConflicts)
+			req := httptest.NewRequest(http.MethodPost, "/booking/1/restore", nil)
+			w := serve(t, "/booking/:id/restore", NewHandler(store).RestoreBooking, req, nil)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := testutil.ToFloat64(metrics.BookingConflicts) - before; got != tt.conflicts {
+				t.Errorf("counted %v conflicts, want %v", got, tt.conflicts)
+			}
+		})
+	}
+}
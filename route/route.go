@@ -1,21 +1,113 @@
 package route
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/subliker/backendproj/auth"
 	dv "github.com/subliker/backendproj/datavalidator"
 	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/logging"
+	"github.com/subliker/backendproj/metrics"
 	"github.com/subliker/backendproj/model"
+	"github.com/subliker/backendproj/passhash"
+	"github.com/subliker/backendproj/stream"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
 )
 
-var DataBase db.DataBase
-var DB = DataBase.Init()
+// Store is the storage used by the handlers, *db.DataBase in production.
+type Store interface {
+	AddNewUser(ctx context.Context, user model.User) (int, db.HttpCode, error)
+	GetUserDataByID(ctx context.Context, id int) (model.User, db.HttpCode, error)
+	GetUserDataByIDWithDeleted(ctx context.Context, id int) (model.User, db.HttpCode, error)
+	DeleteUserByID(ctx context.Context, id int) (db.HttpCode, error)
+	UpdateUserData(ctx context.Context, user model.User) (model.User, db.HttpCode, error)
+	CheckUsernameExists(ctx context.Context, username string) (bool, db.HttpCode, error)
+	RestoreUser(ctx context.Context, id int) (model.User, db.HttpCode, error)
+	VerifyUserPassword(ctx context.Context, username, password string, userID *int) (model.User, bool, db.HttpCode, error)
+
+	AddNewBooking(ctx context.Context, booking model.Booking) (int, db.HttpCode, error)
+	GetBookingDataByID(ctx context.Context, id int) (model.Booking, db.HttpCode, error)
+	GetBookingDataByIDWithDeleted(ctx context.Context, id int) (model.Booking, db.HttpCode, error)
+	GetBookings(ctx context.Context, limit, page, offset string, includeDeleted bool) (db.BookingsData, db.HttpCode, error)
+	RestoreBooking(ctx context.Context, id int) (model.Booking, db.HttpCode, error)
+	DeleteBookingByID(ctx context.Context, id int) (db.HttpCode, error)
+	UpdateBookingData(ctx context.Context, booking model.Booking) (model.Booking, db.HttpCode, error)
+	GetUserBookings(ctx context.Context, userID int) ([]model.Booking, db.HttpCode, error)
+	GetResourceBookings(ctx context.Context, resourceID int) ([]model.Booking, db.HttpCode, error)
+	GetUserBookingsBetween(ctx context.Context, userID int, from, to time.Time) ([]model.Booking, db.HttpCode, error)
+	GetResourceBookingsBetween(ctx context.Context, resourceID int, from, to time.Time) ([]model.Booking, db.HttpCode, error)
+	AddNewBookings(ctx context.Context, bookings []model.Booking) ([]model.Booking, db.HttpCode, error)
+	ExportBookings(ctx context.Context, filter db.ExportFilter, row func(model.Booking) error) (db.HttpCode, error)
+	ExportUsers(ctx context.Context, filter db.ExportFilter, row func(model.User) error) (db.HttpCode, error)
+	GetUsersByIDs(ctx context.Context, ids []int) (map[int]model.User, db.HttpCode, error)
+	GetBookingsByIDs(ctx context.Context, ids []int) (map[int]model.Booking, db.HttpCode, error)
+	BatchBookings(ctx context.Context, ops []db.BookingOp, atomic bool) ([]db.BookingOpResult, bool, db.HttpCode, error)
+
+	SetCalendarToken(ctx context.Context, userID int, prefix, hash string) (model.CalendarToken, db.HttpCode, error)
+	GetCalendarToken(ctx context.Context, userID int) (model.CalendarToken, db.HttpCode, error)
+	DeleteCalendarToken(ctx context.Context, userID int) (db.HttpCode, error)
+
+	AddResource(ctx context.Context, resource model.Resource) (model.Resource, db.HttpCode, error)
+	GetResources(ctx context.Context) ([]model.Resource, db.HttpCode, error)
+	GetResourceByID(ctx context.Context, id int) (model.Resource, db.HttpCode, error)
+	GetResourcesByIDs(ctx context.Context, ids []int) (map[int]model.Resource, db.HttpCode, error)
+	UpdateResource(ctx context.Context, resource model.Resource) (model.Resource, db.HttpCode, error)
+	DeleteResource(ctx context.Context, id int) (db.HttpCode, error)
+
+	AddAPIKey(ctx context.Context, key model.APIKey) (model.APIKey, db.HttpCode, error)
+	GetAPIKeys(ctx context.Context) ([]model.APIKey, db.HttpCode, error)
+	RotateAPIKey(ctx context.Context, id int, prefix, hash string) (model.APIKey, db.HttpCode, error)
+	RevokeAPIKey(ctx context.Context, id int) (db.HttpCode, error)
+
+	AddWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, db.HttpCode, error)
+	GetWebhooks(ctx context.Context) ([]model.Webhook, db.HttpCode, error)
+	GetWebhookByID(ctx context.Context, id int) (model.Webhook, db.HttpCode, error)
+	UpdateWebhook(ctx context.Context, webhook model.Webhook) (model.Webhook, db.HttpCode, error)
+	DeleteWebhook(ctx context.Context, id int) (db.HttpCode, error)
+	GetWebhookDeliveries(ctx context.Context, webhookID int, status string, limit int) ([]model.WebhookDelivery, db.HttpCode, error)
+	RedeliverWebhookDelivery(ctx context.Context, id int64) (model.WebhookDelivery, db.HttpCode, error)
+	GetOutboxAfter(ctx context.Context, afterID int64, limit int) ([]model.OutboxMessage, db.HttpCode, error)
+
+	GetAuditLog(ctx context.Context, filter db.AuditFilter) ([]model.AuditEntry, db.HttpCode, error)
+	GetEntityHistory(ctx context.Context, entity string, id int) ([]model.AuditEntry, db.HttpCode, error)
+	VerifyAuditLog(ctx context.Context) (db.AuditVerification, db.HttpCode, error)
+}
+
+// Handler serves the user and booking endpoints.
+type Handler struct {
+	Store Store
+	// LegacyNotFound answers 200 {} instead of 404 for a missing user or booking on GET by id
+	LegacyNotFound bool
+	// Stream has the live events of the booking stream
+	Stream *stream.Broker
+	// StreamHeartbeat is how often an idle stream gets a keep-alive
+	StreamHeartbeat time.Duration
+	// ImportMaxBookings is the most bookings an .ics import may create
+	ImportMaxBookings int
+	// ImportHorizon is how far from now endless repeating events are imported
+	ImportHorizon time.Duration
+	// BatchMaxOperations is the most operations of a booking batch
+	BatchMaxOperations int
+}
+
+func NewHandler(store Store) *Handler {
+	return &Handler{
+		Store:              store,
+		Stream:             stream.NewBroker(256),
+		StreamHeartbeat:    15 * time.Second,
+		ImportMaxBookings:  1000,
+		ImportHorizon:      365 * 24 * time.Hour,
+		BatchMaxOperations: 500,
+	}
+}
 
 // AddNewUser godoc
 //
@@ -24,54 +116,82 @@ var DB = DataBase.Init()
 //	@Tags			user
 //
 //	@Param   username   formData   string     true        "username (3 <= length <= 20, exclude=\"\\\/")"
-//	@Param   password   formData   string     true        "password (6 <= length <= 20, exclude=\"\\\/")"
+//	@Param   password   formData   string     true        "password (8 <= length <= 64 by default, not common or breached)"
+//	@Param   timezone   formData   string     false        "IANA time zone for times without an offset (default UTC)"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
 //
 //	@Success		200				{object}	model.User
 //	@Failure		400				{object}	dv.ResError
 //	@Failure		500				{object}	dv.ResError
 //	@Router			/user [post]
-func AddNewUser(c *gin.Context) {
+func (h *Handler) AddNewUser(c *gin.Context) {
 	var user model.User
-	fmt.Println(c.PostForm("username"))
-	err := dv.ValidateUsername(c.PostForm("username"))
+	if !auth.CanAccessAllUsers(c.Request.Context()) {
+		dv.ResMessageCode(c, http.StatusForbidden, "access_denied")
+		return
+	}
+	loc, err := responseLocation(c)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
-	user.Username = c.PostForm("username")
+
+	username := dv.Normalize(c.PostForm("username"))
+	err = dv.ValidateUsername(username)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	usernameExists, httpCodeE, errE := h.Store.CheckUsernameExists(c.Request.Context(), username)
+	if errE != nil {
+		dv.ResErr(c, int(httpCodeE), errE)
+		return
+	}
+	if usernameExists {
+		dv.ResMessageCode(c, http.StatusBadRequest, "username_exists")
+		return
+	}
+	user.Username = username
 
 	err = dv.ValidatePassword(c.PostForm("password"))
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
-	passwordHashed, errh := dv.HashPassword(c.PostForm("password"))
+	passwordHashed, errh := hashPassword(c.Request.Context(), c.PostForm("password"))
 	if errh != nil {
-		dv.ResMessage(c, http.StatusInternalServerError, dv.ErrToString(errh))
+		dv.ResErr(c, http.StatusInternalServerError, errh)
 		return
 	}
 	user.Password = passwordHashed
 
-	t := time.Now()
-	ts := t.Format("2006-01-02 15:04:05")
-	user.Created_at = ts
-	user.Updated_at = ts
+	timezone, err := dv.LoadTimezone(c.PostForm("timezone"))
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	user.Timezone = timezone.String()
 
-	user_id, httpCodeA, errA := DataBase.AddNewUser(user)
+	t := time.Now().UTC()
+	user.Created_at = t
+	user.Updated_at = t
+
+	user_id, httpCodeA, errA := h.Store.AddNewUser(c.Request.Context(), user)
 	if errA != nil {
-		dv.ResMessage(c, int(httpCodeA), dv.ErrToString(errA))
+		dv.ResErr(c, int(httpCodeA), errA)
 		return
 	}
+	logging.FromContext(c.Request.Context()).Info("user created", "user_id", user_id, "username", user.Username)
 
-	user, httpCodeG, errG := DataBase.GetUserDataByID(user_id)
+	user, httpCodeG, errG := h.Store.GetUserDataByID(c.Request.Context(), user_id)
 	if errG != nil {
-		dv.ResMessage(c, int(httpCodeG), dv.ErrToString(errG))
+		dv.ResErr(c, int(httpCodeG), errG)
 		return
 	}
 
-	userData, e := json.Marshal(user)
+	userData, e := json.Marshal(user.In(loc))
 	if e != nil {
-		dv.ResMessage(c, http.StatusInternalServerError, dv.ErrToString(e))
+		dv.ResErr(c, http.StatusInternalServerError, e)
 		return
 	}
 
@@ -81,39 +201,61 @@ func AddNewUser(c *gin.Context) {
 // GetUserDataById godoc
 //
 //	@Summary		Return user data (json) by id
-//	@Description	If user isn't found, it returns blank json
+//	@Description	404 if the user isn't found or is deleted ({} with api.legacy_not_found), admins find deleted users with include_deleted
 //	@Tags			user
 //	@Produce		json
 //	@Param id path int required "id to find user"
+//	@Param   include_deleted   query   bool     false        "find a deleted user too (admin scope)"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
 //	@Success		200				{object}	model.User
 //	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
 //	@Failure		500				{object}	dv.ResError
 //	@Router			/user/{id} [get]
-func GetUserDataById(c *gin.Context) {
+func (h *Handler) GetUserDataById(c *gin.Context) {
 	id := c.Param("id")
 	if c.Param("id") == "" {
-		dv.ResMessage(c, http.StatusBadRequest, "id isn`t set")
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_not_set")
 		return
 	}
 	idI, err := strconv.Atoi(id)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if denied(c, idI) {
 		return
 	}
 
-	user, httpCodeG, errG := DataBase.GetUserDataByID(idI)
+	loc, err := responseLocation(c)
 	if err != nil {
-		dv.ResMessage(c, int(httpCodeG), dv.ErrToString(errG))
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
 
-	if user == (model.User{}) {
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+	get := h.Store.GetUserDataByID
+	if withDeleted {
+		get = h.Store.GetUserDataByIDWithDeleted
+	}
+	user, httpCodeG, errG := get(c.Request.Context(), idI)
+	if errors.Is(errG, db.ErrNotFound) && h.LegacyNotFound {
 		c.Data(http.StatusOK, "application/json", []byte("{}"))
 		return
 	}
-	userData, e := json.Marshal(user)
+	if errG != nil {
+		dv.ResErr(c, int(httpCodeG), errG)
+		return
+	}
+	userData, e := json.Marshal(user.In(loc))
 	if e != nil {
-		fmt.Println(e)
+		logging.FromContext(c.Request.Context()).Error("marshal user", "err", e)
+		dv.ResMessageCode(c, http.StatusInternalServerError, "internal_error")
+		return
 	}
 	c.Data(http.StatusOK, "application/json", userData)
 }
@@ -127,114 +269,135 @@ func GetUserDataById(c *gin.Context) {
 //	@Param id path int required "id to find user"
 //	@Success		200				{object}	dv.ResMesOK
 //	@Failure		400				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
 //	@Failure		500				{object}	dv.ResError
 //	@Router			/user/{id} [delete]
-func DeleteUserDataByID(c *gin.Context) {
+func (h *Handler) DeleteUserDataByID(c *gin.Context) {
 	id := c.Param("id")
 	if c.Param("id") == "" {
-		dv.ResMessage(c, http.StatusBadRequest, "id isn`t set")
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_not_set")
 		return
 	}
 	idI, err := strconv.Atoi(id)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if denied(c, idI) {
 		return
 	}
 
-	httpCodeD, errD := DataBase.DeleteUserByID(idI)
+	httpCodeD, errD := h.Store.DeleteUserByID(c.Request.Context(), idI)
 	if errD != nil {
-		dv.ResMessage(c, int(httpCodeD), dv.ErrToString(errD))
+		dv.ResErr(c, int(httpCodeD), errD)
 		return
 	}
 
-	dv.ResMessage(c, http.StatusOK, "user was successfully deleted")
+	dv.ResMessageCode(c, http.StatusOK, "user_deleted")
 }
 
 // UpdateUserDataById godoc
 //
 // @Summary	Update user data by id
-// @Description (option) update username(to unique), password
+// @Description (option) update username(to unique), password, timezone
 // @Tags user
 // @Produce json
 // @Param   id   path   int     true        "user id"
 // @Param   username   formData   string     false        "username (3 <= length <= 20, exclude=\"\\\/")"
-// @Param   password   formData   string     false        "password (6 <= length <= 20, exclude=\"\\\/")"
+// @Param   password   formData   string     false        "password (8 <= length <= 64 by default, not common or breached)"
+// @Param   timezone   formData   string     false        "IANA time zone for times without an offset"
+// @Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
 // @Success		200				{object}	model.User
 // @Failure		400				{object}	dv.ResError
+// @Failure		404				{object}	dv.Problem
 // @Failure		500				{object}	dv.ResError
 // @Router /user/{id} [put]
-func UpdateUserDataById(c *gin.Context) {
+func (h *Handler) UpdateUserDataById(c *gin.Context) {
 	id := c.Param("id")
 	if c.Param("id") == "" {
-		dv.ResMessage(c, http.StatusBadRequest, "id isn`t set")
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_not_set")
 		return
 	}
 	idI, err := strconv.Atoi(id)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if denied(c, idI) {
+		return
+	}
+
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
 
 	var user model.User
-	user, httpCodeG, errG := DataBase.GetUserDataByID(idI)
+	user, httpCodeG, errG := h.Store.GetUserDataByID(c.Request.Context(), idI)
 	if errG != nil {
-		dv.ResMessage(c, int(httpCodeG), dv.ErrToString(err))
+		dv.ResErr(c, int(httpCodeG), errG)
 		return
 	}
 
-	if c.PostForm("username") != "" {
-		usernameExists, httpCode, err := DataBase.CheckUsernameExists(c.PostForm("username"))
+	if username := dv.Normalize(c.PostForm("username")); username != "" {
+		err := dv.ValidateUsername(username)
 		if err != nil {
-			dv.ResMessage(c, int(httpCode), dv.ErrToString(err))
+			dv.ResErr(c, http.StatusBadRequest, err)
 			return
 		}
-		if usernameExists {
-			dv.ResMessage(c, http.StatusBadRequest, "username already exists")
-			return
+		// renaming "andrew" to "Andrew" must not collide with the user itself
+		if dv.UsernameSkeleton(username) != dv.UsernameSkeleton(user.Username) {
+			usernameExists, httpCode, err := h.Store.CheckUsernameExists(c.Request.Context(), username)
+			if err != nil {
+				dv.ResErr(c, int(httpCode), err)
+				return
+			}
+			if usernameExists {
+				dv.ResMessageCode(c, http.StatusBadRequest, "username_exists")
+				return
+			}
 		}
-		err = dv.ValidateUsername(c.PostForm("username"))
-		if err != nil {
-			dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
-			return
-		}
-		user.Username = c.PostForm("username")
+		user.Username = username
 	}
 
 	if c.PostForm("password") != "" {
 		err := dv.ValidatePassword(c.PostForm("password"))
 		if err != nil {
-			dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+			dv.ResErr(c, http.StatusBadRequest, err)
 			return
 		}
-		passwordHashed, errh := dv.HashPassword(c.PostForm("password"))
+		passwordHashed, errh := hashPassword(c.Request.Context(), c.PostForm("password"))
 		if errh != nil {
-			dv.ResMessage(c, http.StatusInternalServerError, dv.ErrToString(errh))
+			dv.ResErr(c, http.StatusInternalServerError, errh)
 			return
 		}
 		user.Password = passwordHashed
 	}
 
-	t := time.Now()
-	ts := t.Format("2006-01-02 15:04:05")
-	user.Updated_at = ts
-	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
-		return
+	if c.PostForm("timezone") != "" {
+		timezone, err := dv.LoadTimezone(c.PostForm("timezone"))
+		if err != nil {
+			dv.ResErr(c, http.StatusBadRequest, err)
+			return
+		}
+		user.Timezone = timezone.String()
 	}
 
-	user, httpCodeU, errU := DataBase.UpdateUserData(user)
+	user.Updated_at = time.Now().UTC()
 	if err != nil {
-		dv.ResMessage(c, int(httpCodeU), dv.ErrToString(errU))
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
 
-	if user == (model.User{}) {
-		dv.ResMessage(c, http.StatusBadRequest, "User wasn't found")
+	user, httpCodeU, errU := h.Store.UpdateUserData(c.Request.Context(), user)
+	if errU != nil {
+		dv.ResErr(c, int(httpCodeU), errU)
 		return
 	}
-	userData, e := json.Marshal(user)
+	userData, e := json.Marshal(user.In(loc))
 	if e != nil {
-		dv.ResMessage(c, http.StatusInternalServerError, dv.ErrToString(e))
+		dv.ResErr(c, http.StatusInternalServerError, e)
 		return
 	}
 
@@ -245,72 +408,106 @@ func UpdateUserDataById(c *gin.Context) {
 //
 //	@Summary		Add new booking data in db
 //	@Description	Prepairing booking data for new booking (linked to user) in db
+//	@Description	409 booking_conflict if it overlaps another booking of the resource, bookings without a resource aren't checked.
 //	@Tags			booking
 //
 //	@Param   user_id   formData   int     true        "user_id (user is exists)"
-//	@Param   start_time   formData   string     true        "start_time (YYYY-MM-DD HH:MM:SS)"
-//	@Param   end_time   formData   string     true        "end_time (YYYY-MM-DD HH:MM:SS)"
+//	@Param   resource_id   formData   int     false        "resource_id (resource is exists)"
+//	@Param   start_time   formData   string     true        "start_time (RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user)"
+//	@Param   end_time   formData   string     true        "end_time (RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user)"
 //	@Param   comment   formData   string     false        "comment (5 <= length <= 120, exclude=\"\\\/")"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
 //
 //	@Success		200				{object}	model.Booking
 //	@Failure		400				{object}	dv.ResError
+//	@Failure		409				{object}	dv.ResError
 //	@Failure		500				{object}	dv.ResError
 //	@Router			/booking [post]
-func AddNewBooking(c *gin.Context) {
+func (h *Handler) AddNewBooking(c *gin.Context) {
 	var booking model.Booking
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+
 	user_id := c.PostForm("user_id")
 	if user_id == "" {
-		dv.ResMessage(c, http.StatusBadRequest, "user_id isn`t set")
+		dv.ResMessageCode(c, http.StatusBadRequest, "user_id_not_set")
 		return
 	}
 	user_idI, err := strconv.Atoi(user_id)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResMessageCode(c, http.StatusBadRequest, "user_id_incorrect")
 		return
 	}
+	booking.User_id = user_idI
 
-	user, httpCodeG, errG := DataBase.GetUserDataByID(user_idI)
-	if errG != nil {
-		dv.ResMessage(c, int(httpCodeG), dv.ErrToString(errG))
+	resource, ok := h.bookingResource(c, c.PostForm("resource_id"))
+	if !ok {
 		return
 	}
-	if user == (model.User{}) {
-		dv.ResMessage(c, http.StatusBadRequest, "user with this user_id doesn't exist")
+	if resource != nil {
+		booking.Resource_id = &resource.Id
+	}
+	if deniedBooking(c, booking) {
 		return
 	}
-	booking.User_id = user_idI
 
-	booking.Start_time = c.PostForm("start_time")
-	booking.End_time = c.PostForm("end_time")
+	user, httpCodeG, errG := h.Store.GetUserDataByID(c.Request.Context(), user_idI)
+	if errors.Is(errG, db.ErrNotFound) {
+		dv.ResMessageCode(c, http.StatusBadRequest, "booking_user_not_exists")
+		return
+	}
+	if errG != nil {
+		dv.ResErr(c, int(httpCodeG), errG)
+		return
+	}
+	bookingLoc, err := bookingLocation(user, resource)
+	if err != nil {
+		dv.ResErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	booking.Start_time, err = dv.ParseTime(c.PostForm("start_time"), bookingLoc)
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "start_time_incorrect")
+		return
+	}
+	booking.End_time, err = dv.ParseTime(c.PostForm("end_time"), bookingLoc)
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "end_time_incorrect")
+		return
+	}
 	err = dv.CheckCorrectTimeDuration(booking.Start_time, booking.End_time)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
 
-	comment := c.PostForm("comment")
+	comment := dv.Normalize(c.PostForm("comment"))
 	booking.Comment = comment
 	err = dv.ValidateComment(comment)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
 
-	booking_id, httpCodeA, errA := DataBase.AddNewBooking(booking)
+	booking_id, httpCodeA, errA := h.Store.AddNewBooking(c.Request.Context(), booking)
 	if errA != nil {
-		dv.ResMessage(c, int(httpCodeA), dv.ErrToString(errA))
+		countConflict(errA)
+		dv.ResErr(c, int(httpCodeA), errA)
 		return
 	}
 
-	booking, httpCodeGN, errGN := DataBase.GetBookingDataByID(booking_id)
+	booking, httpCodeGN, errGN := h.Store.GetBookingDataByID(c.Request.Context(), booking_id)
 	if errGN != nil {
-		dv.ResMessage(c, int(httpCodeGN), dv.ErrToString(errGN))
+		dv.ResErr(c, int(httpCodeGN), errGN)
 		return
 	}
 
-	bookingData, e := json.Marshal(booking)
+	bookingData, e := json.Marshal(booking.In(loc))
 	if e != nil {
-		dv.ResMessage(c, http.StatusInternalServerError, dv.ErrToString(e))
+		dv.ResErr(c, http.StatusInternalServerError, e)
 		return
 	}
 
@@ -320,40 +517,60 @@ func AddNewBooking(c *gin.Context) {
 // GetBookingDataById godoc
 //
 //	@Summary		Return booking data (json) by id
-//	@Description	If booking isn't found, it returns blank json
+//	@Description	404 if the booking isn't found or is deleted ({} with api.legacy_not_found), admins find deleted bookings with include_deleted
 //	@Tags			booking
 //	@Produce		json
 //	@Param id path int required "id to find booking"
+//	@Param   include_deleted   query   bool     false        "find a deleted booking too (admin scope)"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
 //	@Success		200				{object}	model.Booking
 //	@Failure		400				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
 //	@Failure		500				{object}	dv.ResError
 //	@Router			/booking/{id} [get]
-func GetBookingDataById(c *gin.Context) {
+func (h *Handler) GetBookingDataById(c *gin.Context) {
 	var booking model.Booking
 	id := c.Param("id")
 	if c.Param("id") == "" {
-		dv.ResMessage(c, http.StatusBadRequest, "id isn`t set")
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_not_set")
 		return
 	}
 	idI, err := strconv.Atoi(id)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
 		return
 	}
 
-	booking, httpCodeG, errG := DataBase.GetBookingDataByID(idI)
-	if errG != nil {
-		dv.ResMessage(c, int(httpCodeG), dv.ErrToString(errG))
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
 
-	if booking == (model.Booking{}) {
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+	get := h.Store.GetBookingDataByID
+	if withDeleted {
+		get = h.Store.GetBookingDataByIDWithDeleted
+	}
+	booking, httpCodeG, errG := get(c.Request.Context(), idI)
+	if errors.Is(errG, db.ErrNotFound) && h.LegacyNotFound {
 		c.Data(http.StatusOK, "application/json", []byte("{}"))
 		return
 	}
-	bookingData, e := json.Marshal(booking)
+	if errG != nil {
+		dv.ResErr(c, int(httpCodeG), errG)
+		return
+	}
+	if deniedBooking(c, booking) {
+		return
+	}
+	bookingData, e := json.Marshal(booking.In(loc))
 	if e != nil {
-		fmt.Println(e)
+		logging.FromContext(c.Request.Context()).Error("marshal booking", "err", e)
+		dv.ResMessageCode(c, http.StatusInternalServerError, "internal_error")
 		return
 	}
 	c.Data(http.StatusOK, "application/json", bookingData)
@@ -368,52 +585,83 @@ func GetBookingDataById(c *gin.Context) {
 //	@Param id path int required "id to find booking"
 //	@Success		200				{object}	dv.ResMesOK
 //	@Failure		400				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
 //	@Failure		500				{object}	dv.ResError
 //	@Router			/booking/{id} [delete]
-func DeleteBookingByID(c *gin.Context) {
+func (h *Handler) DeleteBookingByID(c *gin.Context) {
 	id := c.Param("id")
 	if c.Param("id") == "" {
-		dv.ResMessage(c, http.StatusBadRequest, "id isn`t set")
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_not_set")
 		return
 	}
 	idI, err := strconv.Atoi(id)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
 		return
 	}
 
-	httpCodeD, errD := DataBase.DeleteBookingByID(idI)
+	booking, httpCodeG, errG := h.Store.GetBookingDataByID(c.Request.Context(), idI)
+	if errG != nil {
+		dv.ResErr(c, int(httpCodeG), errG)
+		return
+	}
+	if deniedBooking(c, booking) {
+		return
+	}
+
+	httpCodeD, errD := h.Store.DeleteBookingByID(c.Request.Context(), idI)
 	if errD != nil {
-		dv.ResMessage(c, int(httpCodeD), dv.ErrToString(errD))
+		dv.ResErr(c, int(httpCodeD), errD)
 		return
 	}
 
-	dv.ResMessage(c, http.StatusOK, "booking was successfully deleted")
+	dv.ResMessageCode(c, http.StatusOK, "booking_deleted")
 }
 
 // GetBookings godoc
 //
 //	@Summary		Return all bookings
-//	@Description	(optional) set limit or limit with page or limit with offset
+//	@Description	(optional) set limit or limit with page or limit with offset. Deleted bookings are listed only with include_deleted.
 //	@Tags			booking
 //	@Produce		json
 //	@Param        limit    query     int  false  "limit"
 //	@Param        page    query     int  false  "page"
 //	@Param        offset    query     int  false  "offset"
+//	@Param   include_deleted   query   bool     false        "list deleted bookings too (admin scope)"
+//	@Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
 //	@Success		200				{object}	db.BookingsData
 //	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
 //	@Failure		500				{object}	dv.ResError
 //	@Router			/booking [get]
-func GetBookings(c *gin.Context) {
-	bookings, httpCode, err := DataBase.GetBookings(c.Query("limit"), c.Query("page"), c.Query("offset"))
+func (h *Handler) GetBookings(c *gin.Context) {
+	if !auth.CanAccessAllUsers(c.Request.Context()) {
+		dv.ResMessageCode(c, http.StatusForbidden, "access_denied")
+		return
+	}
+	loc, err := responseLocation(c)
 	if err != nil {
-		dv.ResMessage(c, int(httpCode), dv.ErrToString(err))
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
 
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+
+	bookings, httpCode, err := h.Store.GetBookings(c.Request.Context(), c.Query("limit"), c.Query("page"), c.Query("offset"), withDeleted)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	for i := range bookings.Rows {
+		bookings.Rows[i] = bookings.Rows[i].In(loc)
+	}
+
 	jsonData, e := json.Marshal(bookings)
 	if e != nil {
-		dv.ResMessage(c, http.StatusInternalServerError, dv.ErrToString(e))
+		dv.ResErr(c, http.StatusInternalServerError, e)
 		return
 	}
 
@@ -424,70 +672,177 @@ func GetBookings(c *gin.Context) {
 //
 // @Summary	Update booking data by id
 // @Description (option) update start_time, end_time, comment
+// @Description 409 booking_conflict if the booking would overlap another booking of its resource, bookings without a resource aren't checked. The resource can't be changed.
 // @Tags booking
 // @Produce json
 // @Param   id   path   int     true        "booking id"
-// @Param   start_time   formData   string     false        "start_time (YYYY-MM-DD HH:MM:SS)"
-// @Param   end_time   formData   string     false        "end_time (YYYY-MM-DD HH:MM:SS)"
+// @Param   start_time   formData   string     false        "start_time (RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user)"
+// @Param   end_time   formData   string     false        "end_time (RFC 3339, or YYYY-MM-DD HH:MM:SS in the timezone of the resource, or else of the user)"
 // @Param   comment   formData   string     false        "comment (5 <= length <= 120, exclude=\"\\\/")"
+// @Param   tz   query   string     false        "IANA time zone to render times in (or Time-Zone header), default UTC"
 // @Success		200				{object}	model.Booking
 // @Failure		400				{object}	dv.ResError
+// @Failure		404				{object}	dv.Problem
+// @Failure		409				{object}	dv.ResError
 // @Failure		500				{object}	dv.ResError
 // @Router /booking/{id} [put]
-func UpdateBookingDataById(c *gin.Context) {
+func (h *Handler) UpdateBookingDataById(c *gin.Context) {
 	id := c.Param("id")
 	if c.Param("id") == "" {
-		dv.ResMessage(c, http.StatusBadRequest, "id isn`t set")
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_not_set")
 		return
 	}
 	idI, err := strconv.Atoi(id)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+
+	loc, err := responseLocation(c)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
 
 	var booking model.Booking
-	booking, httpCodeG, errG := DataBase.GetBookingDataByID(idI)
+	booking, httpCodeG, errG := h.Store.GetBookingDataByID(c.Request.Context(), idI)
 	if errG != nil {
-		dv.ResMessage(c, int(httpCodeG), dv.ErrToString(err))
+		dv.ResErr(c, int(httpCodeG), errG)
+		return
+	}
+	if deniedBooking(c, booking) {
 		return
 	}
 
-	if c.PostForm("start_time") != "" {
-		booking.Start_time = c.PostForm("start_time")
+	user, httpCodeGU, errGU := h.Store.GetUserDataByID(c.Request.Context(), booking.User_id)
+	if errGU != nil {
+		dv.ResErr(c, int(httpCodeGU), errGU)
+		return
 	}
-	if c.PostForm("end_time") != "" {
-		booking.End_time = c.PostForm("end_time")
+	var resource *model.Resource
+	if booking.Resource_id != nil {
+		r, httpCode, err := h.Store.GetResourceByID(c.Request.Context(), *booking.Resource_id)
+		if err != nil {
+			dv.ResErr(c, int(httpCode), err)
+			return
+		}
+		resource = &r
 	}
-
-	err = dv.CheckCorrectTimeDuration(booking.Start_time, booking.End_time)
+	bookingLoc, err := bookingLocation(user, resource)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResErr(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	err = dv.ValidateComment(c.PostForm("comment"))
+	if c.PostForm("start_time") != "" {
+		booking.Start_time, err = dv.ParseTime(c.PostForm("start_time"), bookingLoc)
+		if err != nil {
+			dv.ResMessageCode(c, http.StatusBadRequest, "start_time_incorrect")
+			return
+		}
+	}
+	if c.PostForm("end_time") != "" {
+		booking.End_time, err = dv.ParseTime(c.PostForm("end_time"), bookingLoc)
+		if err != nil {
+			dv.ResMessageCode(c, http.StatusBadRequest, "end_time_incorrect")
+			return
+		}
+	}
+
+	err = dv.CheckCorrectTimeDuration(booking.Start_time, booking.End_time)
 	if err != nil {
-		dv.ResMessage(c, http.StatusBadRequest, dv.ErrToString(err))
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
-	booking.Comment = c.PostForm("comment")
 
-	booking, httpCodeU, errU := DataBase.UpdateBookingData(booking)
+	comment := dv.Normalize(c.PostForm("comment"))
+	err = dv.ValidateComment(comment)
 	if err != nil {
-		dv.ResMessage(c, int(httpCodeU), dv.ErrToString(errU))
+		dv.ResErr(c, http.StatusBadRequest, err)
 		return
 	}
+	booking.Comment = comment
 
-	if booking == (model.Booking{}) {
-		dv.ResMessage(c, http.StatusBadRequest, "Booking wasn't found")
+	booking, httpCodeU, errU := h.Store.UpdateBookingData(c.Request.Context(), booking)
+	if errU != nil {
+		countConflict(errU)
+		dv.ResErr(c, int(httpCodeU), errU)
 		return
 	}
-	bookingData, e := json.Marshal(booking)
+	bookingData, e := json.Marshal(booking.In(loc))
 	if e != nil {
-		dv.ResMessage(c, http.StatusInternalServerError, dv.ErrToString(e))
+		dv.ResErr(c, http.StatusInternalServerError, e)
 		return
 	}
 
 	c.Data(http.StatusOK, "application/json", bookingData)
 }
+
+// responseLocation returns the time zone to render times in,
+// set by the tz query parameter or the Time-Zone header, UTC by default.
+func responseLocation(c *gin.Context) (*time.Location, error) {
+	name := c.Query("tz")
+	if name == "" {
+		name = c.GetHeader("Time-Zone")
+	}
+	return dv.LoadTimezone(name)
+}
+
+// includeDeleted reads the include_deleted parameter, only admins can set it.
+// It answers 400 or 403 and reports false as the second value when the parameter can't be used.
+func includeDeleted(c *gin.Context) (bool, bool) {
+	value := c.Query("include_deleted")
+	if value == "" {
+		return false, true
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		dv.ResErr(c, http.StatusBadRequest, dv.NewError("flag_incorrect", i18n.Args{"name": "include_deleted"}))
+		return false, false
+	}
+	if include && !auth.IsAdmin(c.Request.Context()) {
+		dv.ResErr(c, http.StatusForbidden, dv.NewError("scope_missing", i18n.Args{"scope": auth.ScopeAdmin}))
+		return false, false
+	}
+	return include, true
+}
+
+// denied answers 403 when the API key of the request is restricted to another user or to a resource.
+func denied(c *gin.Context, userID int) bool {
+	return deny(c, auth.CanAccessUser(c.Request.Context(), userID))
+}
+
+// deniedBooking answers 403 when the API key of the request is restricted to another user
+// than the one of booking, or to another resource than the one booking takes.
+func deniedBooking(c *gin.Context, booking model.Booking) bool {
+	return deny(c, auth.CanAccessBooking(c.Request.Context(), booking.User_id, booking.Resource_id))
+}
+
+// deniedResource answers 403 when the API key of the request is restricted to another resource.
+func deniedResource(c *gin.Context, id int) bool {
+	return deny(c, auth.CanAccessResource(c.Request.Context(), id))
+}
+
+func deny(c *gin.Context, allowed bool) bool {
+	if !allowed {
+		dv.ResMessageCode(c, http.StatusForbidden, "access_denied")
+	}
+	return !allowed
+}
+
+// countConflict counts err in metrics.BookingConflicts when it's a booking_conflict.
+func countConflict(err error) {
+	var coded *dv.Error
+	if errors.As(err, &coded) && coded.Code == "booking_conflict" {
+		metrics.BookingConflicts.Inc()
+	}
+}
+
+var tracer = otel.Tracer("github.com/subliker/backendproj/route")
+
+// hashPassword hashes password in its own span, hashing is meant to be slow.
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracer.Start(ctx, "passhash.Hash")
+	defer span.End()
+	return passhash.Default.Hash(password)
+}
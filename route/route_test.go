@@ -0,0 +1,177 @@
+package route
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/subliker/backendproj/auth"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeStore is a Store whose methods the tests need are set, the others panic.
+type fakeStore struct {
+	Store
+	bookings map[int]model.Booking
+	history  []model.AuditEntry
+	outbox   []model.OutboxMessage
+	// users are found by username, their password is the plain one
+	users map[string]model.User
+	// restoreErr is what RestoreBooking fails with
+	restoreErr error
+	resources  map[int]model.Resource
+	// exportErr is what ExportBookings fails with after the bookings
+	exportErr error
+}
+
+func (s *fakeStore) GetUserDataByID(_ context.Context, id int) (model.User, db.HttpCode, error) {
+	for _, user := range s.users {
+		if user.Id == id {
+			return user, 200, nil
+		}
+	}
+	return model.User{}, http.StatusNotFound, db.ErrNotFound
+}
+
+func (s *fakeStore) GetResourceByID(_ context.Context, id int) (model.Resource, db.HttpCode, error) {
+	resource, ok := s.resources[id]
+	if !ok {
+		return model.Resource{}, http.StatusNotFound, db.ErrNotFound
+	}
+	return resource, 200, nil
+}
+
+func (s *fakeStore) GetResources(context.Context) ([]model.Resource, db.HttpCode, error) {
+	resources := make([]model.Resource, 0, len(s.resources))
+	for _, resource := range s.resources {
+		resources = append(resources, resource)
+	}
+	slices.SortFunc(resources, func(a, b model.Resource) int { return a.Id - b.Id })
+	return resources, 200, nil
+}
+
+func (s *fakeStore) GetResourceBookings(_ context.Context, id int) ([]model.Booking, db.HttpCode, error) {
+	var bookings []model.Booking
+	for _, booking := range s.bookings {
+		if booking.Resource_id != nil && *booking.Resource_id == id {
+			bookings = append(bookings, booking)
+		}
+	}
+	return bookings, 200, nil
+}
+
+// between returns the bookings overlapping [from, to) that take is true for.
+func (s *fakeStore) between(from, to time.Time, take func(model.Booking) bool) []model.Booking {
+	var bookings []model.Booking
+	for _, booking := range s.bookings {
+		if take(booking) && booking.Start_time.Before(to) && booking.End_time.After(from) {
+			bookings = append(bookings, booking)
+		}
+	}
+	return bookings
+}
+
+func (s *fakeStore) GetUserBookingsBetween(_ context.Context, id int, from, to time.Time) ([]model.Booking, db.HttpCode, error) {
+	return s.between(from, to, func(b model.Booking) bool { return b.User_id == id }), 200, nil
+}
+
+func (s *fakeStore) GetResourceBookingsBetween(_ context.Context, id int, from, to time.Time) ([]model.Booking, db.HttpCode, error) {
+	return s.between(from, to, func(b model.Booking) bool { return b.Resource_id != nil && *b.Resource_id == id }), 200, nil
+}
+
+func (s *fakeStore) AddNewBookings(ctx context.Context, bookings []model.Booking) ([]model.Booking, db.HttpCode, error) {
+	added := make([]model.Booking, 0, len(bookings))
+	for _, booking := range bookings {
+		booking.Id, _, _ = s.AddNewBooking(ctx, booking)
+		added = append(added, booking)
+	}
+	return added, 200, nil
+}
+
+func (s *fakeStore) AddNewBooking(_ context.Context, booking model.Booking) (int, db.HttpCode, error) {
+	if s.bookings == nil {
+		s.bookings = map[int]model.Booking{}
+	}
+	booking.Id = len(s.bookings) + 1
+	s.bookings[booking.Id] = booking
+	return booking.Id, 200, nil
+}
+
+func (s *fakeStore) GetBookingDataByID(ctx context.Context, id int) (model.Booking, db.HttpCode, error) {
+	return s.GetBookingDataByIDWithDeleted(ctx, id)
+}
+
+func (s *fakeStore) RestoreBooking(_ context.Context, id int) (model.Booking, db.HttpCode, error) {
+	if s.restoreErr != nil {
+		return model.Booking{}, http.StatusConflict, s.restoreErr
+	}
+	return s.bookings[id], 200, nil
+}
+
+func (s *fakeStore) VerifyUserPassword(_ context.Context, username, password string, userID *int) (model.User, bool, db.HttpCode, error) {
+	user, ok := s.users[username]
+	if !ok || user.Password != password || userID != nil && user.Id != *userID {
+		return model.User{}, false, 200, nil
+	}
+	return user, true, 200, nil
+}
+
+func (s *fakeStore) GetBookingDataByIDWithDeleted(_ context.Context, id int) (model.Booking, db.HttpCode, error) {
+	b, ok := s.bookings[id]
+	if !ok {
+		return model.Booking{}, http.StatusNotFound, db.ErrNotFound
+	}
+	return b, 200, nil
+}
+
+func (s *fakeStore) GetEntityHistory(context.Context, string, int) ([]model.AuditEntry, db.HttpCode, error) {
+	return append([]model.AuditEntry(nil), s.history...), 200, nil
+}
+
+func (s *fakeStore) GetOutboxAfter(_ context.Context, afterID int64, limit int) ([]model.OutboxMessage, db.HttpCode, error) {
+	var messages []model.OutboxMessage
+	for _, msg := range s.outbox {
+		if msg.Id > afterID && len(messages) < limit {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, 200, nil
+}
+
+func (s *fakeStore) ExportBookings(_ context.Context, _ db.ExportFilter, row func(model.Booking) error) (db.HttpCode, error) {
+	ids := make([]int, 0, len(s.bookings))
+	for id := range s.bookings {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		if err := row(s.bookings[id]); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	if s.exportErr != nil {
+		return http.StatusInternalServerError, s.exportErr
+	}
+	return 200, nil
+}
+
+// serve runs handler for a request to path with the principal p, nil for an anonymous request.
+func serve(t *testing.T, pattern string, handler gin.HandlerFunc, req *http.Request, p *auth.Principal) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(req.Method, pattern, func(c *gin.Context) {
+		if p != nil {
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+		}
+	}, handler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,291 @@
+package route
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/subliker/backendproj/auth"
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/events"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/logging"
+	"github.com/subliker/backendproj/stream"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// replayPage is how many missed events are read at once when a client resumes.
+const replayPage = 500
+
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 4096}
+
+// streamResources are the resources a stream can be filtered by.
+var streamResources = []string{events.AggregateBooking, events.AggregateUser}
+
+// streamMessage is a WebSocket message.
+type streamMessage struct {
+	// ID is the event to resume after with last_event_id
+	ID    int64  `json:"id" example:"4711"`
+	Event string `json:"event" example:"booking.updated"`
+	// Data is the event as webhooks get it
+	Data any `json:"data" swaggertype:"object"`
+}
+
+// StreamBookings godoc
+//
+//	@Summary		Stream booking changes
+//	@Description	Server-Sent Events of booking (and optionally user) changes: "id" is the event id, "event" its type (booking.created, booking.updated, booking.deleted, ...), "data" the event as webhooks get it.
+//	@Description	The same stream is served over WebSocket when the request is a WebSocket upgrade, every message is {"id", "event", "data"}.
+//	@Description	A client resumes after the Last-Event-ID header or the last_event_id parameter, events of the last 7 days (outbox.retention) are replayed.
+//	@Description	Events committed late may have ids below ones already sent, so a resumed stream repeats the events of up to 1000 ids before the one resumed after: clients de-duplicate them by the event id in "data".
+//	@Tags			booking
+//	@Produce		text/event-stream
+//	@Param        resource    query     string  false  "comma separated resources: booking (default), user (requires users:read)"
+//	@Param        user_id    query     int  false  "only events of this user and their bookings"
+//	@Param        resource_id    query     int  false  "only events of bookings taking this resource"
+//	@Param        last_event_id    query     int  false  "resume after this event (or the Last-Event-ID header)"
+//	@Success		200				{object}	streamMessage
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Router			/booking/stream [get]
+func (h *Handler) StreamBookings(c *gin.Context) {
+	filter := stream.Filter{Resources: []string{events.AggregateBooking}}
+	if resource := c.Query("resource"); resource != "" {
+		filter.Resources = nil
+		for _, r := range strings.Split(resource, ",") {
+			r = strings.TrimSpace(r)
+			if !slices.Contains(streamResources, r) {
+				dv.ResErr(c, http.StatusBadRequest, dv.NewError("resource_incorrect", i18n.Args{"resource": r}))
+				return
+			}
+			filter.Resources = append(filter.Resources, r)
+		}
+	}
+	// user events carry the user, the route only requires bookings:read
+	if slices.Contains(filter.Resources, events.AggregateUser) && !canReadUsers(c.Request.Context()) {
+		dv.ResErr(c, http.StatusForbidden, dv.NewError("scope_missing", i18n.Args{"scope": auth.ScopeUsersRead}))
+		return
+	}
+	if user_id := c.Query("user_id"); user_id != "" {
+		user_idI, err := strconv.Atoi(user_id)
+		if err != nil {
+			dv.ResMessageCode(c, http.StatusBadRequest, "user_id_incorrect")
+			return
+		}
+		filter.UserID = &user_idI
+	}
+	if resource_id := c.Query("resource_id"); resource_id != "" {
+		resource_idI, err := strconv.Atoi(resource_id)
+		if err != nil {
+			dv.ResMessageCode(c, http.StatusBadRequest, "resource_id_incorrect")
+			return
+		}
+		filter.ResourceID = &resource_idI
+	}
+	// a key restricted to one user streams only that user's events, to a resource only the events of its bookings
+	if p := auth.FromContext(c.Request.Context()); p != nil {
+		if p.UserID != nil {
+			if filter.UserID != nil && *filter.UserID != *p.UserID {
+				dv.ResMessageCode(c, http.StatusForbidden, "access_denied")
+				return
+			}
+			filter.UserID = p.UserID
+		}
+		if p.ResourceID != nil {
+			if filter.ResourceID != nil && *filter.ResourceID != *p.ResourceID {
+				dv.ResMessageCode(c, http.StatusForbidden, "access_denied")
+				return
+			}
+			filter.ResourceID = p.ResourceID
+		}
+	}
+
+	var last int64
+	if id := c.GetHeader("Last-Event-ID"); id != "" || c.Query("last_event_id") != "" {
+		if id == "" {
+			id = c.Query("last_event_id")
+		}
+		var err error
+		if last, err = strconv.ParseInt(id, 10, 64); err != nil || last < 0 {
+			dv.ResMessageCode(c, http.StatusBadRequest, "last_event_id_incorrect")
+			return
+		}
+	}
+
+	// subscribing before the replay leaves no gap between them
+	sub := h.Stream.Subscribe(filter)
+	defer sub.Close()
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		h.streamWebSocket(c, sub, filter, last)
+	} else {
+		h.streamSSE(c, sub, filter, last)
+	}
+}
+
+// canReadUsers reports whether the request may get user events, like auth.Require(auth.ScopeUsersRead) lets it through.
+func canReadUsers(ctx context.Context) bool {
+	p := auth.FromContext(ctx)
+	return p == nil || p.Has(auth.ScopeUsersRead)
+}
+
+// replay calls send for the events not seen yet that match filter, from ResumeWindow below the latest one seen.
+// User events are only replayed to requests that may read users.
+func (h *Handler) replay(ctx context.Context, filter stream.Filter, seen *stream.Seen, send func(stream.Event) error) error {
+	users := canReadUsers(ctx)
+	after := seen.From()
+	for {
+		messages, _, err := h.Store.GetOutboxAfter(ctx, after, replayPage)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			after = msg.Id
+			if !seen.Add(msg.Id) {
+				continue
+			}
+			e := stream.FromOutbox(msg)
+			if e.Resource == events.AggregateUser && !users {
+				continue
+			}
+			if filter.Match(e) {
+				if err := send(e); err != nil {
+					return err
+				}
+			}
+		}
+		if len(messages) < replayPage {
+			return nil
+		}
+	}
+}
+
+func (h *Handler) streamSSE(c *gin.Context, sub *stream.Subscription, filter stream.Filter, last int64) {
+	ctx := c.Request.Context()
+	// the stream outlives http.write_timeout
+	rc := http.NewResponseController(c.Writer)
+	rc.SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", 3000)
+
+	send := func(e stream.Event) error {
+		_, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+		return err
+	}
+
+	seen := stream.NewSeen(last)
+	if last > 0 {
+		if err := h.replay(ctx, filter, seen, send); err != nil {
+			logging.FromContext(ctx).Warn("replay stream", "err", err)
+			return
+		}
+	}
+	rc.Flush()
+
+	heartbeat := time.NewTicker(h.StreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					logging.FromContext(ctx).Info("stream subscriber lagged behind, closing")
+				}
+				return
+			}
+			if !seen.Add(e.ID) {
+				// replayed already
+				continue
+			}
+			if send(e) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (h *Handler) streamWebSocket(c *gin.Context, sub *stream.Subscription, filter stream.Filter, last int64) {
+	ctx := c.Request.Context()
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has answered already
+		return
+	}
+	defer conn.Close()
+
+	// the client only sends pongs and the close message
+	closed := make(chan struct{})
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * h.StreamHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * h.StreamHeartbeat))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(e stream.Event) error {
+		conn.SetWriteDeadline(time.Now().Add(h.StreamHeartbeat))
+		return conn.WriteJSON(streamMessage{ID: e.ID, Event: e.Type, Data: e.Data})
+	}
+	seen := stream.NewSeen(last)
+	if last > 0 {
+		if err := h.replay(ctx, filter, seen, send); err != nil {
+			logging.FromContext(ctx).Warn("replay stream", "err", err)
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.StreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				reason := "server is shutting down"
+				if sub.Lagged() {
+					reason = "too slow, resume with last_event_id"
+				}
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, reason), time.Now().Add(time.Second))
+				return
+			}
+			if !seen.Add(e.ID) {
+				continue
+			}
+			if send(e) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.StreamHeartbeat)) != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package route
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/subliker/backendproj/auth"
+	"github.com/subliker/backendproj/events"
+	"github.com/subliker/backendproj/model"
+	"github.com/subliker/backendproj/stream"
+)
+
+func TestStreamBookingsUserEventsNeedUsersRead(t *testing.T) {
+	h := NewHandler(&fakeStore{})
+	bookingsReader := &auth.Principal{KeyID: 1, Scopes: []string{auth.ScopeBookingsRead}}
+	tests := []struct {
+		name     string
+		resource string
+		p        *auth.Principal
+		status   int
+	}{
+		{"user events without users:read", "user", bookingsReader, http.StatusForbidden},
+		{"booking and user events without users:read", "booking,user", bookingsReader, http.StatusForbidden},
+		{"unknown resource", "room", bookingsReader, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/booking/stream?resource="+tt.resource, nil)
+			w := serve(t, "/booking/stream", h.StreamBookings, req, tt.p)
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestReplaySkipsUserEventsWithoutUsersRead(t *testing.T) {
+	store := &fakeStore{outbox: []model.OutboxMessage{
+		{Id: 1, Aggregate_type: events.AggregateUser, Aggregate_id: "5", Event_type: events.UserCreated, Payload: json.RawMessage(`{"data":{"id":5}}`)},
+		{Id: 2, Aggregate_type: events.AggregateBooking, Aggregate_id: "9", Event_type: events.BookingCreated, Payload: json.RawMessage(`{"data":{"id":9,"user_id":5}}`)},
+		{Id: 3, Aggregate_type: events.AggregateUser, Aggregate_id: "5", Event_type: events.UserUpdated, Payload: json.RawMessage(`{"data":{"id":5}}`)},
+	}}
+	h := NewHandler(store)
+	filter := stream.Filter{Resources: []string{events.AggregateBooking, events.AggregateUser}}
+	tests := []struct {
+		name string
+		p    *auth.Principal
+		want []int64
+	}{
+		{"bookings reader", &auth.Principal{KeyID: 1, Scopes: []string{auth.ScopeBookingsRead}}, []int64{2}},
+		{"users reader", &auth.Principal{KeyID: 2, Scopes: []string{auth.ScopeBookingsRead, auth.ScopeUsersRead}}, []int64{1, 2, 3}},
+		{"anonymous", nil, []int64{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.p != nil {
+				ctx = auth.WithPrincipal(ctx, tt.p)
+			}
+			var sent []int64
+			seen := stream.NewSeen(0)
+			err := h.replay(ctx, filter, seen, func(e stream.Event) error {
+				sent = append(sent, e.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(sent, tt.want) {
+				t.Errorf("replayed %v, want %v", sent, tt.want)
+			}
+			// skipped events are passed, the live stream doesn't send them again
+			if seen.Add(3) {
+				t.Errorf("event 3 isn't seen after the replay")
+			}
+		})
+	}
+}
+
+func TestStreamBookingsResourceID(t *testing.T) {
+	h := NewHandler(&fakeStore{})
+	req := httptest.NewRequest(http.MethodGet, "/booking/stream?resource_id=room", nil)
+	w := serve(t, "/booking/stream", h.StreamBookings, req, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want 400: %s", w.Code, w.Body)
+	}
+	room := 3
+	p := &auth.Principal{KeyID: 1, Scopes: []string{auth.ScopeBookingsRead}, ResourceID: &room}
+	req = httptest.NewRequest(http.MethodGet, "/booking/stream?resource_id=4", nil)
+	if w := serve(t, "/booking/stream", h.StreamBookings, req, p); w.Code != http.StatusForbidden {
+		t.Errorf("key of resource 3 streaming resource 4: status %d, want 403: %s", w.Code, w.Body)
+	}
+
+	store := &fakeStore{outbox: []model.OutboxMessage{
+		{Id: 1, Aggregate_type: events.AggregateBooking, Aggregate_id: "9", Event_type: events.BookingCreated, Payload: json.RawMessage(`{"data":{"id":9,"user_id":5,"resource_id":3}}`)},
+		{Id: 2, Aggregate_type: events.AggregateBooking, Aggregate_id: "10", Event_type: events.BookingCreated, Payload: json.RawMessage(`{"data":{"id":10,"user_id":5}}`)},
+		{Id: 3, Aggregate_type: events.AggregateBooking, Aggregate_id: "9", Event_type: events.BookingDeleted, Payload: json.RawMessage(`{"data":{"id":9,"user_id":5,"resource_id":3}}`)},
+	}}
+	resource := 3
+	var sent []int64
+	err := NewHandler(store).replay(context.Background(), stream.Filter{ResourceID: &resource}, stream.NewSeen(0), func(e stream.Event) error {
+		sent = append(sent, e.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(sent, []int64{1, 3}) {
+		t.Errorf("replayed %v, want the events of resource 3", sent)
+	}
+}
+
+func TestReplayRescansBelowLast(t *testing.T) {
+	booking := func(id int64) model.OutboxMessage {
+		return model.OutboxMessage{Id: id, Aggregate_type: events.AggregateBooking, Aggregate_id: "9", Event_type: events.BookingUpdated, Payload: json.RawMessage(`{"data":{"id":9,"user_id":5}}`)}
+	}
+	// 1500 committed after 1600 and 1700, the client has got 1700 and resumes after it
+	store := &fakeStore{outbox: []model.OutboxMessage{booking(100), booking(1500), booking(1600), booking(1700), booking(1800)}}
+	seen := stream.NewSeen(1700)
+	var sent []int64
+	err := NewHandler(store).replay(context.Background(), stream.Filter{}, seen, func(e stream.Event) error {
+		sent = append(sent, e.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1500, 1600, 1700, 1800}; !slices.Equal(sent, want) {
+		t.Errorf("replayed %v, want %v: the events within the window before 1700 and the ones after it", sent, want)
+	}
+	// the live stream skips what the replay sent, but not an event committed even later below it
+	for id, want := range map[int64]bool{1600: false, 1800: false, 1650: true, 1900: true} {
+		if got := seen.Add(id); got != want {
+			t.Errorf("live event %d sent %v, want %v", id, got, want)
+		}
+	}
+}
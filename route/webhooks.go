@@ -0,0 +1,387 @@
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	dv "github.com/subliker/backendproj/datavalidator"
+	"github.com/subliker/backendproj/events"
+	"github.com/subliker/backendproj/i18n"
+	"github.com/subliker/backendproj/model"
+	"github.com/subliker/backendproj/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewWebhook is a webhook with its secret, which is shown only once.
+type NewWebhook struct {
+	model.Webhook
+	Secret string `json:"secret" example:"whsec_9b1d4c2a8e7f6b5a4c3d2e1f5f0c6a3e9b1d4c2a8e7f6b5a4c3d2e1f5f0c6a3e"`
+}
+
+// webhookSecretMinLength is the shortest secret a client may choose.
+const webhookSecretMinLength = 16
+
+// CreateWebhook godoc
+//
+//	@Summary		Subscribe a URL to events
+//	@Description	Events are POSTed to the URL as JSON signed with the secret, see the README. The secret is returned only in this response. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Param   url   formData   string     true        "http or https URL"
+//	@Param   events   formData   string     true        "comma separated events: booking.created, booking.updated, booking.deleted, booking.restored, user.created, user.updated, user.deleted, user.restored"
+//	@Param   secret   formData   string     false        "signing secret (at least 16 characters), generated if not set"
+//	@Param   active   formData   bool     false        "send events to the webhook (default true)"
+//	@Success		200				{object}	NewWebhook
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks [post]
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var hook model.Webhook
+	var err error
+
+	if hook.Url, err = webhookURL(c.PostForm("url")); err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	if hook.Events, err = webhookEvents(c.PostForm("events")); err != nil {
+		dv.ResErr(c, http.StatusBadRequest, err)
+		return
+	}
+	hook.Active = true
+	if active := c.PostForm("active"); active != "" {
+		if hook.Active, err = strconv.ParseBool(active); err != nil {
+			dv.ResMessageCode(c, http.StatusBadRequest, "active_incorrect")
+			return
+		}
+	}
+
+	hook.Secret = c.PostForm("secret")
+	if hook.Secret == "" {
+		if hook.Secret, err = webhook.GenerateSecret(); err != nil {
+			dv.ResErr(c, http.StatusInternalServerError, err)
+			return
+		}
+	} else if len(hook.Secret) < webhookSecretMinLength {
+		dv.ResErr(c, http.StatusBadRequest, dv.NewError("webhook_secret_too_short", i18n.Args{"min": webhookSecretMinLength}))
+		return
+	}
+
+	hook, httpCode, err := h.Store.AddWebhook(c.Request.Context(), hook)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	hookData, e := json.Marshal(NewWebhook{Webhook: hook.In(time.UTC), Secret: hook.Secret})
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", hookData)
+}
+
+// GetWebhooks godoc
+//
+//	@Summary		List webhooks
+//	@Description	Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200				{array}		model.Webhook
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks [get]
+func (h *Handler) GetWebhooks(c *gin.Context) {
+	hooks, httpCode, err := h.Store.GetWebhooks(c.Request.Context())
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	for i := range hooks {
+		hooks[i] = hooks[i].In(time.UTC)
+	}
+	hooksData, e := json.Marshal(hooks)
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", hooksData)
+}
+
+// GetWebhook godoc
+//
+//	@Summary		Return a webhook
+//	@Description	Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Param id path int required "webhook id"
+//	@Success		200				{object}	model.Webhook
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/{id} [get]
+func (h *Handler) GetWebhook(c *gin.Context) {
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	hook, httpCode, err := h.Store.GetWebhookByID(c.Request.Context(), idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	hookData, e := json.Marshal(hook.In(time.UTC))
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", hookData)
+}
+
+// UpdateWebhook godoc
+//
+//	@Summary		Update a webhook
+//	@Description	(option) update url, events, active. The secret can't be changed, create a new webhook instead. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Param id path int required "webhook id"
+//	@Param   url   formData   string     false        "http or https URL"
+//	@Param   events   formData   string     false        "comma separated events"
+//	@Param   active   formData   bool     false        "send events to the webhook"
+//	@Success		200				{object}	model.Webhook
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/{id} [put]
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	hook, httpCode, err := h.Store.GetWebhookByID(c.Request.Context(), idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+
+	if u := c.PostForm("url"); u != "" {
+		if hook.Url, err = webhookURL(u); err != nil {
+			dv.ResErr(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if list := c.PostForm("events"); list != "" {
+		if hook.Events, err = webhookEvents(list); err != nil {
+			dv.ResErr(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if active := c.PostForm("active"); active != "" {
+		if hook.Active, err = strconv.ParseBool(active); err != nil {
+			dv.ResMessageCode(c, http.StatusBadRequest, "active_incorrect")
+			return
+		}
+	}
+
+	hook, httpCode, err = h.Store.UpdateWebhook(c.Request.Context(), hook)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	hookData, e := json.Marshal(hook.In(time.UTC))
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", hookData)
+}
+
+// DeleteWebhook godoc
+//
+//	@Summary		Delete a webhook
+//	@Description	Deletes the webhook with its deliveries, pending ones aren't sent. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Param id path int required "webhook id"
+//	@Success		200				{object}	dv.ResMesOK
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/{id} [delete]
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	httpCode, err := h.Store.DeleteWebhook(c.Request.Context(), idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	dv.ResMessageCode(c, http.StatusOK, "webhook_deleted")
+}
+
+// GetWebhookDeliveries godoc
+//
+//	@Summary		List deliveries of a webhook
+//	@Description	Newest first. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Param id path int required "webhook id"
+//	@Param        status    query     string  false  "pending, delivered or dead"
+//	@Param        limit    query     int  false  "limit (default 50, at most 500)"
+//	@Success		200				{array}		model.WebhookDelivery
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/{id}/deliveries [get]
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	idI, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	if _, httpCode, err := h.Store.GetWebhookByID(c.Request.Context(), idI); err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	h.deliveries(c, idI)
+}
+
+// GetDeliveries godoc
+//
+//	@Summary		List deliveries of all webhooks
+//	@Description	Newest first, status=dead lists the dead letters: deliveries that ran out of attempts and wait for a redelivery. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Param        status    query     string  false  "pending, delivered or dead"
+//	@Param        limit    query     int  false  "limit (default 50, at most 500)"
+//	@Success		200				{array}		model.WebhookDelivery
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/deliveries [get]
+func (h *Handler) GetDeliveries(c *gin.Context) {
+	h.deliveries(c, 0)
+}
+
+// deliveries answers with the deliveries of webhookID, 0 means every webhook.
+func (h *Handler) deliveries(c *gin.Context, webhookID int) {
+	status := c.Query("status")
+	if status != "" && status != model.DeliveryPending && status != model.DeliveryDelivered && status != model.DeliveryDead {
+		dv.ResMessageCode(c, http.StatusBadRequest, "delivery_status_incorrect")
+		return
+	}
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 || limit > 500 {
+			dv.ResErr(c, http.StatusBadRequest, dv.NewError("query_param_incorrect", i18n.Args{"name": "limit"}))
+			return
+		}
+	}
+
+	deliveries, httpCode, err := h.Store.GetWebhookDeliveries(c.Request.Context(), webhookID, status, limit)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	for i := range deliveries {
+		deliveries[i] = deliveries[i].In(time.UTC)
+	}
+	deliveriesData, e := json.Marshal(deliveries)
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", deliveriesData)
+}
+
+// RedeliverWebhookDelivery godoc
+//
+//	@Summary		Send a delivery again
+//	@Description	Makes a delivered or dead delivery pending with all its attempts, it's sent within a few seconds. The body and event id are the same, the signature is new. Requires the admin scope.
+//	@Tags			admin
+//	@Produce		json
+//	@Param id path int required "delivery id"
+//	@Success		200				{object}	model.WebhookDelivery
+//	@Failure		400				{object}	dv.ResError
+//	@Failure		404				{object}	dv.Problem
+//	@Failure		401				{object}	dv.ResError
+//	@Failure		403				{object}	dv.ResError
+//	@Failure		500				{object}	dv.ResError
+//	@Security		ApiKeyAuth
+//	@Router			/admin/deliveries/{id}/redeliver [post]
+func (h *Handler) RedeliverWebhookDelivery(c *gin.Context) {
+	idI, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		dv.ResMessageCode(c, http.StatusBadRequest, "id_incorrect")
+		return
+	}
+	delivery, httpCode, err := h.Store.RedeliverWebhookDelivery(c.Request.Context(), idI)
+	if err != nil {
+		dv.ResErr(c, int(httpCode), err)
+		return
+	}
+	deliveryData, e := json.Marshal(delivery.In(time.UTC))
+	if e != nil {
+		dv.ResErr(c, http.StatusInternalServerError, e)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", deliveryData)
+}
+
+// webhookURL checks that s is an absolute http(s) URL.
+func webhookURL(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(s) > 2048 {
+		return "", dv.NewError("webhook_url_incorrect", nil)
+	}
+	return s, nil
+}
+
+// webhookEvents parses a comma separated list of event types.
+func webhookEvents(s string) ([]string, error) {
+	var types []string
+	for _, event := range strings.Split(s, ",") {
+		event = strings.TrimSpace(event)
+		if event == "" || slices.Contains(types, event) {
+			continue
+		}
+		if !slices.Contains(events.Types, event) {
+			return nil, dv.NewError("event_incorrect", i18n.Args{"event": event})
+		}
+		types = append(types, event)
+	}
+	if len(types) == 0 {
+		return nil, dv.NewError("events_not_set", nil)
+	}
+	return types, nil
+}
@@ -0,0 +1,166 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/subliker/backendproj/events"
+	"github.com/subliker/backendproj/metrics"
+	"github.com/subliker/backendproj/model"
+)
+
+// Event is a change of a user or booking sent to the subscribers.
+type Event struct {
+	// ID is the outbox id of the event, clients resume after it with Last-Event-ID
+	ID   int64
+	Type string
+	// Resource is user or booking
+	Resource string
+	// UserID is the user the event is about, or the user of the booking
+	UserID int
+	// ResourceID is the resource the booking takes, nil for users and bookings without one
+	ResourceID *int
+	// Data is the event as JSON, the same as webhooks get
+	Data json.RawMessage
+}
+
+// FromOutbox returns the event of an outbox message.
+func FromOutbox(msg model.OutboxMessage) Event {
+	e := Event{ID: msg.Id, Type: msg.Event_type, Resource: msg.Aggregate_type, Data: msg.Payload}
+	if msg.Aggregate_type == events.AggregateUser {
+		e.UserID, _ = strconv.Atoi(msg.Aggregate_id)
+	} else {
+		var payload struct {
+			Data struct {
+				User_id     int  `json:"user_id"`
+				Resource_id *int `json:"resource_id"`
+			} `json:"data"`
+		}
+		json.Unmarshal(msg.Payload, &payload)
+		e.UserID, e.ResourceID = payload.Data.User_id, payload.Data.Resource_id
+	}
+	return e
+}
+
+// Filter selects the events a subscriber gets, empty fields select everything.
+type Filter struct {
+	Resources []string
+	UserID    *int
+	// ResourceID selects the bookings taking this resource, user events don't match it
+	ResourceID *int
+}
+
+func (f Filter) Match(e Event) bool {
+	return (len(f.Resources) == 0 || slices.Contains(f.Resources, e.Resource)) &&
+		(f.UserID == nil || *f.UserID == e.UserID) &&
+		(f.ResourceID == nil || e.ResourceID != nil && *f.ResourceID == *e.ResourceID)
+}
+
+// Subscription receives the events matching its filter on C.
+// C is closed when the broker is closed, or when the subscriber is too slow
+// to keep up, then Lagged reports true and the client should resume from the last event it got.
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	filter Filter
+	lagged bool
+	broker *Broker
+}
+
+// Lagged reports whether C was closed because the subscriber fell behind.
+func (s *Subscription) Lagged() bool {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.lagged
+}
+
+// Close unsubscribes, it's safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// Broker fans events out to the subscribers of this instance.
+// It's fed by Listener from PostgreSQL notifications, so every instance gets every event,
+// or as an outbox sink, which is enough for a single instance.
+type Broker struct {
+	// Buffer is how many events a subscriber may fall behind before it's dropped
+	Buffer int
+
+	mu     sync.Mutex
+	subs   map[*Subscription]bool
+	closed bool
+}
+
+func NewBroker(buffer int) *Broker {
+	return &Broker{Buffer: buffer, subs: map[*Subscription]bool{}}
+}
+
+// Subscribe returns a subscription to the events matching f.
+// After Close, the returned subscription's C is closed at once.
+func (b *Broker) Subscribe(f Filter) *Subscription {
+	c := make(chan Event, b.Buffer)
+	s := &Subscription{C: c, c: c, filter: f, broker: b}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(c)
+		return s
+	}
+	b.subs[s] = true
+	metrics.StreamSubscribers.Inc()
+	return s
+}
+
+// Send gives e to every subscriber it matches.
+func (b *Broker) Send(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			s.lagged = true
+			b.remove(s)
+		}
+	}
+}
+
+// remove closes the channel of s, b.mu must be held.
+func (b *Broker) remove(s *Subscription) {
+	if !b.subs[s] {
+		return
+	}
+	delete(b.subs, s)
+	close(s.c)
+	metrics.StreamSubscribers.Dec()
+}
+
+// Close ends every subscription, later ones end at once. It's called on shutdown,
+// so open streams don't keep the server from stopping.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.remove(s)
+	}
+}
+
+// Name is the sink name of the broker in the outbox relay.
+func (b *Broker) Name() string {
+	return "stream"
+}
+
+// Publish sends the outbox message to the subscribers, so the broker can be an outbox sink.
+func (b *Broker) Publish(ctx context.Context, msg model.OutboxMessage) error {
+	b.Send(FromOutbox(msg))
+	return nil
+}
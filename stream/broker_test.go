@@ -0,0 +1,42 @@
+package stream
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/subliker/backendproj/events"
+	"github.com/subliker/backendproj/model"
+)
+
+func TestFilterMatch(t *testing.T) {
+	booking := FromOutbox(model.OutboxMessage{Id: 1, Aggregate_type: events.AggregateBooking, Aggregate_id: "9",
+		Event_type: events.BookingCreated, Payload: json.RawMessage(`{"data":{"id":9,"user_id":5,"resource_id":3}}`)})
+	unplaced := FromOutbox(model.OutboxMessage{Id: 2, Aggregate_type: events.AggregateBooking, Aggregate_id: "10",
+		Event_type: events.BookingCreated, Payload: json.RawMessage(`{"data":{"id":10,"user_id":5}}`)})
+	user := FromOutbox(model.OutboxMessage{Id: 3, Aggregate_type: events.AggregateUser, Aggregate_id: "5",
+		Event_type: events.UserUpdated, Payload: json.RawMessage(`{"data":{"id":5}}`)})
+	five, six, three, four := 5, 6, 3, 4
+	tests := []struct {
+		name   string
+		filter Filter
+		// want are the matching events of booking, unplaced and user
+		want [3]bool
+	}{
+		{"everything", Filter{}, [3]bool{true, true, true}},
+		{"bookings", Filter{Resources: []string{events.AggregateBooking}}, [3]bool{true, true, false}},
+		{"user", Filter{UserID: &five}, [3]bool{true, true, true}},
+		{"another user", Filter{UserID: &six}, [3]bool{false, false, false}},
+		{"resource", Filter{ResourceID: &three}, [3]bool{true, false, false}},
+		{"another resource", Filter{ResourceID: &four}, [3]bool{false, false, false}},
+		{"user and resource", Filter{Resources: []string{events.AggregateBooking, events.AggregateUser}, UserID: &five, ResourceID: &three}, [3]bool{true, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, e := range []Event{booking, unplaced, user} {
+				if got := tt.filter.Match(e); got != tt.want[i] {
+					t.Errorf("Match(event %d) = %v, want %v", e.ID, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
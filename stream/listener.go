@@ -0,0 +1,97 @@
+package stream
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/model"
+
+	"github.com/lib/pq"
+)
+
+// Store is the storage of the outbox, *db.DataBase in production.
+type Store interface {
+	GetOutboxByIDs(ctx context.Context, ids []int64) ([]model.OutboxMessage, error)
+	GetOutboxAfter(ctx context.Context, afterID int64, limit int) ([]model.OutboxMessage, db.HttpCode, error)
+}
+
+// Listener feeds a broker with the outbox messages committed by any instance,
+// which PostgreSQL announces on db.OutboxChannel.
+type Listener struct {
+	DSN    string
+	Store  Store
+	Broker *Broker
+}
+
+func NewListener(dsn string, store Store, broker *Broker) *Listener {
+	return &Listener{DSN: dsn, Store: store, Broker: broker}
+}
+
+// catchUpLimit is how many missed messages are sent after the connection is restored.
+const catchUpLimit = 1000
+
+// Run listens until ctx is done. After the connection is lost and restored,
+// messages committed in between are sent too, as far as catchUpLimit allows,
+// including the ones within ResumeWindow below the latest message sent.
+func (l *Listener) Run(ctx context.Context) {
+	listener := pq.NewListener(l.DSN, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.WarnContext(ctx, "outbox listener", "event", ev, "err", err)
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(db.OutboxChannel); err != nil {
+		// retried by pq when the connection is restored
+		slog.WarnContext(ctx, "listen to outbox notifications", "err", err)
+	}
+
+	seen := NewSeen(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			if n == nil {
+				// the connection was restored, notifications sent meanwhile are lost
+				l.catchUp(ctx, seen)
+				continue
+			}
+			id, err := strconv.ParseInt(n.Extra, 10, 64)
+			if err != nil {
+				continue
+			}
+			messages, err := l.Store.GetOutboxByIDs(ctx, []int64{id})
+			if err != nil {
+				slog.WarnContext(ctx, "get outbox message", "outbox_id", id, "err", err)
+				continue
+			}
+			for _, msg := range messages {
+				if seen.Add(msg.Id) {
+					l.Broker.Send(FromOutbox(msg))
+				}
+			}
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
+
+// catchUp sends the messages committed since the latest one seen, which may be below it.
+func (l *Listener) catchUp(ctx context.Context, seen *Seen) {
+	if seen.Last() == 0 {
+		return
+	}
+	messages, _, err := l.Store.GetOutboxAfter(ctx, seen.From(), ResumeWindow+catchUpLimit)
+	if err != nil {
+		slog.WarnContext(ctx, "get missed outbox messages", "err", err)
+		return
+	}
+	for _, msg := range messages {
+		if seen.Add(msg.Id) {
+			l.Broker.Send(FromOutbox(msg))
+		}
+	}
+}
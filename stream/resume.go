@@ -0,0 +1,51 @@
+package stream
+
+// ResumeWindow is how many outbox ids below the latest one seen are scanned again when resuming.
+// Ids are taken when a message is inserted, not when it's committed: a transaction committing
+// after a later one adds messages below ids already seen.
+const ResumeWindow = 1000
+
+// Seen remembers the events sent within ResumeWindow of the latest one, so that re-scanned
+// and late events are sent once. Events further below are taken as sent.
+type Seen struct {
+	last int64
+	ids  map[int64]struct{}
+}
+
+// NewSeen returns a Seen resuming after last: events within ResumeWindow below it are sent again,
+// the client doesn't tell which of them it has got.
+func NewSeen(last int64) *Seen {
+	return &Seen{last: last, ids: make(map[int64]struct{})}
+}
+
+// Last returns the latest id seen.
+func (s *Seen) Last() int64 {
+	return s.last
+}
+
+// From returns the id to scan the outbox after.
+func (s *Seen) From() int64 {
+	return max(s.last-ResumeWindow, 0)
+}
+
+// Add records id and reports whether it's new.
+func (s *Seen) Add(id int64) bool {
+	if id <= s.From() {
+		return false
+	}
+	if _, ok := s.ids[id]; ok {
+		return false
+	}
+	s.ids[id] = struct{}{}
+	if id > s.last {
+		s.last = id
+		if len(s.ids) > 2*ResumeWindow {
+			for old := range s.ids {
+				if old <= s.From() {
+					delete(s.ids, old)
+				}
+			}
+		}
+	}
+	return true
+}
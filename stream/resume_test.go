@@ -0,0 +1,85 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/events"
+	"github.com/subliker/backendproj/model"
+)
+
+func TestSeen(t *testing.T) {
+	seen := NewSeen(0)
+	for _, id := range []int64{5, 3, ResumeWindow + 10} {
+		if !seen.Add(id) {
+			t.Errorf("Add(%d) = false, want a new event", id)
+		}
+	}
+	if seen.Add(3) {
+		t.Errorf("Add(3) again = true, want it seen")
+	}
+	if seen.Last() != ResumeWindow+10 || seen.From() != 10 {
+		t.Errorf("Last() = %d, From() = %d, want %d and 10", seen.Last(), seen.From(), ResumeWindow+10)
+	}
+	// committed late within the window
+	if !seen.Add(11) {
+		t.Errorf("Add(11) = false, want an event within the window sent")
+	}
+	// below the window, taken as sent
+	if seen.Add(4) {
+		t.Errorf("Add(4) = true, want an event below the window skipped")
+	}
+
+	// many events keep only the window
+	for id := int64(ResumeWindow + 11); id <= 4*ResumeWindow; id++ {
+		seen.Add(id)
+	}
+	if len(seen.ids) > 2*ResumeWindow+1 {
+		t.Errorf("%d ids remembered, want at most %d", len(seen.ids), 2*ResumeWindow+1)
+	}
+	if seen.Add(4*ResumeWindow - 1) {
+		t.Errorf("an event within the window sent twice")
+	}
+}
+
+type fakeOutbox []model.OutboxMessage
+
+func (o fakeOutbox) GetOutboxByIDs(context.Context, []int64) ([]model.OutboxMessage, error) {
+	return nil, nil
+}
+
+func (o fakeOutbox) GetOutboxAfter(_ context.Context, afterID int64, limit int) ([]model.OutboxMessage, db.HttpCode, error) {
+	var messages []model.OutboxMessage
+	for _, msg := range o {
+		if msg.Id > afterID && len(messages) < limit {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, 200, nil
+}
+
+func TestCatchUp(t *testing.T) {
+	message := func(id int64) model.OutboxMessage {
+		return model.OutboxMessage{Id: id, Aggregate_type: events.AggregateUser, Aggregate_id: "5", Event_type: events.UserUpdated, Payload: json.RawMessage(`{"data":{"id":5}}`)}
+	}
+	broker := NewBroker(10)
+	sub := broker.Subscribe(Filter{})
+	defer sub.Close()
+	// 7 and 8 were sent before the connection was lost, 6 committed after them
+	l := NewListener("", fakeOutbox{message(6), message(7), message(8), message(9)}, broker)
+	seen := NewSeen(0)
+	seen.Add(7)
+	seen.Add(8)
+	l.catchUp(context.Background(), seen)
+
+	var sent []int64
+	for len(sub.C) > 0 {
+		sent = append(sent, (<-sub.C).ID)
+	}
+	if !slices.Equal(sent, []int64{6, 9}) {
+		t.Errorf("caught up with %v, want 6 and 9", sent)
+	}
+}
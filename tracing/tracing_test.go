@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/subliker/backendproj/config"
+	"github.com/subliker/backendproj/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spans := NewInMemory()
+	var logs bytes.Buffer
+	router := gin.New()
+	router.Use(Middleware(), logging.Middleware(logging.New(&logs, config.Log{Format: "json"})))
+	router.GET("/booking/:id", func(c *gin.Context) {
+		_, span := tracer.Start(c.Request.Context(), "db.GetBookingDataByID")
+		span.End()
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		c.Error(errors.New("database is down"))
+		c.Status(http.StatusInternalServerError)
+	})
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/booking/7", nil)
+	req.Header.Set("traceparent", parent)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := spans.GetSpans()
+	if len(got) != 2 {
+		t.Fatalf("%d spans, want the handler's and the request's", len(got))
+	}
+	child, server := got[0], got[1]
+	if server.Name != "GET /booking/:id" || server.SpanKind != trace.SpanKindServer {
+		t.Errorf("server span %q of kind %v", server.Name, server.SpanKind)
+	}
+	if tid := server.SpanContext.TraceID().String(); tid != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace %s, want the one from traceparent", tid)
+	}
+	if server.Parent.SpanID().String() != "00f067aa0ba902b7" || !server.Parent.IsRemote() {
+		t.Errorf("parent %v, want the remote span from traceparent", server.Parent)
+	}
+	if child.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("handler span isn't a child of the request span")
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, a := range server.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	if attrs["http.route"].AsString() != "/booking/:id" || attrs["url.path"].AsString() != "/booking/7" || attrs["http.response.status_code"].AsInt64() != 200 {
+		t.Errorf("attributes %v", server.Attributes)
+	}
+	var entry struct {
+		TraceID string `json:"trace_id"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil || entry.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("logged trace_id %q (%v), want the request's", entry.TraceID, err)
+	}
+
+	spans.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	got = spans.GetSpans()
+	if len(got) != 1 {
+		t.Fatalf("%d spans, want 1", len(got))
+	}
+	if got[0].Status.Code != codes.Error || len(got[0].Events) != 1 || got[0].Events[0].Name != "exception" {
+		t.Errorf("status %v with events %v, want an error with the recorded exception", got[0].Status, got[0].Events)
+	}
+	if got[0].Parent.IsValid() {
+		t.Errorf("request without traceparent has parent %v", got[0].Parent)
+	}
+}
@@ -0,0 +1,171 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/subliker/backendproj/config"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/logging"
+	"github.com/subliker/backendproj/metrics"
+	"github.com/subliker/backendproj/model"
+	"github.com/subliker/backendproj/retry"
+)
+
+// Store is the storage of deliveries, *db.DataBase in production.
+type Store interface {
+	EnqueueWebhookDeliveries(ctx context.Context, eventID, event string, payload []byte) (int64, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, leaseUntil time.Time) ([]db.DueDelivery, error)
+	MarkWebhookDelivered(ctx context.Context, id int64, statusCode int) error
+	MarkWebhookFailed(ctx context.Context, id int64, statusCode int, reason string, next time.Time, dead bool) error
+}
+
+// Dispatcher is the outbox sink that stores events as deliveries to the subscribed webhooks,
+// and sends the deliveries in the background.
+// Deliveries live in the database, so they survive restarts, and several instances can send them together.
+type Dispatcher struct {
+	Store  Store
+	Client *http.Client
+	Config config.Webhooks
+}
+
+func NewDispatcher(store Store, cfg config.Webhooks) *Dispatcher {
+	client := &http.Client{
+		Timeout: cfg.Timeout,
+		// a redirect would send the signed event to a URL no admin checked, it fails the attempt instead
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &Dispatcher{Store: store, Client: client, Config: cfg}
+}
+
+// Name is the sink name of the dispatcher in the outbox configuration.
+func (d *Dispatcher) Name() string {
+	return "webhook"
+}
+
+// Publish stores a delivery of the outbox message for every active webhook subscribed to its event type.
+// A message published again adds no deliveries.
+func (d *Dispatcher) Publish(ctx context.Context, msg model.OutboxMessage) error {
+	n, err := d.Store.EnqueueWebhookDeliveries(ctx, msg.Event_id, msg.Event_type, msg.Payload)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logging.FromContext(ctx).Debug("webhook deliveries enqueued", "event_id", msg.Event_id, "event", msg.Event_type, "deliveries", n)
+	}
+	return nil
+}
+
+// Run sends due deliveries every PollInterval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Config.PollInterval)
+	defer ticker.Stop()
+	for {
+		// a full batch means more deliveries may be due already
+		for {
+			n, err := d.SendDue(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "claim webhook deliveries", "err", err)
+			}
+			if err != nil || n < d.Config.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue sends one batch of due deliveries at once and returns its size.
+func (d *Dispatcher) SendDue(ctx context.Context) (int, error) {
+	// a delivery that isn't marked by then, e.g. the instance died, is claimed again
+	leaseUntil := time.Now().Add(2*d.Config.Timeout + time.Minute)
+	deliveries, err := d.Store.ClaimWebhookDeliveries(ctx, d.Config.BatchSize, leaseUntil)
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery db.DueDelivery) {
+			defer wg.Done()
+			d.send(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// send makes one attempt of delivery and records its result.
+func (d *Dispatcher) send(ctx context.Context, delivery db.DueDelivery) {
+	log := slog.Default().With("delivery_id", delivery.Id, "webhook_id", delivery.Webhook_id, "event", delivery.Event)
+	statusCode, err := d.post(ctx, delivery)
+	if err == nil {
+		metrics.WebhookDeliveries.WithLabelValues("delivered").Inc()
+		if err := d.Store.MarkWebhookDelivered(ctx, delivery.Id, statusCode); err != nil {
+			log.WarnContext(ctx, "mark webhook delivery delivered", "err", err)
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		// shutting down, the lease runs out and the attempt is repeated
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	dead := attempts >= d.Config.MaxAttempts
+	next := time.Now().Add(retry.Backoff(d.Config.RetryInitial, d.Config.RetryMax, attempts))
+	if dead {
+		metrics.WebhookDeliveries.WithLabelValues("dead").Inc()
+		log.WarnContext(ctx, "webhook delivery is dead", "attempts", attempts, "status", statusCode, "err", err)
+	} else {
+		metrics.WebhookDeliveries.WithLabelValues("failed").Inc()
+		log.InfoContext(ctx, "webhook delivery failed, retrying", "attempts", attempts, "next_attempt_at", next, "status", statusCode, "err", err)
+	}
+	if err := d.Store.MarkWebhookFailed(ctx, delivery.Id, statusCode, retry.Truncate(err.Error()), next, dead); err != nil {
+		log.WarnContext(ctx, "mark webhook delivery failed", "err", err)
+	}
+}
+
+// post sends the delivery, any 2xx response is a success.
+// statusCode is 0 when there was no response.
+func (d *Dispatcher) post(ctx context.Context, delivery db.DueDelivery) (statusCode int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Config.Timeout)
+	defer cancel()
+
+	now := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "backendproj-webhooks")
+	req.Header.Set(HeaderID, delivery.Event_id)
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, now, delivery.Payload))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(res.Body, retry.MaxErrorLength))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d: %s", res.StatusCode, body)
+	}
+	return res.StatusCode, nil
+}
@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/subliker/backendproj/config"
+	"github.com/subliker/backendproj/db"
+	"github.com/subliker/backendproj/model"
+)
+
+// fakeStore hands out deliveries once and records how they ended.
+type fakeStore struct {
+	mu        sync.Mutex
+	due       []db.DueDelivery
+	delivered map[int64]int
+	failed    map[int64]failure
+}
+
+type failure struct {
+	statusCode int
+	reason     string
+	next       time.Time
+	dead       bool
+}
+
+func newFakeStore(due ...db.DueDelivery) *fakeStore {
+	return &fakeStore{due: due, delivered: map[int64]int{}, failed: map[int64]failure{}}
+}
+
+func (s *fakeStore) EnqueueWebhookDeliveries(context.Context, string, string, []byte) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) ClaimWebhookDeliveries(_ context.Context, limit int, _ time.Time) ([]db.DueDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(limit, len(s.due))
+	claimed := s.due[:n]
+	s.due = s.due[n:]
+	return claimed, nil
+}
+
+func (s *fakeStore) MarkWebhookDelivered(_ context.Context, id int64, statusCode int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delivered[id] = statusCode
+	return nil
+}
+
+func (s *fakeStore) MarkWebhookFailed(_ context.Context, id int64, statusCode int, reason string, next time.Time, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed[id] = failure{statusCode, reason, next, dead}
+	return nil
+}
+
+func testConfig() config.Webhooks {
+	return config.Webhooks{
+		Timeout:      2 * time.Second,
+		MaxAttempts:  3,
+		RetryInitial: time.Minute,
+		RetryMax:     10 * time.Minute,
+		PollInterval: time.Second,
+		BatchSize:    10,
+	}
+}
+
+func delivery(id int64, url string, attempts int) db.DueDelivery {
+	return db.DueDelivery{
+		WebhookDelivery: model.WebhookDelivery{
+			Id:         id,
+			Webhook_id: 1,
+			Event_id:   "evt_" + strconv.FormatInt(id, 10),
+			Event:      "booking.created",
+			Payload:    []byte(`{"id":"evt_1","type":"booking.created","data":{"id":9}}`),
+			Attempts:   attempts,
+		},
+		Url:    url,
+		Secret: "whsec_test_secret_value",
+	}
+}
+
+func TestDispatcherSignsDeliveries(t *testing.T) {
+	var got atomic.Bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		unix, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Errorf("timestamp %q: %v", r.Header.Get(HeaderTimestamp), err)
+		}
+		if !Verify("whsec_test_secret_value", time.Unix(unix, 0), body, r.Header.Get(HeaderSignature)) {
+			t.Errorf("signature %q doesn't verify", r.Header.Get(HeaderSignature))
+		}
+		if r.Header.Get(HeaderID) != "evt_1" || r.Header.Get(HeaderEvent) != "booking.created" || r.Header.Get(HeaderDelivery) != "1" {
+			t.Errorf("headers %v", r.Header)
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("content type %q", r.Header.Get("Content-Type"))
+		}
+		got.Store(true)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	store := newFakeStore(delivery(1, receiver.URL, 0))
+	d := NewDispatcher(store, testConfig())
+	if n, err := d.SendDue(context.Background()); err != nil || n != 1 {
+		t.Fatalf("SendDue() = %d, %v", n, err)
+	}
+	if !got.Load() {
+		t.Fatal("receiver got nothing")
+	}
+	if store.delivered[1] != http.StatusNoContent {
+		t.Errorf("delivered %v, want delivery 1 with 204", store.delivered)
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	cfg := testConfig()
+	tests := []struct {
+		name     string
+		status   int
+		attempts int
+		// delay is the least delay before the next attempt, it's at most 1.5 times that
+		delay time.Duration
+		dead  bool
+	}{
+		{"first failure", http.StatusInternalServerError, 0, cfg.RetryInitial, false},
+		{"second failure doubles the delay", http.StatusBadGateway, 1, 2 * cfg.RetryInitial, false},
+		{"last attempt goes to the dead letters", http.StatusServiceUnavailable, 2, 4 * cfg.RetryInitial, true},
+		{"client error is retried too", http.StatusGone, 0, cfg.RetryInitial, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, "try later")
+			}))
+			defer receiver.Close()
+
+			store := newFakeStore(delivery(7, receiver.URL, tt.attempts))
+			d := NewDispatcher(store, cfg)
+			start := time.Now()
+			if _, err := d.SendDue(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			f, ok := store.failed[7]
+			if !ok {
+				t.Fatalf("delivery isn't marked failed, delivered %v", store.delivered)
+			}
+			if f.statusCode != tt.status || f.dead != tt.dead {
+				t.Errorf("status %d dead %v, want %d %v", f.statusCode, f.dead, tt.status, tt.dead)
+			}
+			if !strings.Contains(f.reason, strconv.Itoa(tt.status)) || !strings.Contains(f.reason, "try later") {
+				t.Errorf("reason %q doesn't tell the status and body", f.reason)
+			}
+			if delay := f.next.Sub(start); delay < tt.delay || delay > tt.delay*3/2+time.Second {
+				t.Errorf("next attempt in %v, want %v to %v", delay, tt.delay, tt.delay*3/2)
+			}
+		})
+	}
+}
+
+func TestDispatcherRefusesRedirects(t *testing.T) {
+	var followed atomic.Bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+
+	store := newFakeStore(delivery(3, receiver.URL, 0))
+	d := NewDispatcher(store, testConfig())
+	if _, err := d.SendDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if followed.Load() {
+		t.Error("the redirect was followed")
+	}
+	if f, ok := store.failed[3]; !ok || f.statusCode != http.StatusTemporaryRedirect {
+		t.Errorf("failure %+v, want the redirect status", f)
+	}
+}
+
+func TestDispatcherUnreachableReceiver(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	url := receiver.URL
+	receiver.Close()
+
+	store := newFakeStore(delivery(4, url, 0))
+	d := NewDispatcher(store, testConfig())
+	if _, err := d.SendDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := store.failed[4]; !ok || f.statusCode != 0 || f.dead {
+		t.Errorf("failure %+v, want a retry without status", f)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	secret, body := "whsec_a", []byte(`{"id":"evt_1"}`)
+	at := time.Unix(1700000000, 0)
+	signature := Sign(secret, at, body)
+	tests := []struct {
+		name      string
+		secret    string
+		at        time.Time
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid", secret, at, body, signature, true},
+		{"other secret", "whsec_b", at, body, signature, false},
+		{"other body", secret, at, []byte(`{"id":"evt_2"}`), signature, false},
+		{"replayed with another timestamp", secret, at.Add(time.Second), body, signature, false},
+		{"without version", secret, at, body, strings.TrimPrefix(signature, "v1="), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.at, tt.body, tt.signature); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if !regexp.MustCompile(`^v1=[0-9a-f]{64}$`).MatchString(signature) {
+		t.Errorf("signature %q isn't v1= and a hex SHA-256", signature)
+	}
+}
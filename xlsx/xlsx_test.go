@@ -0,0 +1,159 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// cell is a cell of the sheet as it's read back, Type is empty for numbers.
+type cell struct {
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+type sheet struct {
+	Rows []struct {
+		Cells []cell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readParts returns the files of the workbook in b, it fails when one isn't well-formed XML.
+func readParts(t *testing.T, b []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string][]byte{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for d := xml.NewDecoder(bytes.NewReader(data)); ; {
+			if _, err := d.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s isn't well-formed: %v", f.Name, err)
+			}
+		}
+		parts[f.Name] = data
+	}
+	return parts
+}
+
+func TestWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := NewWriter(&b, `Bookings <2023> & "more"`)
+	rows := [][]any{
+		{"id", "comment"},
+		{7, "plain"},
+		{int64(8), "<b>tags</b> & ampersands"},
+		{1.5, "  spaces kept  "},
+		{float32(2), "control \x01 character"},
+		{"", nil},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := readParts(t, b.Bytes())
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/workbook.xml", "xl/worksheets/sheet1.xml"} {
+		if parts[name] == nil {
+			t.Errorf("no %s", name)
+		}
+	}
+	if !bytes.Contains(parts["xl/workbook.xml"], []byte(`name="Bookings &lt;2023&gt; &amp; &#34;more&#34;"`)) {
+		t.Errorf("sheet name isn't escaped: %s", parts["xl/workbook.xml"])
+	}
+
+	var got sheet
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &got); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]cell{
+		{{"inlineStr", "", "id"}, {"inlineStr", "", "comment"}},
+		{{"", "7", ""}, {"inlineStr", "", "plain"}},
+		{{"", "8", ""}, {"inlineStr", "", "<b>tags</b> & ampersands"}},
+		{{"", "1.5", ""}, {"inlineStr", "", "  spaces kept  "}},
+		{{"", "2", ""}, {"inlineStr", "", "control � character"}},
+		{{"inlineStr", "", ""}, {"inlineStr", "", "<nil>"}},
+	}
+	if len(got.Rows) != len(want) {
+		t.Fatalf("%d rows, want %d", len(got.Rows), len(want))
+	}
+	for i, row := range got.Rows {
+		if len(row.Cells) != len(want[i]) {
+			t.Errorf("row %d has %d cells, want %d", i, len(row.Cells), len(want[i]))
+			continue
+		}
+		for j, c := range row.Cells {
+			if c != want[i][j] {
+				t.Errorf("row %d cell %d = %+v, want %+v", i, j, c, want[i][j])
+			}
+		}
+	}
+}
+
+func TestWriterMaxRows(t *testing.T) {
+	var b bytes.Buffer
+	w := NewWriter(&b, "Users")
+	w.rows = MaxRows - 1
+	if err := w.WriteRow([]any{"last"}); err != nil {
+		t.Fatalf("row %d: %v", MaxRows, err)
+	}
+	if err := w.WriteRow([]any{"one too many"}); !errors.Is(err, ErrTooManyRows) {
+		t.Fatalf("row %d: %v, want ErrTooManyRows", MaxRows+1, err)
+	}
+	// the rows written so far are still a valid workbook
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sheet := readParts(t, b.Bytes())["xl/worksheets/sheet1.xml"]
+	if strings.Contains(string(sheet), "one too many") {
+		t.Error("the row over the limit was written")
+	}
+}
+
+// failingWriter fails every write after the first n bytes.
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errors.New("disk full")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestWriterFailure(t *testing.T) {
+	w := NewWriter(&failingWriter{n: 100}, "Bookings")
+	var err error
+	for i := 0; i < 10000 && err == nil; i++ {
+		err = w.WriteRow([]any{i, strings.Repeat("x", 100)})
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("error %v, want the error of the underlying writer", err)
+	}
+}